@@ -0,0 +1,158 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"predict-death/longevity"
+)
+
+func formatBatchDiff(diffDays int, count int) string {
+	if count == 0 {
+		return "n/a"
+	}
+	return formatDiff(diffDays)
+}
+
+// batchDiffs returns a summary's male, female and overall diffs for the given statistic, along with
+// the number of ancestors each covers.
+func batchDiffs(summary longevity.BatchSummary, stat string) ([]int, []int) {
+	diffs := []int{
+		pickDiff(stat, summary.MaleLifeExpectancyDiffDays, summary.MaleMedianAgeAtDeathDiffDays, summary.MaleModalAgeAtDeathDiffDays),
+		pickDiff(stat, summary.FemaleLifeExpectancyDiffDays, summary.FemaleMedianAgeAtDeathDiffDays, summary.FemaleModalAgeAtDeathDiffDays),
+		pickDiff(stat, summary.OverallLifeExpectancyDiffDays, summary.OverallMedianAgeAtDeathDiffDays, summary.OverallModalAgeAtDeathDiffDays),
+	}
+	return diffs, []int{summary.MaleAncestorCount, summary.FemaleAncestorCount, summary.AncestorCount}
+}
+
+// batchStatLabel is the short name a diff statistic is given in batch column headings.
+func batchStatLabel(stat string) string {
+	switch stat {
+	case lifeExpectancyStat:
+		return "Life Expectancy"
+	case medianStat:
+		return "Median"
+	}
+	return "Modal"
+}
+
+func printBatchResults(summaries []longevity.BatchSummary, weighting string, stats StatSelection) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "===========================================================================================")
+	fmt.Fprintln(w, "Longevity statistics for the direct ancestors of "+strconv.Itoa(len(summaries))+" subjects ("+weighting+" weighting)")
+	fmt.Fprintln(w, "===========================================================================================")
+	header := []string{"Xref", "Name", "Born", "Ancestors", "Excluded"}
+	for _, stat := range stats.diffStats() {
+		label := batchStatLabel(stat)
+		header = append(header, "Male "+label+" Diff", "Female "+label+" Diff", "Overall "+label+" Diff")
+	}
+	if stats.includes(percentileStat) {
+		header = append(header, "Mean Survival Percentile")
+	}
+	fmt.Fprintln(w, strings.Join(header, "\t"))
+	for _, summary := range summaries {
+		born := ""
+		if year, ok := longevity.BirthYear(summary.Subject); ok {
+			born = strconv.Itoa(year)
+		}
+		row := []string{summary.Subject.Xref, longevity.IndividualName(summary.Subject), born}
+		if summary.Error != "" {
+			for len(row) < len(header) {
+				row = append(row, "n/a")
+			}
+			fmt.Fprintln(w, strings.Join(row, "\t"))
+			continue
+		}
+		row = append(row, strconv.Itoa(summary.AncestorCount), strconv.Itoa(summary.ExcludedCount))
+		for _, stat := range stats.diffStats() {
+			diffs, counts := batchDiffs(summary, stat)
+			for i := range diffs {
+				row = append(row, formatBatchDiff(diffs[i], counts[i]))
+			}
+		}
+		if stats.includes(percentileStat) {
+			row = append(row, formatPercentile(summary.OverallSurvivalPercentile, summary.HasOverallSurvivalPercentile))
+		}
+		fmt.Fprintln(w, strings.Join(row, "\t"))
+	}
+	w.Flush()
+
+	for _, summary := range summaries {
+		if summary.Error != "" {
+			fmt.Printf("@%s@ was not analysed: %s\n", summary.Subject.Xref, summary.Error)
+		}
+	}
+}
+
+func writeBatchCsv(summaries []longevity.BatchSummary, csvFileName string, stats StatSelection) error {
+	if !strings.HasSuffix(csvFileName, ".csv") {
+		csvFileName = csvFileName + ".csv"
+	}
+	file, err := os.Create(csvFileName)
+	if err != nil {
+		return err
+	}
+	writer := csv.NewWriter(file)
+
+	header := []string{"Xref", "Name", "Born", "Weighting", "Ancestors", "Excluded ancestors", "Male ancestors", "Female ancestors"}
+	for _, sex := range []string{"Male", "Female", "Overall"} {
+		for _, stat := range stats.diffStats() {
+			label := batchStatLabel(stat)
+			if stat != lifeExpectancyStat {
+				label += " Death Age"
+			}
+			header = append(header, sex+" "+label+" Diff (days)")
+		}
+	}
+	if stats.includes(percentileStat) {
+		header = append(header, "Overall mean survival percentile")
+	}
+	header = append(header, "Error")
+	writer.Write(header)
+
+	for _, summary := range summaries {
+		born := ""
+		if year, ok := longevity.BirthYear(summary.Subject); ok {
+			born = strconv.Itoa(year)
+		}
+		row := []string{
+			summary.Subject.Xref,
+			longevity.IndividualName(summary.Subject),
+			born,
+			summary.Weighting,
+		}
+		if summary.Error != "" {
+			for len(row) < len(header)-1 {
+				row = append(row, "")
+			}
+			writer.Write(append(row, summary.Error))
+			continue
+		}
+		row = append(row,
+			strconv.Itoa(summary.AncestorCount),
+			strconv.Itoa(summary.ExcludedCount),
+			strconv.Itoa(summary.MaleAncestorCount),
+			strconv.Itoa(summary.FemaleAncestorCount),
+		)
+		for sex := 0; sex < 3; sex++ {
+			for _, stat := range stats.diffStats() {
+				diffs, _ := batchDiffs(summary, stat)
+				row = append(row, strconv.Itoa(diffs[sex]))
+			}
+		}
+		if stats.includes(percentileStat) {
+			row = append(row, csvPercentile(summary.OverallSurvivalPercentile, summary.HasOverallSurvivalPercentile))
+		}
+		writer.Write(append(row, ""))
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
@@ -0,0 +1,240 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"math"
+	"sort"
+	"strings"
+
+	"predict-death/longevity"
+)
+
+// The charts are plain SVG written by hand so the report needs nothing beyond the file itself.
+
+const (
+	maleColour    = "#1f77b4"
+	femaleColour  = "#d62728"
+	unknownColour = "#7f7f7f"
+	axisColour    = "#444444"
+	gridColour    = "#dddddd"
+	chartFont     = `font-family="sans-serif" font-size="11"`
+)
+
+// diffColourRangeYears is the diff at which the fan chart's colour scale saturates.
+const diffColourRangeYears = 15.0
+
+// linearScale maps values from a domain onto a range of pixels.
+type linearScale struct {
+	domainMin, domainMax float64
+	rangeMin, rangeMax   float64
+}
+
+func (s linearScale) at(value float64) float64 {
+	if s.domainMax == s.domainMin {
+		return (s.rangeMin + s.rangeMax) / 2
+	}
+	return s.rangeMin + (value-s.domainMin)/(s.domainMax-s.domainMin)*(s.rangeMax-s.rangeMin)
+}
+
+// niceTicks returns round tick values spanning min to max, roughly count of them.
+func niceTicks(min float64, max float64, count int) []float64 {
+	if max <= min || count < 1 {
+		return []float64{min}
+	}
+	rawStep := (max - min) / float64(count)
+	magnitude := math.Pow(10, math.Floor(math.Log10(rawStep)))
+	step := magnitude
+	for _, multiple := range []float64{1, 2, 5, 10} {
+		step = multiple * magnitude
+		if step >= rawStep {
+			break
+		}
+	}
+	var ticks []float64
+	for tick := math.Ceil(min/step) * step; tick <= max+step/1e6; tick += step {
+		ticks = append(ticks, tick)
+	}
+	return ticks
+}
+
+func sexColour(gender string) string {
+	switch gender {
+	case "m":
+		return maleColour
+	case "f":
+		return femaleColour
+	}
+	return unknownColour
+}
+
+// diffColour shades a diff from red for ancestors who died young, through white, to blue for those
+// who lived long, saturating at diffColourRangeYears either way.
+func diffColour(diffDays int) string {
+	t := float64(diffDays) / 365 / diffColourRangeYears
+	t = math.Max(-1, math.Min(1, t))
+	white := [3]float64{247, 247, 247}
+	end := [3]float64{69, 117, 180}
+	if t < 0 {
+		end = [3]float64{215, 48, 39}
+		t = -t
+	}
+	var rgb [3]int
+	for i := range rgb {
+		rgb[i] = int(math.Round(white[i] + (end[i]-white[i])*t))
+	}
+	return fmt.Sprintf("#%02x%02x%02x", rgb[0], rgb[1], rgb[2])
+}
+
+func svgText(text string) string {
+	return html.EscapeString(text)
+}
+
+// writeAxes draws the left and bottom axes of a chart with their ticks and labels.
+func writeAxes(b *strings.Builder, x linearScale, y linearScale, xTicks []float64, yTicks []float64, xLabel string, yLabel string) {
+	for _, tick := range yTicks {
+		fmt.Fprintf(b, `<line x1="%.1f" y1="%.1f" x2="%.1f" y2="%.1f" stroke="%s"/>`+"\n", x.rangeMin, y.at(tick), x.rangeMax, y.at(tick), gridColour)
+		fmt.Fprintf(b, `<text x="%.1f" y="%.1f" text-anchor="end" %s>%g</text>`+"\n", x.rangeMin-6, y.at(tick)+4, chartFont, tick)
+	}
+	for _, tick := range xTicks {
+		fmt.Fprintf(b, `<line x1="%.1f" y1="%.1f" x2="%.1f" y2="%.1f" stroke="%s"/>`+"\n", x.at(tick), y.rangeMin, x.at(tick), y.rangeMin+5, axisColour)
+		fmt.Fprintf(b, `<text x="%.1f" y="%.1f" text-anchor="middle" %s>%g</text>`+"\n", x.at(tick), y.rangeMin+18, chartFont, tick)
+	}
+	fmt.Fprintf(b, `<line x1="%.1f" y1="%.1f" x2="%.1f" y2="%.1f" stroke="%s"/>`+"\n", x.rangeMin, y.rangeMin, x.rangeMax, y.rangeMin, axisColour)
+	fmt.Fprintf(b, `<line x1="%.1f" y1="%.1f" x2="%.1f" y2="%.1f" stroke="%s"/>`+"\n", x.rangeMin, y.rangeMin, x.rangeMin, y.rangeMax, axisColour)
+	fmt.Fprintf(b, `<text x="%.1f" y="%.1f" text-anchor="middle" %s>%s</text>`+"\n", (x.rangeMin+x.rangeMax)/2, y.rangeMin+36, chartFont, svgText(xLabel))
+	fmt.Fprintf(b, `<text transform="translate(14 %.1f) rotate(-90)" text-anchor="middle" %s>%s</text>`+"\n", (y.rangeMin+y.rangeMax)/2, chartFont, svgText(yLabel))
+}
+
+// scatterChartSvg plots each ancestor's age at death against the year they died, alongside the
+// median and modal ages at death they were compared with.
+func scatterChartSvg(ancestors []longevity.AncestorDeath) string {
+	const width, height = 720.0, 400.0
+	const left, right, top, bottom = 60.0, 140.0, 20.0, 50.0
+
+	minYear, maxYear := math.Inf(1), math.Inf(-1)
+	maxAge := 100.0
+	for _, ancestor := range ancestors {
+		minYear = math.Min(minYear, float64(ancestor.Year))
+		maxYear = math.Max(maxYear, float64(ancestor.Year))
+		for _, days := range []int{ancestor.AgeAtDeathDaysTotal, ancestor.MedianDeathAgeDays, ancestor.ModalDeathAgeDays} {
+			maxAge = math.Max(maxAge, float64(days)/365)
+		}
+	}
+	if len(ancestors) == 0 {
+		minYear, maxYear = 1900, 2000
+	}
+	minYear, maxYear = math.Floor(minYear/10)*10, math.Ceil((maxYear+1)/10)*10
+
+	x := linearScale{minYear, maxYear, left, width - right}
+	y := linearScale{0, math.Ceil(maxAge/10) * 10, height - bottom, top}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 %g %g" width="%g" height="%g" role="img">`+"\n", width, height, width, height)
+	fmt.Fprintf(&b, "<title>Age at death by year of death</title>\n")
+	writeAxes(&b, x, y, niceTicks(x.domainMin, x.domainMax, 8), niceTicks(y.domainMin, y.domainMax, 6), "Year of death", "Age (years)")
+
+	for _, ancestor := range ancestors {
+		cx := x.at(float64(ancestor.Year))
+		median := y.at(float64(ancestor.MedianDeathAgeDays) / 365)
+		modal := y.at(float64(ancestor.ModalDeathAgeDays) / 365)
+		fmt.Fprintf(&b, `<line x1="%.1f" y1="%.1f" x2="%.1f" y2="%.1f" stroke="%s" stroke-width="2"/>`+"\n", cx-4, median, cx+4, median, unknownColour)
+		fmt.Fprintf(&b, `<circle cx="%.1f" cy="%.1f" r="3" fill="none" stroke="%s"/>`+"\n", cx, modal, unknownColour)
+	}
+	for _, ancestor := range ancestors {
+		fmt.Fprintf(&b, `<circle cx="%.1f" cy="%.1f" r="4" fill="%s" fill-opacity="0.8"><title>%s (%d-%d): %s</title></circle>`+"\n",
+			x.at(float64(ancestor.Year)), y.at(float64(ancestor.AgeAtDeathDaysTotal)/365), sexColour(ancestor.Gender),
+			svgText(ancestor.Name), ancestor.BirthYear, ancestor.Year, formatYearsAndDays(ancestor.AgeAtDeathDaysTotal))
+	}
+
+	legendX := width - right + 16
+	for i, item := range []struct{ colour, label string }{{maleColour, "Men"}, {femaleColour, "Women"}, {unknownColour, "Unknown sex"}} {
+		cy := top + 10 + float64(i)*18
+		fmt.Fprintf(&b, `<circle cx="%.1f" cy="%.1f" r="4" fill="%s"/>`+"\n", legendX, cy, item.colour)
+		fmt.Fprintf(&b, `<text x="%.1f" y="%.1f" %s>%s</text>`+"\n", legendX+10, cy+4, chartFont, item.label)
+	}
+	cy := top + 10 + 3*18
+	fmt.Fprintf(&b, `<line x1="%.1f" y1="%.1f" x2="%.1f" y2="%.1f" stroke="%s" stroke-width="2"/>`+"\n", legendX-4, cy, legendX+4, cy, unknownColour)
+	fmt.Fprintf(&b, `<text x="%.1f" y="%.1f" %s>Median age</text>`+"\n", legendX+10, cy+4, chartFont)
+	cy += 18
+	fmt.Fprintf(&b, `<circle cx="%.1f" cy="%.1f" r="3" fill="none" stroke="%s"/>`+"\n", legendX, cy, unknownColour)
+	fmt.Fprintf(&b, `<text x="%.1f" y="%.1f" %s>Modal age</text>`+"\n", legendX+10, cy+4, chartFont)
+	b.WriteString("</svg>\n")
+	return b.String()
+}
+
+// generationDiffs returns the mean diff from each of the given statistics for every generation with
+// ancestors in it, nearest generation first.
+func generationDiffs(ancestors []longevity.AncestorDeath, stats []string) ([]int, [][]int) {
+	byGeneration := map[int][]longevity.AncestorDeath{}
+	for _, ancestor := range ancestors {
+		byGeneration[ancestor.GenerationsRemoved] = append(byGeneration[ancestor.GenerationsRemoved], ancestor)
+	}
+	var generations []int
+	for generation := range byGeneration {
+		generations = append(generations, generation)
+	}
+	sort.Ints(generations)
+
+	diffs := make([][]int, len(generations))
+	for i, generation := range generations {
+		lifeExpectancy, median, modal := longevity.CalculateWeightedAverages(byGeneration[generation], "", longevity.EqualWeighter{})
+		for _, stat := range stats {
+			diffs[i] = append(diffs[i], pickDiff(stat, lifeExpectancy, median, modal))
+		}
+	}
+	return generations, diffs
+}
+
+var statColours = map[string]string{
+	lifeExpectancyStat: "#2ca02c",
+	medianStat:         "#9467bd",
+	modalStat:          "#ff7f0e",
+}
+
+// generationChartSvg draws a grouped bar chart of the mean diffs for each generation.
+func generationChartSvg(ancestors []longevity.AncestorDeath, stats StatSelection) string {
+	const width, height = 720.0, 360.0
+	const left, right, top, bottom = 60.0, 180.0, 20.0, 50.0
+
+	diffStats := stats.diffStats()
+	generations, diffs := generationDiffs(ancestors, diffStats)
+
+	minDiff, maxDiff := -5.0, 5.0
+	for _, generationDiffs := range diffs {
+		for _, diff := range generationDiffs {
+			minDiff = math.Min(minDiff, float64(diff)/365)
+			maxDiff = math.Max(maxDiff, float64(diff)/365)
+		}
+	}
+	y := linearScale{math.Floor(minDiff/5) * 5, math.Ceil(maxDiff/5) * 5, height - bottom, top}
+	x := linearScale{0, float64(len(generations)), left, width - right}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 %g %g" width="%g" height="%g" role="img">`+"\n", width, height, width, height)
+	fmt.Fprintf(&b, "<title>Mean difference by generation</title>\n")
+	writeAxes(&b, x, y, nil, niceTicks(y.domainMin, y.domainMax, 6), "Generations removed from subject", "Mean difference (years)")
+	fmt.Fprintf(&b, `<line x1="%.1f" y1="%.1f" x2="%.1f" y2="%.1f" stroke="%s"/>`+"\n", x.rangeMin, y.at(0), x.rangeMax, y.at(0), axisColour)
+
+	slot := (x.rangeMax - x.rangeMin) / math.Max(1, float64(len(generations)))
+	barWidth := slot * 0.8 / math.Max(1, float64(len(diffStats)))
+	for i, generation := range generations {
+		slotStart := x.rangeMin + float64(i)*slot + slot*0.1
+		for j, stat := range diffStats {
+			value := float64(diffs[i][j]) / 365
+			barTop, barBottom := y.at(math.Max(value, 0)), y.at(math.Min(value, 0))
+			fmt.Fprintf(&b, `<rect x="%.1f" y="%.1f" width="%.1f" height="%.1f" fill="%s"><title>Generation %d: %s from %s</title></rect>`+"\n",
+				slotStart+float64(j)*barWidth, barTop, barWidth, barBottom-barTop, statColours[stat], generation, formatDiff(diffs[i][j]), svgText(strings.ToLower(statLabel(stat))))
+		}
+		fmt.Fprintf(&b, `<text x="%.1f" y="%.1f" text-anchor="middle" %s>%d</text>`+"\n", x.rangeMin+(float64(i)+0.5)*slot, y.rangeMin+18, chartFont, generation)
+	}
+
+	legendX := width - right + 16
+	for i, stat := range diffStats {
+		cy := top + 10 + float64(i)*18
+		fmt.Fprintf(&b, `<rect x="%.1f" y="%.1f" width="10" height="10" fill="%s"/>`+"\n", legendX-5, cy-5, statColours[stat])
+		fmt.Fprintf(&b, `<text x="%.1f" y="%.1f" %s>%s</text>`+"\n", legendX+10, cy+4, chartFont, svgText(statLabel(stat)))
+	}
+	b.WriteString("</svg>\n")
+	return b.String()
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"encoding/xml"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+
+	"predict-death/longevity"
+)
+
+// checkSvg fails the test if svg isn't well-formed XML.
+func checkSvg(t *testing.T, svg string) {
+	t.Helper()
+	decoder := xml.NewDecoder(strings.NewReader(svg))
+	for {
+		_, err := decoder.Token()
+		if err == io.EOF {
+			return
+		}
+		if err != nil {
+			t.Fatalf("invalid SVG: %s\n%s", err, svg)
+		}
+	}
+}
+
+func TestNiceTicks(t *testing.T) {
+	tests := []struct {
+		min, max float64
+		count    int
+		want     []float64
+	}{
+		{0, 100, 5, []float64{0, 20, 40, 60, 80, 100}},
+		{1840, 2000, 8, []float64{1840, 1860, 1880, 1900, 1920, 1940, 1960, 1980, 2000}},
+		{-10, 5, 6, []float64{-10, -5, 0, 5}},
+	}
+	for _, tt := range tests {
+		if got := niceTicks(tt.min, tt.max, tt.count); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("niceTicks(%v, %v, %d) = %v, want %v", tt.min, tt.max, tt.count, got, tt.want)
+		}
+	}
+}
+
+func TestDiffColour(t *testing.T) {
+	tests := map[int]string{
+		0:          "#f7f7f7",
+		15 * 365:   "#4575b4",
+		40 * 365:   "#4575b4",
+		-15 * 365:  "#d73027",
+		-100 * 365: "#d73027",
+	}
+	for diff, want := range tests {
+		if got := diffColour(diff); got != want {
+			t.Errorf("diffColour(%d) = %s, want %s", diff, got, want)
+		}
+	}
+}
+
+func TestCharts(t *testing.T) {
+	ancestors := []longevity.AncestorDeath{
+		{Xref: "I2", Name: "Living & Well", Year: 1970, BirthYear: 1900, GenerationsRemoved: 1, Gender: "m", AgeAtDeathDaysTotal: 70 * 365, MedianDeathAgeDays: 72 * 365, ModalDeathAgeDays: 75 * 365, MedianAgeAtDeathDiffDays: -2 * 365},
+		{Xref: "I4", Name: "Early Jones", Year: 1760, BirthYear: 1700, GenerationsRemoved: 2, Gender: "u", AgeAtDeathDaysTotal: 60 * 365, MedianDeathAgeDays: 50 * 365, ModalDeathAgeDays: 70 * 365, MedianAgeAtDeathDiffDays: 10 * 365},
+	}
+	stats, _ := parseStatSelection("all")
+
+	for name, svg := range map[string]string{
+		"scatter":    scatterChartSvg(ancestors),
+		"generation": generationChartSvg(ancestors, stats),
+		"empty":      scatterChartSvg(nil),
+	} {
+		t.Run(name, func(t *testing.T) {
+			checkSvg(t, svg)
+		})
+	}
+
+	if scatter := scatterChartSvg(ancestors); !strings.Contains(scatter, "Living &amp; Well") {
+		t.Errorf("expected names to be escaped:\n%s", scatter)
+	}
+}
+
+func TestGenerationDiffs(t *testing.T) {
+	ancestors := []longevity.AncestorDeath{
+		{GenerationsRemoved: 2, MedianAgeAtDeathDiffDays: 100, ModalAgeAtDeathDiffDays: 10},
+		{GenerationsRemoved: 1, MedianAgeAtDeathDiffDays: 300, ModalAgeAtDeathDiffDays: 30},
+		{GenerationsRemoved: 2, MedianAgeAtDeathDiffDays: 200, ModalAgeAtDeathDiffDays: 20},
+	}
+	generations, diffs := generationDiffs(ancestors, []string{medianStat, modalStat})
+	if !reflect.DeepEqual(generations, []int{1, 2}) {
+		t.Errorf("got generations %v", generations)
+	}
+	if !reflect.DeepEqual(diffs, [][]int{{300, 30}, {150, 15}}) {
+		t.Errorf("got diffs %v", diffs)
+	}
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+
+	"predict-death/longevity"
+)
+
+func printExclusions(exclusions []longevity.Exclusion) {
+	if len(exclusions) == 0 {
+		return
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "===========================================================================================")
+	fmt.Fprintf(w, "Excluded ancestors (%d)\n", len(exclusions))
+	fmt.Fprintln(w, "===========================================================================================")
+	fmt.Fprintln(w, "Xref\tName\tGenerations removed from subject\tReason\tDetail")
+	for _, exclusion := range exclusions {
+		fmt.Fprintf(w, "@%s@\t%s\t%s\t%s\t%s\n", exclusion.Xref, exclusion.Name, formatGenerations(exclusion.Paths), exclusion.Reason, exclusion.Detail)
+	}
+	w.Flush()
+}
+
+func writeExclusionsCsv(exclusions []longevity.Exclusion, csvFileName string) error {
+	file, err := os.Create(csvFileName)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	writer := csv.NewWriter(file)
+
+	writer.Write([]string{"Xref", "Name", "Generations removed", "Paths", "Reason", "Detail"})
+	for _, exclusion := range exclusions {
+		writer.Write([]string{
+			exclusion.Xref,
+			exclusion.Name,
+			strconv.Itoa(exclusion.GenerationsRemoved),
+			strconv.Itoa(exclusion.Paths.Count()),
+			exclusion.Reason,
+			exclusion.Detail,
+		})
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+func writeExclusionsJson(exclusions []longevity.Exclusion, jsonFileName string) error {
+	data, err := json.MarshalIndent(newJsonExclusions(exclusions), "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(jsonFileName, append(data, '\n'), 0o644)
+}
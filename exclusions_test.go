@@ -0,0 +1,59 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"predict-death/longevity"
+)
+
+func TestWriteExclusions(t *testing.T) {
+	exclusions := []longevity.Exclusion{
+		{Xref: "I2", Name: "Living Smith", GenerationsRemoved: 1, Paths: longevity.AncestorPaths{1: 1}, Reason: longevity.NoDeathDateExclusion, Detail: "no dated DEAT event"},
+	}
+	dir := t.TempDir()
+
+	csvPath := filepath.Join(dir, "exclusions.csv")
+	if err := writeExclusionsCsv(exclusions, csvPath); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	file, err := os.Open(csvPath)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer file.Close()
+	records, err := csv.NewReader(file).ReadAll()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	wantRecords := [][]string{
+		{"Xref", "Name", "Generations removed", "Paths", "Reason", "Detail"},
+		{"I2", "Living Smith", "1", "1", "no death date", "no dated DEAT event"},
+	}
+	if !reflect.DeepEqual(records, wantRecords) {
+		t.Errorf("got %v, want %v", records, wantRecords)
+	}
+
+	jsonPath := filepath.Join(dir, "exclusions.json")
+	if err := writeExclusionsJson(exclusions, jsonPath); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	data, err := os.ReadFile(jsonPath)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	var decoded []map[string]interface{}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	wantJson := []map[string]interface{}{
+		{"xref": "I2", "name": "Living Smith", "generations_removed": float64(1), "reason": "no death date", "detail": "no dated DEAT event"},
+	}
+	if !reflect.DeepEqual(decoded, wantJson) {
+		t.Errorf("got %v, want %v", decoded, wantJson)
+	}
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"strings"
+
+	"github.com/iand/gedcom"
+	"predict-death/longevity"
+)
+
+// defaultFanChartGenerations is how many generations of ancestors the fan chart shows unless told
+// otherwise. Beyond about eight the outer segments are too thin to label.
+const defaultFanChartGenerations = 6
+
+const (
+	excludedColour  = "#bdbdbd"
+	notShownColour  = "#ffffff"
+	fanLabelColour  = "#222222"
+	maxFanFontSize  = 12.0
+	minFanFontSize  = 5.0
+	fanCharWidthEms = 0.6
+)
+
+// fanSegment is an ancestor's place in the fan chart: the generation they're in and their position
+// within it, counting from the subject's father's father's... line.
+type fanSegment struct {
+	individual *gedcom.IndividualRecord
+	generation int
+	position   int
+}
+
+// fanSegments walks the subject's pedigree father first, placing each ancestor by their line of
+// descent. An ancestor reached by more than one line, whose paths longevity.GetAncestors counts,
+// appears once for each.
+func fanSegments(subject *gedcom.IndividualRecord, generations int) []fanSegment {
+	var segments []fanSegment
+	current := []*gedcom.IndividualRecord{subject}
+	for generation := 1; generation <= generations; generation++ {
+		next := make([]*gedcom.IndividualRecord, len(current)*2)
+		for i, individual := range current {
+			if individual == nil {
+				continue
+			}
+			for _, parentRecord := range individual.Parents {
+				if parentRecord.Family == nil {
+					continue
+				}
+				if next[2*i] == nil {
+					next[2*i] = parentRecord.Family.Husband
+				}
+				if next[2*i+1] == nil {
+					next[2*i+1] = parentRecord.Family.Wife
+				}
+			}
+		}
+		for position, individual := range next {
+			if individual != nil {
+				segments = append(segments, fanSegment{individual, generation, position})
+			}
+		}
+		current = next
+	}
+	return segments
+}
+
+func polarPoint(cx float64, cy float64, radius float64, angle float64) (float64, float64) {
+	return cx + radius*math.Cos(angle), cy - radius*math.Sin(angle)
+}
+
+// formatLifespan shows the years someone was born and died, as far as they're known.
+func formatLifespan(born int, hasBirth bool, died int, hasDeath bool) string {
+	switch {
+	case hasBirth && hasDeath:
+		return fmt.Sprintf("%d–%d", born, died)
+	case hasBirth:
+		return fmt.Sprintf("b. %d", born)
+	case hasDeath:
+		return fmt.Sprintf("d. %d", died)
+	}
+	return ""
+}
+
+func individualLifespan(individual *gedcom.IndividualRecord) string {
+	born, hasBirth := longevity.BirthYear(individual)
+	died, hasDeath := longevity.DeathYear(individual)
+	return formatLifespan(born, hasBirth, died, hasDeath)
+}
+
+// truncateLabel shortens text to at most maxChars characters, marking the cut with an ellipsis.
+func truncateLabel(text string, maxChars int) string {
+	runes := []rune(text)
+	if len(runes) <= maxChars {
+		return text
+	}
+	if maxChars < 2 {
+		return ""
+	}
+	return string(runes[:maxChars-1]) + "…"
+}
+
+// writeFanLabel writes a two-line label centred at the given angle and radius. Labels run along the
+// ring where a segment is wider than it is deep and out along the radius otherwise, turned so they're
+// never upside down.
+func writeFanLabel(b *strings.Builder, cx float64, cy float64, radius float64, angle float64, length float64, thickness float64, lines []string) {
+	tangential := length >= thickness
+	if !tangential {
+		length, thickness = thickness, length
+	}
+	fontSize := math.Max(minFanFontSize, math.Min(maxFanFontSize, thickness/2.6))
+	maxChars := int(length * 0.9 / (fontSize * fanCharWidthEms))
+
+	degrees := angle * 180 / math.Pi
+	rotation := 90 - degrees
+	if !tangential {
+		rotation = -degrees
+		if degrees > 90 {
+			rotation = 180 - degrees
+		}
+	}
+
+	x, y := polarPoint(cx, cy, radius, angle)
+	fmt.Fprintf(b, `<text transform="translate(%.1f %.1f) rotate(%.1f)" text-anchor="middle" font-family="sans-serif" font-size="%.1f" fill="%s">`, x, y, rotation, fontSize, fanLabelColour)
+	offset := -0.2 * float64(len(lines)-1)
+	for i, line := range lines {
+		dy := fmt.Sprintf("%.1fem", offset+0.35)
+		if i > 0 {
+			dy = "1.1em"
+		}
+		fmt.Fprintf(b, `<tspan x="0" dy="%s">%s</tspan>`, dy, svgText(truncateLabel(line, maxChars)))
+	}
+	b.WriteString("</text>\n")
+}
+
+// fanChartSvg draws a semicircular fan chart of the subject's pedigree, with the subject at the
+// centre and fathers' lines on the left. Each ancestor is labelled with their name and lifespan.
+// Compared ancestors are coloured by how their age at death compared with the median age at death.
+// Ancestors left out of the analysis are grey.
+func fanChartSvg(subject *gedcom.IndividualRecord, ancestors []longevity.AncestorDeath, exclusions []longevity.Exclusion, generations int) string {
+	const width, height = 900.0, 520.0
+	const centreRadius, outerRadius = 60.0, 430.0
+	cx, cy := width/2, outerRadius+20
+	ringWidth := (outerRadius - centreRadius) / float64(generations)
+
+	deaths := map[string]longevity.AncestorDeath{}
+	for _, ancestor := range ancestors {
+		deaths[ancestor.Xref] = ancestor
+	}
+	excluded := map[string]longevity.Exclusion{}
+	for _, exclusion := range exclusions {
+		excluded[exclusion.Xref] = exclusion
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 %g %g" width="%g" height="%g" role="img">`+"\n", width, height, width, height)
+	fmt.Fprintf(&b, "<title>Pedigree of %s coloured by difference from median age at death</title>\n", svgText(longevity.IndividualName(subject)))
+	fmt.Fprintf(&b, `<defs><linearGradient id="fan-diff-scale"><stop offset="0" stop-color="%s"/><stop offset="0.5" stop-color="%s"/><stop offset="1" stop-color="%s"/></linearGradient></defs>`+"\n",
+		diffColour(-int(diffColourRangeYears*365)), diffColour(0), diffColour(int(diffColourRangeYears*365)))
+
+	fmt.Fprintf(&b, `<path d="M %.1f %.1f A %g %g 0 0 1 %.1f %.1f Z" fill="%s" stroke="%s"><title>%s</title></path>`+"\n",
+		cx-centreRadius, cy, centreRadius, centreRadius, cx+centreRadius, cy, notShownColour, axisColour, svgText(longevity.IndividualName(subject)))
+	writeFanLabel(&b, cx, cy, centreRadius/2, math.Pi/2, centreRadius*1.6, centreRadius/2, []string{longevity.IndividualName(subject), individualLifespan(subject)})
+
+	for _, segment := range fanSegments(subject, generations) {
+		inner := centreRadius + float64(segment.generation-1)*ringWidth
+		outer := inner + ringWidth
+		span := math.Pi / math.Pow(2, float64(segment.generation))
+		start := math.Pi - float64(segment.position)*span
+		end := start - span
+
+		name := longevity.IndividualName(segment.individual)
+		lifespan := individualLifespan(segment.individual)
+		fill, title := notShownColour, name
+		if death, ok := deaths[segment.individual.Xref]; ok {
+			lifespan = formatLifespan(death.BirthYear, true, death.Year, true)
+			fill = diffColour(death.MedianAgeAtDeathDiffDays)
+			title = fmt.Sprintf("%s (%s): %s from median age at death", name, lifespan, formatDiff(death.MedianAgeAtDeathDiffDays))
+		} else if exclusion, ok := excluded[segment.individual.Xref]; ok {
+			fill = excludedColour
+			title = fmt.Sprintf("%s: left out (%s)", name, exclusion.Reason)
+		}
+
+		x1, y1 := polarPoint(cx, cy, inner, start)
+		x2, y2 := polarPoint(cx, cy, outer, start)
+		x3, y3 := polarPoint(cx, cy, outer, end)
+		x4, y4 := polarPoint(cx, cy, inner, end)
+		fmt.Fprintf(&b, `<path d="M %.1f %.1f L %.1f %.1f A %g %g 0 0 1 %.1f %.1f L %.1f %.1f A %g %g 0 0 0 %.1f %.1f Z" fill="%s" stroke="%s"><title>%s</title></path>`+"\n",
+			x1, y1, x2, y2, outer, outer, x3, y3, x4, y4, inner, inner, x1, y1, fill, axisColour, svgText(title))
+
+		middle := (inner + outer) / 2
+		writeFanLabel(&b, cx, cy, middle, start-span/2, middle*span, ringWidth, []string{name, lifespan})
+	}
+
+	legendY := cy + 10
+	fmt.Fprintf(&b, `<rect x="20" y="%.1f" width="160" height="10" fill="url(#fan-diff-scale)" stroke="%s"/>`+"\n", legendY-10, axisColour)
+	fmt.Fprintf(&b, `<text x="20" y="%.1f" %s>-%g years</text>`+"\n", legendY+12, chartFont, diffColourRangeYears)
+	fmt.Fprintf(&b, `<text x="180" y="%.1f" text-anchor="end" %s>+%g years</text>`+"\n", legendY+12, chartFont, diffColourRangeYears)
+	fmt.Fprintf(&b, `<rect x="%g" y="%.1f" width="10" height="10" fill="%s" stroke="%s"/>`+"\n", width-120, legendY-10, excludedColour, axisColour)
+	fmt.Fprintf(&b, `<text x="%g" y="%.1f" %s>Left out</text>`+"\n", width-104, legendY, chartFont)
+	b.WriteString("</svg>\n")
+	return b.String()
+}
+
+// writeFanChart writes the fan chart as a standalone SVG file.
+func writeFanChart(subject *gedcom.IndividualRecord, ancestors []longevity.AncestorDeath, exclusions []longevity.Exclusion, generations int, fileName string) error {
+	if !strings.HasSuffix(fileName, ".svg") {
+		fileName = fileName + ".svg"
+	}
+	svg := `<?xml version="1.0" encoding="UTF-8"?>` + "\n" + fanChartSvg(subject, ancestors, exclusions, generations)
+	return os.WriteFile(fileName, []byte(svg), 0o644)
+}
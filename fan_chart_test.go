@@ -0,0 +1,109 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"predict-death/longevity"
+)
+
+func TestFanSegments(t *testing.T) {
+	g := decodeTestTree(t, reportTestTree)
+	type placed struct {
+		xref                 string
+		generation, position int
+	}
+	var got []placed
+	for _, segment := range fanSegments(individualsByXref(g)["I1"], defaultFanChartGenerations) {
+		got = append(got, placed{segment.individual.Xref, segment.generation, segment.position})
+	}
+	// The mother's parents sit in the outer half of the second ring, fathers first.
+	want := []placed{{"I2", 1, 0}, {"I3", 1, 1}, {"I4", 2, 2}, {"I5", 2, 3}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestFormatLifespan(t *testing.T) {
+	tests := []struct {
+		born, died         int
+		hasBirth, hasDeath bool
+		want               string
+	}{
+		{1850, 1920, true, true, "1850–1920"},
+		{1850, 0, true, false, "b. 1850"},
+		{0, 1920, false, true, "d. 1920"},
+		{0, 0, false, false, ""},
+	}
+	for _, tt := range tests {
+		if got := formatLifespan(tt.born, tt.hasBirth, tt.died, tt.hasDeath); got != tt.want {
+			t.Errorf("got %q, want %q", got, tt.want)
+		}
+	}
+}
+
+func TestTruncateLabel(t *testing.T) {
+	tests := []struct {
+		text     string
+		maxChars int
+		want     string
+	}{
+		{"Ann Smith", 20, "Ann Smith"},
+		{"Ann Smith", 9, "Ann Smith"},
+		{"Ann Smith", 5, "Ann …"},
+		{"Zoë Brontë", 4, "Zoë…"},
+		{"Ann Smith", 1, ""},
+	}
+	for _, tt := range tests {
+		if got := truncateLabel(tt.text, tt.maxChars); got != tt.want {
+			t.Errorf("truncateLabel(%q, %d) = %q, want %q", tt.text, tt.maxChars, got, tt.want)
+		}
+	}
+}
+
+func TestFanChart(t *testing.T) {
+	g := decodeTestTree(t, reportTestTree)
+	subject := individualsByXref(g)["I1"]
+	ancestors := []longevity.AncestorDeath{
+		{Xref: "I3", Name: "Muddled Jones", Year: 1950, BirthYear: 1880, GenerationsRemoved: 1, Gender: "f", MedianAgeAtDeathDiffDays: 10 * 365},
+	}
+	exclusions := []longevity.Exclusion{
+		{Xref: "I2", Name: "Living Smith", GenerationsRemoved: 1, Reason: longevity.NoDeathDateExclusion},
+		{Xref: "I4", Name: "Early Jones", GenerationsRemoved: 2, Reason: longevity.NoStatsForYearExclusion},
+	}
+
+	svg := fanChartSvg(subject, ancestors, exclusions, defaultFanChartGenerations)
+	checkSvg(t, svg)
+	for _, want := range []string{
+		`fill="` + diffColour(10*365) + `" stroke="#444444"><title>Muddled Jones (1880–1950): +10 years 0 days from median age at death</title>`,
+		`fill="` + excludedColour + `" stroke="#444444"><title>Living Smith: left out (no death date)</title>`,
+		`fill="` + excludedColour + `" stroke="#444444"><title>Early Jones: left out (no stats for year)</title>`,
+		// Ancestors who were neither compared nor listed as left out stay white.
+		`fill="` + notShownColour + `" stroke="#444444"><title>Unknown Brown</title>`,
+		`>Early Jones</tspan><tspan x="0" dy="1.1em">1700–1760</tspan>`,
+		`>Living Smith</tspan><tspan x="0" dy="1.1em">b. 1900</tspan>`,
+	} {
+		if !strings.Contains(svg, want) {
+			t.Errorf("expected the fan chart to contain %q:\n%s", want, svg)
+		}
+	}
+
+	if got := strings.Count(fanChartSvg(subject, ancestors, exclusions, 1), "<path "); got != 3 {
+		t.Errorf("expected the subject and two parents with one generation, got %d segments", got)
+	}
+
+	path := filepath.Join(t.TempDir(), "fan")
+	if err := writeFanChart(subject, ancestors, exclusions, defaultFanChartGenerations, path); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	data, err := os.ReadFile(path + ".svg")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !strings.HasPrefix(string(data), `<?xml version="1.0" encoding="UTF-8"?>`) {
+		t.Errorf("expected a standalone SVG document, got %.60s", data)
+	}
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"html/template"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/iand/gedcom"
+	"predict-death/longevity"
+)
+
+// htmlReportTemplate lays out the report as a single file with its styles and charts inline, so it
+// can be emailed or opened straight from disk.
+var htmlReportTemplate = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>Longevity of the ancestors of {{.SubjectName}}</title>
+<style>
+body { font-family: sans-serif; margin: 2em auto; max-width: 1100px; color: #222; }
+h1 { font-size: 1.6em; }
+h2 { font-size: 1.2em; margin-top: 2em; }
+table { border-collapse: collapse; margin: 1em 0; font-size: 0.9em; }
+th, td { border: 1px solid #ccc; padding: 0.3em 0.6em; text-align: left; }
+th { background: #f3f3f3; }
+p.note { color: #555; font-size: 0.9em; }
+svg { max-width: 100%; height: auto; }
+</style>
+</head>
+<body>
+<h1>Longevity of the ancestors of {{.SubjectName}}</h1>
+<p>{{.AncestorCount}} direct ancestors compared, {{.ExcludedCount}} left out. Averages use {{.Weighting}} weighting.</p>
+
+<h2>Summary</h2>
+<table>
+<tr>{{range index .Summary 0}}<th>{{.}}</th>{{end}}</tr>
+{{range slice .Summary 1}}<tr>{{range .}}<td>{{.}}</td>{{end}}</tr>
+{{end}}</table>
+
+<h2>Age at death by year</h2>
+{{.ScatterChart}}
+<h2>Difference by generation</h2>
+{{.GenerationChart}}
+<h2>Pedigree</h2>
+<p class="note">Each ancestor is shaded by how their age at death compared with the median age at death, from red for those who died {{.ColourRangeYears}} or more years younger to blue for those who lived {{.ColourRangeYears}} or more years longer. Ancestors left out of the analysis are grey.</p>
+{{.FanChart}}
+
+<h2>Ancestors</h2>
+<table>
+<tr>{{range index .Ancestors 0}}<th>{{.}}</th>{{end}}</tr>
+{{range slice .Ancestors 1}}<tr>{{range .}}<td>{{.}}</td>{{end}}</tr>
+{{end}}</table>
+{{if .Exclusions}}
+<h2>Excluded ancestors</h2>
+<table>
+<tr><th>Xref</th><th>Name</th><th>Generation</th><th>Reason</th><th>Detail</th></tr>
+{{range .Exclusions}}<tr><td>@{{.Xref}}@</td><td>{{.Name}}</td><td>{{.GenerationsRemoved}}</td><td>{{.Reason}}</td><td>{{.Detail}}</td></tr>
+{{end}}</table>
+{{end}}
+</body>
+</html>
+`))
+
+type htmlReport struct {
+	SubjectName      string
+	Weighting        string
+	AncestorCount    int
+	ExcludedCount    int
+	Summary          [][]string
+	Ancestors        [][]string
+	Exclusions       []longevity.Exclusion
+	ScatterChart     template.HTML
+	GenerationChart  template.HTML
+	FanChart         template.HTML
+	ColourRangeYears float64
+}
+
+// ancestorTable returns the rows of the ancestor table in the HTML report, starting with the heading
+// row, nearest generation first.
+func ancestorTable(ancestors []longevity.AncestorDeath, stats StatSelection) [][]string {
+	sorted := append([]longevity.AncestorDeath(nil), ancestors...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sorted[i].GenerationsRemoved != sorted[j].GenerationsRemoved {
+			return sorted[i].GenerationsRemoved < sorted[j].GenerationsRemoved
+		}
+		return sorted[i].Xref < sorted[j].Xref
+	})
+
+	header := []string{"Name", "Born", "Died", "Generations removed", "Gender", "Country", "Age at death"}
+	for _, stat := range stats.diffStats() {
+		header = append(header, statLabel(stat)+" Diff")
+	}
+	if stats.includes(percentileStat) {
+		header = append(header, "Survival Percentile")
+	}
+	rows := [][]string{header}
+	for _, ancestor := range sorted {
+		row := []string{
+			ancestor.Name, strconv.Itoa(ancestor.BirthYear), strconv.Itoa(ancestor.Year), formatGenerations(ancestor.PathsToSubject()),
+			formatGender(ancestor), ancestor.Country, formatYearsAndDays(ancestor.AgeAtDeathDaysTotal),
+		}
+		for _, stat := range stats.diffStats() {
+			row = append(row, formatDiff(pickDiff(stat, ancestor.LifeExpectancyDiffDays, ancestor.MedianAgeAtDeathDiffDays, ancestor.ModalAgeAtDeathDiffDays)))
+		}
+		if stats.includes(percentileStat) {
+			row = append(row, formatPercentile(ancestor.SurvivalPercentile, ancestor.HasSurvivalPercentile))
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+func renderHtmlReport(w io.Writer, ancestors []longevity.AncestorDeath, exclusions []longevity.Exclusion, subject *gedcom.IndividualRecord, weighter longevity.Weighter, stats StatSelection) error {
+	return htmlReportTemplate.Execute(w, htmlReport{
+		SubjectName:      longevity.IndividualName(subject),
+		Weighting:        weighter.Name(),
+		AncestorCount:    len(ancestors),
+		ExcludedCount:    len(exclusions),
+		Summary:          summaryTable(longevity.Summarise(ancestors, weighter), stats),
+		Ancestors:        ancestorTable(ancestors, stats),
+		Exclusions:       exclusions,
+		ScatterChart:     template.HTML(scatterChartSvg(ancestors)),
+		GenerationChart:  template.HTML(generationChartSvg(ancestors, stats)),
+		FanChart:         template.HTML(fanChartSvg(subject, ancestors, exclusions, defaultFanChartGenerations)),
+		ColourRangeYears: diffColourRangeYears,
+	})
+}
+
+func writeHtmlReport(ancestors []longevity.AncestorDeath, exclusions []longevity.Exclusion, subject *gedcom.IndividualRecord, weighter longevity.Weighter, stats StatSelection, fileName string) error {
+	if !strings.HasSuffix(fileName, ".html") {
+		fileName = fileName + ".html"
+	}
+	file, err := os.Create(fileName)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	return renderHtmlReport(file, ancestors, exclusions, subject, weighter, stats)
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"predict-death/longevity"
+)
+
+func TestRenderHtmlReport(t *testing.T) {
+	g := decodeTestTree(t, reportTestTree)
+	subject := individualsByXref(g)["I1"]
+	ancestors := []longevity.AncestorDeath{
+		{Xref: "I3", Name: "Muddled Jones", Year: 1950, BirthYear: 1880, GenerationsRemoved: 1, Gender: "f", AgeAtDeathDaysTotal: 70 * 365, AgeAtDeathMinDays: 70 * 365, AgeAtDeathMaxDays: 70 * 365, MedianDeathAgeDays: 65 * 365, MedianAgeAtDeathDiffDays: 5 * 365},
+		{Xref: "I2", Name: "<script>alert(1)</script>", Year: 1960, BirthYear: 1900, GenerationsRemoved: 1, Gender: "m", AgeAtDeathDaysTotal: 60 * 365, AgeAtDeathMinDays: 60 * 365, AgeAtDeathMaxDays: 60 * 365, MedianDeathAgeDays: 62 * 365, MedianAgeAtDeathDiffDays: -2 * 365},
+	}
+	exclusions := []longevity.Exclusion{{Xref: "I4", Name: "Early Jones", GenerationsRemoved: 2, Reason: longevity.NoStatsForYearExclusion, Detail: "no GBRTENW stats for men died in 1760"}}
+	stats, _ := parseStatSelection("median,percentile")
+
+	var got bytes.Buffer
+	if err := renderHtmlReport(&got, ancestors, exclusions, subject, longevity.EqualWeighter{}, stats); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	report := got.String()
+
+	for _, want := range []string{
+		"<title>Longevity of the ancestors of Subject Smith</title>",
+		"2 direct ancestors compared, 1 left out. Averages use equal weighting.",
+		"<th>Stat</th><th>Male</th><th>Female</th><th>Unknown</th><th>Overall</th>",
+		"<td>Difference from Median Death Age</td><td>-2 years 0 days</td><td>5 years 0 days</td><td>n/a</td><td>1 years 182 days</td>",
+		"<td>Mean Survival Percentile</td>",
+		"<th>Median Death Age Diff</th><th>Survival Percentile</th>",
+		"&lt;script&gt;alert(1)&lt;/script&gt;",
+		"<td>@I4@</td><td>Early Jones</td><td>2</td><td>no stats for year</td>",
+	} {
+		if !strings.Contains(report, want) {
+			t.Errorf("expected the report to contain %q", want)
+		}
+	}
+	if strings.Contains(report, "Modal Age at Death") || strings.Contains(report, "<script>") {
+		t.Errorf("report contains unselected statistics or unescaped names:\n%s", report)
+	}
+	if count := strings.Count(report, "<svg "); count != 3 {
+		t.Errorf("expected 3 charts, got %d", count)
+	}
+}
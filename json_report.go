@@ -0,0 +1,265 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/iand/gedcom"
+	"predict-death/longevity"
+)
+
+// Output formats for a single subject.
+const (
+	textFormat = "text"
+	jsonFormat = "json"
+)
+
+var outputFormats = []string{textFormat, jsonFormat}
+
+func checkOutputFormat(format string) error {
+	for _, known := range outputFormats {
+		if format == known {
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown format '%s', expected one of %v", format, outputFormats)
+}
+
+// jsonSchemaVersion is bumped whenever a field in the JSON report is renamed, removed or changes
+// meaning. Adding a field doesn't change it.
+const jsonSchemaVersion = 1
+
+// JsonReport is the document written by --format json. Its fields are documented in the README and
+// guarded by TestJsonReportSchema, so change them with care.
+type JsonReport struct {
+	SchemaVersion int             `json:"schema_version"`
+	Metadata      JsonMetadata    `json:"metadata"`
+	Subject       JsonIndividual  `json:"subject"`
+	Summary       JsonSummary     `json:"summary"`
+	Ancestors     []JsonAncestor  `json:"ancestors"`
+	Exclusions    []JsonExclusion `json:"exclusions"`
+}
+
+// JsonMetadata describes how the results were worked out.
+type JsonMetadata struct {
+	Weighting      string   `json:"weighting"`
+	Comparison     string   `json:"comparison"`
+	Conditioning   string   `json:"conditioning"`
+	AdultAge       int      `json:"adult_age"`
+	YearPolicy     string   `json:"year_policy"`
+	UnknownSex     string   `json:"unknown_sex"`
+	DefaultCountry string   `json:"default_country"`
+	Countries      []string `json:"countries"`
+	Datasets       []string `json:"datasets"`
+}
+
+type JsonIndividual struct {
+	Xref      string `json:"xref"`
+	Name      string `json:"name"`
+	BirthYear *int   `json:"birth_year"`
+}
+
+// JsonSummary holds the weighted averages for each sex. Unknown covers ancestors compared with
+// statistics for both sexes.
+type JsonSummary struct {
+	Male    JsonSummaryGroup `json:"male"`
+	Female  JsonSummaryGroup `json:"female"`
+	Unknown JsonSummaryGroup `json:"unknown"`
+	Overall JsonSummaryGroup `json:"overall"`
+}
+
+// JsonSummaryGroup holds the weighted average diffs for a group of ancestors in days, along with
+// how far below and above them the true values could lie given the uncertainty in the dates. The
+// diffs are zero and the percentile null when the group is empty.
+type JsonSummaryGroup struct {
+	Ancestors                int      `json:"ancestors"`
+	LifeExpectancyDiffDays   int      `json:"life_expectancy_diff_days"`
+	MedianAgeAtDeathDiffDays int      `json:"median_age_at_death_diff_days"`
+	ModalAgeAtDeathDiffDays  int      `json:"modal_age_at_death_diff_days"`
+	DiffLowerDays            int      `json:"diff_lower_days"`
+	DiffUpperDays            int      `json:"diff_upper_days"`
+	MeanSurvivalPercentile   *float64 `json:"mean_survival_percentile"`
+}
+
+type JsonPaths struct {
+	GenerationsRemoved int `json:"generations_removed"`
+	Count              int `json:"count"`
+}
+
+// JsonExclusion is a longevity.Exclusion, as written to the report and by --exclusions-json.
+type JsonExclusion struct {
+	Xref               string `json:"xref"`
+	Name               string `json:"name"`
+	GenerationsRemoved int    `json:"generations_removed"`
+	Reason             string `json:"reason"`
+	Detail             string `json:"detail"`
+}
+
+// newJsonExclusions converts exclusions for the JSON output, giving an empty list rather than null
+// when there are none.
+func newJsonExclusions(exclusions []longevity.Exclusion) []JsonExclusion {
+	jsonExclusions := []JsonExclusion{}
+	for _, exclusion := range exclusions {
+		jsonExclusions = append(jsonExclusions, JsonExclusion{
+			Xref:               exclusion.Xref,
+			Name:               exclusion.Name,
+			GenerationsRemoved: exclusion.GenerationsRemoved,
+			Reason:             exclusion.Reason,
+			Detail:             exclusion.Detail,
+		})
+	}
+	return jsonExclusions
+}
+
+// JsonAncestor is a longevity.AncestorDeath along with the weight it was given. All ages and diffs
+// are in days.
+type JsonAncestor struct {
+	Xref                     string      `json:"xref"`
+	Name                     string      `json:"name"`
+	BirthYear                int         `json:"birth_year"`
+	DeathYear                int         `json:"death_year"`
+	GenerationsRemoved       int         `json:"generations_removed"`
+	Paths                    []JsonPaths `json:"paths"`
+	Sex                      string      `json:"sex"`
+	SexInferred              bool        `json:"sex_inferred"`
+	Country                  string      `json:"country"`
+	StatsYear                int         `json:"stats_year"`
+	StatsMatch               string      `json:"stats_match"`
+	AgeAtDeathDays           int         `json:"age_at_death_days"`
+	AgeAtDeathMinDays        int         `json:"age_at_death_min_days"`
+	AgeAtDeathMaxDays        int         `json:"age_at_death_max_days"`
+	LifeExpectancyDays       int         `json:"life_expectancy_days"`
+	LifeExpectancyFromAge    int         `json:"life_expectancy_from_age"`
+	LifeExpectancyDiffDays   int         `json:"life_expectancy_diff_days"`
+	MedianAgeAtDeathDays     int         `json:"median_age_at_death_days"`
+	MedianAgeAtDeathDiffDays int         `json:"median_age_at_death_diff_days"`
+	ModalAgeAtDeathDays      int         `json:"modal_age_at_death_days"`
+	ModalAgeAtDeathDiffDays  int         `json:"modal_age_at_death_diff_days"`
+	SurvivalPercentile       *float64    `json:"survival_percentile"`
+	BirthSource              string      `json:"birth_source"`
+	DeathSource              string      `json:"death_source"`
+	InbreedingCoefficient    float64     `json:"inbreeding_coefficient"`
+	KinshipCoefficient       float64     `json:"kinship_coefficient"`
+	Weight                   float64     `json:"weight"`
+}
+
+func optionalPercentile(percentile float64, ok bool) *float64 {
+	if !ok {
+		return nil
+	}
+	return &percentile
+}
+
+func jsonSummaryGroup(group longevity.SummaryGroup) JsonSummaryGroup {
+	return JsonSummaryGroup{
+		Ancestors:                group.Ancestors,
+		LifeExpectancyDiffDays:   group.LifeExpectancyDiffDays,
+		MedianAgeAtDeathDiffDays: group.MedianAgeAtDeathDiffDays,
+		ModalAgeAtDeathDiffDays:  group.ModalAgeAtDeathDiffDays,
+		DiffLowerDays:            group.DiffLowerDays,
+		DiffUpperDays:            group.DiffUpperDays,
+		MeanSurvivalPercentile:   optionalPercentile(group.SurvivalPercentile, group.HasSurvivalPercentile),
+	}
+}
+
+func jsonPaths(paths longevity.AncestorPaths) []JsonPaths {
+	var generations []int
+	for generation := range paths {
+		generations = append(generations, generation)
+	}
+	sort.Ints(generations)
+
+	result := make([]JsonPaths, len(generations))
+	for i, generation := range generations {
+		result[i] = JsonPaths{GenerationsRemoved: generation, Count: paths[generation]}
+	}
+	return result
+}
+
+// newJsonMetadata describes how the analysis was run, from the options the analyzer settled on.
+func newJsonMetadata(options longevity.Options, registry longevity.ReferenceRegistry, datasets []string) JsonMetadata {
+	return JsonMetadata{
+		Comparison:     options.Comparison,
+		Conditioning:   options.Conditioning,
+		AdultAge:       options.AdultAge,
+		YearPolicy:     options.YearPolicy,
+		UnknownSex:     options.UnknownSex,
+		DefaultCountry: options.DefaultCountry,
+		Countries:      registry.Codes(),
+		Datasets:       datasets,
+	}
+}
+
+// newJsonReport builds the JSON report for a subject. Ancestors are listed nearest generation first
+// and then by xref, so the same tree always gives the same document.
+func newJsonReport(ancestors []longevity.AncestorDeath, exclusions []longevity.Exclusion, subject *gedcom.IndividualRecord, weighter longevity.Weighter, metadata JsonMetadata) JsonReport {
+	metadata.Weighting = weighter.Name()
+	summary := longevity.Summarise(ancestors, weighter)
+	report := JsonReport{
+		SchemaVersion: jsonSchemaVersion,
+		Metadata:      metadata,
+		Subject:       JsonIndividual{Xref: subject.Xref, Name: longevity.IndividualName(subject)},
+		Summary: JsonSummary{
+			Male:    jsonSummaryGroup(summary.Male),
+			Female:  jsonSummaryGroup(summary.Female),
+			Unknown: jsonSummaryGroup(summary.Unknown),
+			Overall: jsonSummaryGroup(summary.Overall),
+		},
+		Ancestors:  []JsonAncestor{},
+		Exclusions: newJsonExclusions(exclusions),
+	}
+	if year, ok := longevity.BirthYear(subject); ok {
+		report.Subject.BirthYear = &year
+	}
+
+	weights := weighter.Weights(ancestors)
+	for i, ancestor := range ancestors {
+		report.Ancestors = append(report.Ancestors, JsonAncestor{
+			Xref:                     ancestor.Xref,
+			Name:                     ancestor.Name,
+			BirthYear:                ancestor.BirthYear,
+			DeathYear:                ancestor.Year,
+			GenerationsRemoved:       ancestor.GenerationsRemoved,
+			Paths:                    jsonPaths(ancestor.PathsToSubject()),
+			Sex:                      ancestor.Gender,
+			SexInferred:              ancestor.SexInferred,
+			Country:                  ancestor.Country,
+			StatsYear:                ancestor.StatsYear,
+			StatsMatch:               ancestor.StatsMatch,
+			AgeAtDeathDays:           ancestor.AgeAtDeathDaysTotal,
+			AgeAtDeathMinDays:        ancestor.AgeAtDeathMinDays,
+			AgeAtDeathMaxDays:        ancestor.AgeAtDeathMaxDays,
+			LifeExpectancyDays:       ancestor.LifeExpectancyDays,
+			LifeExpectancyFromAge:    ancestor.ConditioningAge,
+			LifeExpectancyDiffDays:   ancestor.LifeExpectancyDiffDays,
+			MedianAgeAtDeathDays:     ancestor.MedianDeathAgeDays,
+			MedianAgeAtDeathDiffDays: ancestor.MedianAgeAtDeathDiffDays,
+			ModalAgeAtDeathDays:      ancestor.ModalDeathAgeDays,
+			ModalAgeAtDeathDiffDays:  ancestor.ModalAgeAtDeathDiffDays,
+			SurvivalPercentile:       optionalPercentile(ancestor.SurvivalPercentile, ancestor.HasSurvivalPercentile),
+			BirthSource:              ancestor.BirthSource,
+			DeathSource:              ancestor.DeathSource,
+			InbreedingCoefficient:    ancestor.InbreedingCoefficient,
+			KinshipCoefficient:       ancestor.KinshipCoefficient,
+			Weight:                   weights[i],
+		})
+	}
+	sort.SliceStable(report.Ancestors, func(i, j int) bool {
+		if report.Ancestors[i].GenerationsRemoved != report.Ancestors[j].GenerationsRemoved {
+			return report.Ancestors[i].GenerationsRemoved < report.Ancestors[j].GenerationsRemoved
+		}
+		return report.Ancestors[i].Xref < report.Ancestors[j].Xref
+	})
+	return report
+}
+
+func writeJsonReport(w io.Writer, report JsonReport) error {
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
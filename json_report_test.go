@@ -0,0 +1,223 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/iand/gedcom"
+	"predict-death/longevity"
+)
+
+// TestJsonReportSchema guards the documented JSON schema: renaming, removing or retyping a field
+// fails it, and should come with a bump of jsonSchemaVersion and a README update.
+func TestJsonReportSchema(t *testing.T) {
+	subject := &gedcom.IndividualRecord{
+		Xref:  "I1",
+		Name:  []*gedcom.NameRecord{{Name: "Subject /Smith/"}},
+		Event: []*gedcom.EventRecord{{Tag: "BIRT", Date: "1980"}},
+	}
+	ancestors := []longevity.AncestorDeath{
+		{
+			Xref: "I3", Name: "Mother /Smith/", Year: 1990, BirthYear: 1920, GenerationsRemoved: 1, Paths: longevity.AncestorPaths{1: 1},
+			Gender: "f", Country: longevity.DefaultCountry, StatsYear: 1990, StatsMatch: longevity.ExactYearPolicy,
+			AgeAtDeathDaysTotal: 25550, AgeAtDeathMinDays: 25550, AgeAtDeathMaxDays: 25550,
+			LifeExpectancyDays: 25000, LifeExpectancyDiffDays: 550, ConditioningAge: 15,
+			MedianDeathAgeDays: 26000, MedianAgeAtDeathDiffDays: -450, ModalDeathAgeDays: 27000, ModalAgeAtDeathDiffDays: -1450,
+			SurvivalPercentile: 40, HasSurvivalPercentile: true, BirthSource: "BIRT", DeathSource: "DEAT",
+		},
+		{
+			Xref: "I2", Name: "Father /Smith/", Year: 1985, BirthYear: 1915, GenerationsRemoved: 1, Paths: longevity.AncestorPaths{1: 1},
+			Gender: "m", SexInferred: true, Country: longevity.DefaultCountry, StatsYear: 1985, StatsMatch: longevity.ExactYearPolicy,
+			AgeAtDeathDaysTotal: 25550, AgeAtDeathMinDays: 25185, AgeAtDeathMaxDays: 25915,
+			LifeExpectancyDays: 24000, LifeExpectancyDiffDays: 1550,
+			MedianDeathAgeDays: 25000, MedianAgeAtDeathDiffDays: 550, ModalDeathAgeDays: 26000, ModalAgeAtDeathDiffDays: -450,
+			BirthSource: "BIRT", DeathSource: "BURI",
+		},
+	}
+	exclusions := []longevity.Exclusion{{Xref: "I4", Name: "Grandfather /Smith/", GenerationsRemoved: 2, Reason: longevity.NoDeathDateExclusion, Detail: "no dated DEAT, BURI event"}}
+
+	report := newJsonReport(ancestors, exclusions, subject, longevity.EqualWeighter{}, JsonMetadata{
+		Comparison:     longevity.PeriodComparison,
+		Conditioning:   longevity.AdulthoodConditioning,
+		AdultAge:       15,
+		YearPolicy:     longevity.ExactYearPolicy,
+		UnknownSex:     longevity.InferUnknownSex,
+		DefaultCountry: longevity.DefaultCountry,
+		Countries:      []string{longevity.DefaultCountry},
+		Datasets:       []string{"male_death_stats.csv (embedded)", "female_death_stats.csv (embedded)"},
+	})
+	var got bytes.Buffer
+	if err := writeJsonReport(&got, report); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	want := `{
+  "schema_version": 1,
+  "metadata": {
+    "weighting": "equal",
+    "comparison": "period",
+    "conditioning": "adulthood",
+    "adult_age": 15,
+    "year_policy": "exact",
+    "unknown_sex": "infer",
+    "default_country": "GBRTENW",
+    "countries": [
+      "GBRTENW"
+    ],
+    "datasets": [
+      "male_death_stats.csv (embedded)",
+      "female_death_stats.csv (embedded)"
+    ]
+  },
+  "subject": {
+    "xref": "I1",
+    "name": "Subject Smith",
+    "birth_year": 1980
+  },
+  "summary": {
+    "male": {
+      "ancestors": 1,
+      "life_expectancy_diff_days": 1550,
+      "median_age_at_death_diff_days": 550,
+      "modal_age_at_death_diff_days": -450,
+      "diff_lower_days": -365,
+      "diff_upper_days": 365,
+      "mean_survival_percentile": null
+    },
+    "female": {
+      "ancestors": 1,
+      "life_expectancy_diff_days": 550,
+      "median_age_at_death_diff_days": -450,
+      "modal_age_at_death_diff_days": -1450,
+      "diff_lower_days": 0,
+      "diff_upper_days": 0,
+      "mean_survival_percentile": 40
+    },
+    "unknown": {
+      "ancestors": 0,
+      "life_expectancy_diff_days": 0,
+      "median_age_at_death_diff_days": 0,
+      "modal_age_at_death_diff_days": 0,
+      "diff_lower_days": 0,
+      "diff_upper_days": 0,
+      "mean_survival_percentile": null
+    },
+    "overall": {
+      "ancestors": 2,
+      "life_expectancy_diff_days": 1050,
+      "median_age_at_death_diff_days": 50,
+      "modal_age_at_death_diff_days": -950,
+      "diff_lower_days": -182,
+      "diff_upper_days": 182,
+      "mean_survival_percentile": 40
+    }
+  },
+  "ancestors": [
+    {
+      "xref": "I2",
+      "name": "Father /Smith/",
+      "birth_year": 1915,
+      "death_year": 1985,
+      "generations_removed": 1,
+      "paths": [
+        {
+          "generations_removed": 1,
+          "count": 1
+        }
+      ],
+      "sex": "m",
+      "sex_inferred": true,
+      "country": "GBRTENW",
+      "stats_year": 1985,
+      "stats_match": "exact",
+      "age_at_death_days": 25550,
+      "age_at_death_min_days": 25185,
+      "age_at_death_max_days": 25915,
+      "life_expectancy_days": 24000,
+      "life_expectancy_from_age": 0,
+      "life_expectancy_diff_days": 1550,
+      "median_age_at_death_days": 25000,
+      "median_age_at_death_diff_days": 550,
+      "modal_age_at_death_days": 26000,
+      "modal_age_at_death_diff_days": -450,
+      "survival_percentile": null,
+      "birth_source": "BIRT",
+      "death_source": "BURI",
+      "inbreeding_coefficient": 0,
+      "kinship_coefficient": 0,
+      "weight": 1
+    },
+    {
+      "xref": "I3",
+      "name": "Mother /Smith/",
+      "birth_year": 1920,
+      "death_year": 1990,
+      "generations_removed": 1,
+      "paths": [
+        {
+          "generations_removed": 1,
+          "count": 1
+        }
+      ],
+      "sex": "f",
+      "sex_inferred": false,
+      "country": "GBRTENW",
+      "stats_year": 1990,
+      "stats_match": "exact",
+      "age_at_death_days": 25550,
+      "age_at_death_min_days": 25550,
+      "age_at_death_max_days": 25550,
+      "life_expectancy_days": 25000,
+      "life_expectancy_from_age": 15,
+      "life_expectancy_diff_days": 550,
+      "median_age_at_death_days": 26000,
+      "median_age_at_death_diff_days": -450,
+      "modal_age_at_death_days": 27000,
+      "modal_age_at_death_diff_days": -1450,
+      "survival_percentile": 40,
+      "birth_source": "BIRT",
+      "death_source": "DEAT",
+      "inbreeding_coefficient": 0,
+      "kinship_coefficient": 0,
+      "weight": 1
+    }
+  ],
+  "exclusions": [
+    {
+      "xref": "I4",
+      "name": "Grandfather /Smith/",
+      "generations_removed": 2,
+      "reason": "no death date",
+      "detail": "no dated DEAT, BURI event"
+    }
+  ]
+}
+`
+	if got.String() != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got.String(), want)
+	}
+}
+
+func TestJsonReportEmpty(t *testing.T) {
+	subject := &gedcom.IndividualRecord{Xref: "I1"}
+	var got bytes.Buffer
+	if err := writeJsonReport(&got, newJsonReport(nil, nil, subject, longevity.EqualWeighter{}, JsonMetadata{})); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	var decoded map[string]interface{}
+	if err := json.Unmarshal(got.Bytes(), &decoded); err != nil {
+		t.Fatalf("invalid JSON: %s", err)
+	}
+	// Empty lists are written as [] rather than null, and a subject without a birth year has a null
+	// one.
+	for _, key := range []string{"ancestors", "exclusions"} {
+		if list, ok := decoded[key].([]interface{}); !ok || len(list) != 0 {
+			t.Errorf("expected %s to be an empty list, got %v", key, decoded[key])
+		}
+	}
+	if subject := decoded["subject"].(map[string]interface{}); subject["birth_year"] != nil {
+		t.Errorf("expected a null birth year, got %v", subject["birth_year"])
+	}
+}
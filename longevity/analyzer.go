@@ -0,0 +1,197 @@
+package longevity
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/iand/gedcom"
+)
+
+// Options configures an Analyzer. Zero values keep the strict behaviour the tool started with: dates
+// only from birth and death events, life expectancy from birth, period comparison, exact years only
+// and ancestors of unknown sex left out. DefaultOptions returns the defaults the command line uses,
+// which still take dates only from birth and death events.
+type Options struct {
+	// Subject picks the individual whose ancestors are analysed by Analyze, as an xref, REFN or UID,
+	// or name and birth year. Empty picks the first individual in the tree.
+	Subject                string
+	BirthFallbacks         []EventFallback
+	DeathFallbacks         []EventFallback
+	Weighting              string
+	WeightingHalfLifeYears float64
+	ImputedDateDiscount    float64
+	Conditioning           string
+	AdultAge               int
+	Comparison             string
+	DefaultCountry         string
+	YearPolicy             string
+	UnknownSex             string
+}
+
+// DefaultOptions returns the options the command line uses unless told otherwise.
+func DefaultOptions() Options {
+	return Options{
+		Weighting:              GenerationalWeighting,
+		WeightingHalfLifeYears: DefaultWeightingHalfLifeYears,
+		ImputedDateDiscount:    DefaultImputedDateDiscount,
+		Conditioning:           AdulthoodConditioning,
+		AdultAge:               DefaultAdultAge,
+		Comparison:             PeriodComparison,
+		DefaultCountry:         DefaultCountry,
+		YearPolicy:             ExactYearPolicy,
+		UnknownSex:             InferUnknownSex,
+	}
+}
+
+// DefaultRegistry returns the ONS statistics for England and Wales built into the package, under
+// DefaultCountry.
+func DefaultRegistry() (ReferenceRegistry, error) {
+	var reference ReferenceData
+	var err error
+	reference.MaleDeathStats, err = LoadDeathStats("", "", MaleDeathStatsFileName)
+	if err != nil {
+		return nil, err
+	}
+	reference.FemaleDeathStats, err = LoadDeathStats("", "", FemaleDeathStatsFileName)
+	if err != nil {
+		return nil, err
+	}
+	return ReferenceRegistry{DefaultCountry: reference}, nil
+}
+
+// Analyzer compares the ages at death of a subject's ancestors with the statistics in a registry.
+// It holds no state between analyses, so one Analyzer can be reused for any number of subjects and
+// trees.
+type Analyzer struct {
+	registry ReferenceRegistry
+	options  Options
+}
+
+// NewAnalyzer checks the options against each other and the registry. Options left empty keep their
+// zero-value behaviour.
+func NewAnalyzer(registry ReferenceRegistry, options Options) (*Analyzer, error) {
+	checks := []struct {
+		value string
+		check func(string) error
+	}{
+		{options.Weighting, checkWeighterName},
+		{options.Conditioning, checkConditioningMode},
+		{options.Comparison, checkComparisonMode},
+		{options.YearPolicy, checkYearPolicy},
+		{options.UnknownSex, checkUnknownSexPolicy},
+	}
+	for _, c := range checks {
+		if c.value == "" {
+			continue
+		}
+		if err := c.check(c.value); err != nil {
+			return nil, err
+		}
+	}
+
+	if options.Weighting == "" {
+		options.Weighting = GenerationalWeighting
+	}
+	options.DefaultCountry = strings.ToUpper(options.DefaultCountry)
+	if options.DefaultCountry == "" {
+		options.DefaultCountry = DefaultCountry
+	}
+	if _, ok := registry[options.DefaultCountry]; !ok {
+		return nil, fmt.Errorf("no stats for default country %s, expected one of %v", options.DefaultCountry, registry.Codes())
+	}
+	if options.Comparison == CohortComparison && !registry.HasCohortLifeTables() {
+		return nil, fmt.Errorf("cohort comparison requires cohort life tables")
+	}
+	return &Analyzer{registry: registry, options: options}, nil
+}
+
+// Options returns the options the analyzer was created with, with the weighting and default country
+// normalised.
+func (a *Analyzer) Options() Options {
+	return a.options
+}
+
+// Result is the analysis of one subject: every ancestor who could be compared with the statistics,
+// every one who couldn't and why, and the weighted averages of the comparisons.
+type Result struct {
+	Subject    *gedcom.IndividualRecord
+	Weighter   Weighter
+	Ancestors  []AncestorDeath
+	Exclusions []Exclusion
+	Summary    Summary
+}
+
+// Summary holds the weighted averages for the subject's male, female and unknown-sex ancestors and
+// all of them together.
+type Summary struct {
+	Male    SummaryGroup
+	Female  SummaryGroup
+	Unknown SummaryGroup
+	Overall SummaryGroup
+}
+
+// SummaryGroup is the weighted average of a group of ancestors' diffs, along with how far below and
+// above them the true values could lie given the uncertainty in their dates.
+type SummaryGroup struct {
+	Ancestors                int
+	LifeExpectancyDiffDays   int
+	MedianAgeAtDeathDiffDays int
+	ModalAgeAtDeathDiffDays  int
+	DiffLowerDays            int
+	DiffUpperDays            int
+	SurvivalPercentile       float64
+	HasSurvivalPercentile    bool
+}
+
+func summariseGroup(ancestors []AncestorDeath, gender string, weighter Weighter) SummaryGroup {
+	group := SummaryGroup{Ancestors: CountAncestors(ancestors, gender)}
+	group.LifeExpectancyDiffDays, group.MedianAgeAtDeathDiffDays, group.ModalAgeAtDeathDiffDays = CalculateWeightedAverages(ancestors, gender, weighter)
+	group.DiffLowerDays, group.DiffUpperDays = CalculateWeightedUncertainty(ancestors, gender, weighter)
+	group.SurvivalPercentile, group.HasSurvivalPercentile = CalculateWeightedPercentile(ancestors, gender, weighter)
+	return group
+}
+
+// Summarise returns the weighted averages of the ancestors' diffs by sex.
+func Summarise(ancestors []AncestorDeath, weighter Weighter) Summary {
+	return Summary{
+		Male:    summariseGroup(ancestors, "m", weighter),
+		Female:  summariseGroup(ancestors, "f", weighter),
+		Unknown: summariseGroup(ancestors, UnknownSex, weighter),
+		Overall: summariseGroup(ancestors, "", weighter),
+	}
+}
+
+// Analyze finds the subject chosen by the options in the tree and analyses their ancestors.
+func (a *Analyzer) Analyze(tree *gedcom.Gedcom) (Result, error) {
+	subject, err := FindSubject(tree, a.options.Subject)
+	if err != nil {
+		return Result{}, err
+	}
+	return a.AnalyzeSubject(subject)
+}
+
+// AnalyzeSubject analyses the ancestors of the given subject.
+func (a *Analyzer) AnalyzeSubject(subject *gedcom.IndividualRecord) (Result, error) {
+	ancestors, err := GetAncestors(subject)
+	if err != nil {
+		return Result{}, err
+	}
+	weighter, err := NewWeighter(a.options.Weighting, subject, a.options)
+	if err != nil {
+		return Result{}, err
+	}
+	ancestorDeaths, exclusions := getDeathStatsForAncestors(subject, ancestors, a.registry, a.options)
+	return Result{
+		Subject:    subject,
+		Weighter:   weighter,
+		Ancestors:  ancestorDeaths,
+		Exclusions: exclusions,
+		Summary:    Summarise(ancestorDeaths, weighter),
+	}, nil
+}
+
+// Weighters returns every weighting strategy that can be used for the subject, for comparing the
+// results they give.
+func (a *Analyzer) Weighters(subject *gedcom.IndividualRecord) []Weighter {
+	return NewWeighters(subject, a.options)
+}
@@ -0,0 +1,149 @@
+package longevity_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/iand/gedcom"
+	"predict-death/longevity"
+)
+
+const analyzerTestTree = `0 HEAD
+0 @I1@ INDI
+1 NAME Ann /Child/
+1 SEX F
+1 BIRT
+2 DATE 1960
+1 FAMC @F1@
+0 @I2@ INDI
+1 NAME Tom /Father/
+1 SEX M
+1 BIRT
+2 DATE 1 JAN 1900
+1 DEAT
+2 DATE 1 JAN 1970
+1 FAMS @F1@
+0 @I3@ INDI
+1 NAME Sue /Mother/
+1 SEX F
+1 BIRT
+2 DATE 1 JAN 1905
+1 BURI
+2 DATE 5 JAN 1980
+1 FAMS @F1@
+0 @F1@ FAM
+1 HUSB @I2@
+1 WIFE @I3@
+1 CHIL @I1@
+0 TRLR
+`
+
+func TestAnalyze(t *testing.T) {
+	tree, err := gedcom.NewDecoder(strings.NewReader(analyzerTestTree)).Decode()
+	if err != nil {
+		t.Fatalf("unexpected error decoding tree: %s", err)
+	}
+	registry, err := longevity.DefaultRegistry()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	options := longevity.DefaultOptions()
+	options.Subject = "Ann Child 1960"
+	options.Weighting = longevity.EqualWeighting
+	options.DeathFallbacks, err = longevity.ParseFallbacks(longevity.TypicalDeathFallbacks)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	analyzer, err := longevity.NewAnalyzer(registry, options)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	result, err := analyzer.Analyze(tree)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if result.Subject.Xref != "I1" || result.Weighter.Name() != longevity.EqualWeighting {
+		t.Errorf("expected I1 with equal weighting, got %s with %s", result.Subject.Xref, result.Weighter.Name())
+	}
+	if len(result.Ancestors) != 2 || len(result.Exclusions) != 0 {
+		t.Fatalf("expected 2 ancestors and no exclusions, got %v and %v", result.Ancestors, result.Exclusions)
+	}
+	if result.Summary.Male.Ancestors != 1 || result.Summary.Female.Ancestors != 1 || result.Summary.Overall.Ancestors != 2 {
+		t.Errorf("expected one ancestor of each sex, got %+v", result.Summary)
+	}
+	lifeExpectancy, median, modal := longevity.CalculateWeightedAverages(result.Ancestors, "", result.Weighter)
+	overall := result.Summary.Overall
+	if overall.LifeExpectancyDiffDays != lifeExpectancy || overall.MedianAgeAtDeathDiffDays != median || overall.ModalAgeAtDeathDiffDays != modal {
+		t.Errorf("expected the overall summary to match the weighted averages, got %+v", overall)
+	}
+	for _, ancestor := range result.Ancestors {
+		// The mother's death date is imputed from her burial with the typical fallbacks.
+		if ancestor.Xref == "I3" && ancestor.DeathSource != "BURI" {
+			t.Errorf("expected the mother's death date from BURI, got %s", ancestor.DeathSource)
+		}
+	}
+}
+
+func TestDefaultOptionsLeaveFallbacksOff(t *testing.T) {
+	tree, err := gedcom.NewDecoder(strings.NewReader(analyzerTestTree)).Decode()
+	if err != nil {
+		t.Fatalf("unexpected error decoding tree: %s", err)
+	}
+	registry, err := longevity.DefaultRegistry()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	options := longevity.DefaultOptions()
+	options.Subject = "Ann Child 1960"
+	analyzer, err := longevity.NewAnalyzer(registry, options)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	result, err := analyzer.Analyze(tree)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	// Without fallbacks the mother, who only has a burial, is left out as she always was.
+	if len(result.Ancestors) != 1 || len(result.Exclusions) != 1 || result.Exclusions[0].Xref != "I3" {
+		t.Errorf("expected the mother to be excluded, got %v and %v", result.Ancestors, result.Exclusions)
+	}
+}
+
+func TestNewAnalyzerErrors(t *testing.T) {
+	registry, err := longevity.DefaultRegistry()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	tests := []struct {
+		name    string
+		options longevity.Options
+		want    string
+	}{
+		{name: "weighting", options: longevity.Options{Weighting: "random"}, want: "unknown weighting 'random'"},
+		{name: "year policy", options: longevity.Options{YearPolicy: "guess"}, want: "unknown year policy 'guess'"},
+		{name: "default country", options: longevity.Options{DefaultCountry: "fra"}, want: "no stats for default country FRA"},
+		{name: "cohort", options: longevity.Options{Comparison: longevity.CohortComparison}, want: "cohort comparison requires cohort life tables"},
+	}
+	for _, test := range tests {
+		if _, err := longevity.NewAnalyzer(registry, test.options); err == nil || !strings.Contains(err.Error(), test.want) {
+			t.Errorf("%s: expected an error containing %q, got %v", test.name, test.want, err)
+		}
+	}
+
+	analyzer, err := longevity.NewAnalyzer(registry, longevity.Options{DefaultCountry: "gbrtenw"})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if got := analyzer.Options().DefaultCountry; got != longevity.DefaultCountry {
+		t.Errorf("expected the default country to be normalised to %s, got %s", longevity.DefaultCountry, got)
+	}
+
+	// Left empty, the default country falls back to DefaultCountry, which must have stats too.
+	hmdOnly := longevity.ReferenceRegistry{"USA": registry[longevity.DefaultCountry]}
+	if _, err := longevity.NewAnalyzer(hmdOnly, longevity.Options{}); err == nil || !strings.Contains(err.Error(), "no stats for default country GBRTENW") {
+		t.Errorf("expected an error for a registry without the fallback country, got %v", err)
+	}
+}
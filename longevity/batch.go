@@ -0,0 +1,150 @@
+package longevity
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/iand/gedcom"
+)
+
+// BatchSummary is the outcome of analysing one subject in a batch, reduced to the weighted averages.
+// A subject who couldn't be analysed, e.g. for want of a birth date under calendar-decay weighting,
+// has the reason in Error and no statistics.
+type BatchSummary struct {
+	Subject                         *gedcom.IndividualRecord
+	Weighting                       string
+	Error                           string
+	AncestorCount                   int
+	MaleAncestorCount               int
+	FemaleAncestorCount             int
+	ExcludedCount                   int
+	MaleLifeExpectancyDiffDays      int
+	MaleMedianAgeAtDeathDiffDays    int
+	MaleModalAgeAtDeathDiffDays     int
+	FemaleLifeExpectancyDiffDays    int
+	FemaleMedianAgeAtDeathDiffDays  int
+	FemaleModalAgeAtDeathDiffDays   int
+	OverallLifeExpectancyDiffDays   int
+	OverallMedianAgeAtDeathDiffDays int
+	OverallModalAgeAtDeathDiffDays  int
+	OverallSurvivalPercentile       float64
+	HasOverallSurvivalPercentile    bool
+}
+
+// IndividualFilter reports whether an individual should be analysed in a batch.
+type IndividualFilter func(individual *gedcom.IndividualRecord) bool
+
+func isLiving(individual *gedcom.IndividualRecord) bool {
+	for _, event := range individual.Event {
+		switch event.Tag {
+		case "DEAT", "BURI", "CREM":
+			return false
+		}
+	}
+	return true
+}
+
+// ParseFilter turns a comma-separated list of conditions into a filter that individuals must
+// satisfy all of. Supported conditions are "all", "living", "deceased", "born-after=YEAR" and
+// "born-before=YEAR".
+func ParseFilter(filterStr string) (IndividualFilter, error) {
+	var filters []IndividualFilter
+	for _, condition := range strings.Split(filterStr, ",") {
+		condition = strings.ToLower(strings.TrimSpace(condition))
+		name, value, hasValue := strings.Cut(condition, "=")
+		switch name {
+		case "", "all":
+			continue
+		case "living":
+			filters = append(filters, isLiving)
+		case "deceased":
+			filters = append(filters, func(individual *gedcom.IndividualRecord) bool {
+				return !isLiving(individual)
+			})
+		case "born-after", "born-before":
+			if !hasValue {
+				return nil, fmt.Errorf("filter '%s' requires a year, e.g. %s=1950", name, name)
+			}
+			year, err := strconv.Atoi(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid year in filter '%s': %s", condition, err)
+			}
+			after := name == "born-after"
+			filters = append(filters, func(individual *gedcom.IndividualRecord) bool {
+				born, ok := BirthYear(individual)
+				if !ok {
+					return false
+				}
+				if after {
+					return born > year
+				}
+				return born < year
+			})
+		default:
+			return nil, fmt.Errorf("unknown filter '%s'", condition)
+		}
+	}
+
+	return func(individual *gedcom.IndividualRecord) bool {
+		for _, filter := range filters {
+			if !filter(individual) {
+				return false
+			}
+		}
+		return true
+	}, nil
+}
+
+// CountAncestors returns how many of the ancestors are of the given sex, or all of them for "".
+func CountAncestors(ancestors []AncestorDeath, gender string) int {
+	count := 0
+	for _, ancestor := range ancestors {
+		if ancestor.Gender == gender || gender == "" {
+			count++
+		}
+	}
+	return count
+}
+
+func newBatchSummary(result Result) BatchSummary {
+	return BatchSummary{
+		Subject:                         result.Subject,
+		Weighting:                       result.Weighter.Name(),
+		AncestorCount:                   result.Summary.Overall.Ancestors,
+		MaleAncestorCount:               result.Summary.Male.Ancestors,
+		FemaleAncestorCount:             result.Summary.Female.Ancestors,
+		ExcludedCount:                   len(result.Exclusions),
+		MaleLifeExpectancyDiffDays:      result.Summary.Male.LifeExpectancyDiffDays,
+		MaleMedianAgeAtDeathDiffDays:    result.Summary.Male.MedianAgeAtDeathDiffDays,
+		MaleModalAgeAtDeathDiffDays:     result.Summary.Male.ModalAgeAtDeathDiffDays,
+		FemaleLifeExpectancyDiffDays:    result.Summary.Female.LifeExpectancyDiffDays,
+		FemaleMedianAgeAtDeathDiffDays:  result.Summary.Female.MedianAgeAtDeathDiffDays,
+		FemaleModalAgeAtDeathDiffDays:   result.Summary.Female.ModalAgeAtDeathDiffDays,
+		OverallLifeExpectancyDiffDays:   result.Summary.Overall.LifeExpectancyDiffDays,
+		OverallMedianAgeAtDeathDiffDays: result.Summary.Overall.MedianAgeAtDeathDiffDays,
+		OverallModalAgeAtDeathDiffDays:  result.Summary.Overall.ModalAgeAtDeathDiffDays,
+		OverallSurvivalPercentile:       result.Summary.Overall.SurvivalPercentile,
+		HasOverallSurvivalPercentile:    result.Summary.Overall.HasSurvivalPercentile,
+	}
+}
+
+// AnalyzeBatch summarises every individual in the tree that passes the filter, reusing the parsed
+// tree and reference data for each subject. A subject who can't be analysed gets a summary holding
+// the error rather than stopping the batch.
+func (a *Analyzer) AnalyzeBatch(tree *gedcom.Gedcom, filter IndividualFilter) []BatchSummary {
+	var summaries []BatchSummary
+	for _, individual := range tree.Individual {
+		if !filter(individual) {
+			continue
+		}
+		result, err := a.AnalyzeSubject(individual)
+		if err != nil {
+			// The weighting is normalised by NewAnalyzer, so it's the name every subject's weighter has.
+			summaries = append(summaries, BatchSummary{Subject: individual, Weighting: a.options.Weighting, Error: err.Error()})
+			continue
+		}
+		summaries = append(summaries, newBatchSummary(result))
+	}
+	return summaries
+}
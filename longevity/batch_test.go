@@ -0,0 +1,145 @@
+package longevity
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/iand/gedcom"
+)
+
+const batchTestTree = `0 HEAD
+0 @I1@ INDI
+1 NAME Ann /Child/
+1 SEX F
+1 BIRT
+2 DATE 1960
+1 FAMC @F1@
+0 @I2@ INDI
+1 NAME Tom /Father/
+1 SEX M
+1 BIRT
+2 DATE 1 JAN 1900
+1 DEAT
+2 DATE 1 JAN 1970
+1 FAMS @F1@
+0 @I3@ INDI
+1 NAME Sue /Mother/
+1 SEX F
+1 BIRT
+2 DATE 1 JAN 1905
+1 DEAT
+2 DATE 1 JAN 1980
+1 FAMS @F1@
+0 @F1@ FAM
+1 HUSB @I2@
+1 WIFE @I3@
+1 CHIL @I1@
+0 TRLR
+`
+
+func TestParseFilter(t *testing.T) {
+	g := decodeTestTree(t, batchTestTree)
+	child, father := g.Individual[0], g.Individual[1]
+
+	tests := []struct {
+		filter     string
+		wantChild  bool
+		wantFather bool
+	}{
+		{filter: "all", wantChild: true, wantFather: true},
+		{filter: "living", wantChild: true, wantFather: false},
+		{filter: "deceased", wantChild: false, wantFather: true},
+		{filter: "born-after=1950", wantChild: true, wantFather: false},
+		{filter: "born-before=1950, deceased", wantChild: false, wantFather: true},
+		{filter: "living,born-before=1950", wantChild: false, wantFather: false},
+	}
+
+	for _, test := range tests {
+		filter, err := ParseFilter(test.filter)
+		if err != nil {
+			t.Fatalf("unexpected error for filter %q: %s", test.filter, err)
+		}
+		if got := filter(child); got != test.wantChild {
+			t.Errorf("filter %q: got %v for child, want %v", test.filter, got, test.wantChild)
+		}
+		if got := filter(father); got != test.wantFather {
+			t.Errorf("filter %q: got %v for father, want %v", test.filter, got, test.wantFather)
+		}
+	}
+
+	for _, invalid := range []string{"born-after", "born-after=soon", "tall"} {
+		if _, err := ParseFilter(invalid); err == nil {
+			t.Errorf("expected an error for filter %q", invalid)
+		}
+	}
+}
+
+func TestRunBatch(t *testing.T) {
+	g := decodeTestTree(t, batchTestTree)
+	maleDeathStats := []DeathStat{
+		{Year: "1970", LifeExpectancyDays: 25000, MedianAgeAtDeathDays: 25000, ModalAgeAtDeathDays: 27000},
+	}
+	femaleDeathStats := []DeathStat{
+		{Year: "1980", LifeExpectancyDays: 27000, MedianAgeAtDeathDays: 27000, ModalAgeAtDeathDays: 29000},
+	}
+
+	filter, err := ParseFilter("all")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	analyzer, err := NewAnalyzer(ReferenceRegistry{DefaultCountry: ReferenceData{MaleDeathStats: maleDeathStats, FemaleDeathStats: femaleDeathStats}}, Options{})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	summaries := analyzer.AnalyzeBatch(g, filter)
+
+	if len(summaries) != 3 {
+		t.Fatalf("expected 3 summaries, got %d", len(summaries))
+	}
+
+	child := summaries[0]
+	if child.Subject.Xref != "I1" || child.Weighting != GenerationalWeighting {
+		t.Errorf("expected first summary to be for I1 with generational weighting, got %s with %q", child.Subject.Xref, child.Weighting)
+	}
+	if child.AncestorCount != 2 || child.MaleAncestorCount != 1 || child.FemaleAncestorCount != 1 {
+		t.Errorf("unexpected ancestor counts: %+v", child)
+	}
+	// The father lived 25567 days and the mother 27393 days.
+	if child.MaleMedianAgeAtDeathDiffDays != 567 {
+		t.Errorf("expected male median diff of 567 days, got %d", child.MaleMedianAgeAtDeathDiffDays)
+	}
+	if child.FemaleModalAgeAtDeathDiffDays != -1607 {
+		t.Errorf("expected female modal diff of -1607 days, got %d", child.FemaleModalAgeAtDeathDiffDays)
+	}
+	if child.OverallMedianAgeAtDeathDiffDays != (567+393)/2 {
+		t.Errorf("expected overall median diff of %d days, got %d", (567+393)/2, child.OverallMedianAgeAtDeathDiffDays)
+	}
+
+	for _, parent := range summaries[1:] {
+		if parent.AncestorCount != 0 {
+			t.Errorf("expected no ancestors for %s, got %d", parent.Subject.Xref, parent.AncestorCount)
+		}
+	}
+}
+
+func TestRunBatchRecordsSubjectErrors(t *testing.T) {
+	g := decodeTestTree(t, strings.Replace(batchTestTree, "1 BIRT\n2 DATE 1960\n", "", 1))
+	options := Options{Weighting: CalendarDecayWeighting, WeightingHalfLifeYears: DefaultWeightingHalfLifeYears}
+	analyzer, err := NewAnalyzer(ReferenceRegistry{DefaultCountry: ReferenceData{}}, options)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	summaries := analyzer.AnalyzeBatch(g, func(*gedcom.IndividualRecord) bool { return true })
+
+	if len(summaries) != 3 {
+		t.Fatalf("expected 3 summaries, got %d", len(summaries))
+	}
+	if summaries[0].Error == "" || summaries[0].Weighting != CalendarDecayWeighting {
+		t.Errorf("expected a calendar-decay error for the child without a birth date, got %+v", summaries[0])
+	}
+	for _, parent := range summaries[1:] {
+		if parent.Error != "" {
+			t.Errorf("unexpected error for %s: %s", parent.Subject.Xref, parent.Error)
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package longevity
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/iand/gedcom"
+)
+
+// Conditioning modes choose the age an ancestor is known to have survived to, so their age at death
+// is compared with the life expectancy of people who lived that long rather than of everyone born.
+const (
+	NoConditioning         = "none"
+	AdulthoodConditioning  = "adulthood"
+	FirstChildConditioning = "first-child"
+)
+
+var ConditioningModes = []string{NoConditioning, AdulthoodConditioning, FirstChildConditioning}
+
+const DefaultAdultAge = 15
+
+func checkConditioningMode(mode string) error {
+	for _, known := range ConditioningModes {
+		if mode == known {
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown conditioning '%s', expected one of %v", mode, ConditioningModes)
+}
+
+// firstChildBirth returns the earliest birth date of any of the individual's children.
+func firstChildBirth(individual *gedcom.IndividualRecord, fallbacks []EventFallback) (time.Time, bool) {
+	var first time.Time
+	found := false
+	for _, familyLink := range individual.Family {
+		if familyLink.Family == nil {
+			continue
+		}
+		for _, child := range familyLink.Family.Child {
+			birth, _, ok := findEventDate(child, "BIRT", fallbacks)
+			if !ok {
+				continue
+			}
+			if !found || birth.Estimate.Before(first) {
+				first = birth.Estimate
+				found = true
+			}
+		}
+	}
+	return first, found
+}
+
+// conditioningAge returns the age in whole years the ancestor is known to have survived to under the
+// chosen mode. With first-child conditioning, ancestors with no dated children are taken to have
+// reached adulthood, which they must have done to appear in the pedigree.
+func conditioningAge(individual *gedcom.IndividualRecord, birthDate time.Time, options Options) int {
+	adultAge := options.AdultAge
+	if adultAge <= 0 {
+		adultAge = DefaultAdultAge
+	}
+
+	switch options.Conditioning {
+	case AdulthoodConditioning:
+		return adultAge
+	case FirstChildConditioning:
+		childBirth, ok := firstChildBirth(individual, options.BirthFallbacks)
+		if !ok || childBirth.Before(birthDate) {
+			return adultAge
+		}
+		return ageInYears(birthDate, childBirth)
+	}
+	return 0
+}
+
+// ageInYears returns the number of birthdays someone born on birthDate has had by date.
+func ageInYears(birthDate time.Time, date time.Time) int {
+	years := date.Year() - birthDate.Year()
+	if date.Before(birthDate.AddDate(years, 0, 0)) {
+		years--
+	}
+	return years
+}
+
+// conditionalLifeExpectancyDays returns the expected age at death, in days, of someone who survived
+// to age according to the life table.
+func conditionalLifeExpectancyDays(table *LifeTable, age int) (int, bool) {
+	expectedAge, ok := table.expectedAgeAtDeath(age)
+	if !ok {
+		return 0, false
+	}
+	return int(expectedAge * 365), true
+}
@@ -0,0 +1,106 @@
+package longevity
+
+import (
+	"testing"
+
+	"github.com/iand/gedcom"
+)
+
+func TestConditioningAge(t *testing.T) {
+	g := decodeTestTree(t, `0 HEAD
+0 @I1@ INDI
+1 NAME Ann /Smith/
+1 SEX F
+1 BIRT
+2 DATE 10 MAR 1850
+1 DEAT
+2 DATE 1910
+1 FAMS @F1@
+0 @I2@ INDI
+1 NAME Mary /Smith/
+1 BIRT
+2 DATE 1880
+1 FAMC @F1@
+0 @I3@ INDI
+1 NAME John /Smith/
+1 BIRT
+2 DATE 9 MAR 1872
+1 FAMC @F1@
+0 @I4@ INDI
+1 NAME Tom /Jones/
+1 SEX M
+1 BIRT
+2 DATE 1840
+0 @F1@ FAM
+1 WIFE @I1@
+1 CHIL @I2@
+1 CHIL @I3@
+0 TRLR
+`)
+	individuals := individualsByXref(g)
+
+	tests := []struct {
+		name       string
+		individual *gedcom.IndividualRecord
+		birth      string
+		options    Options
+		want       int
+	}{
+		{name: "none", individual: individuals["I1"], birth: "10 MAR 1850", options: Options{Conditioning: NoConditioning}, want: 0},
+		{name: "adulthood", individual: individuals["I1"], birth: "10 MAR 1850", options: Options{Conditioning: AdulthoodConditioning}, want: DefaultAdultAge},
+		{name: "adult age", individual: individuals["I1"], birth: "10 MAR 1850", options: Options{Conditioning: AdulthoodConditioning, AdultAge: 18}, want: 18},
+		{name: "first child", individual: individuals["I1"], birth: "10 MAR 1850", options: Options{Conditioning: FirstChildConditioning}, want: 21},
+		{name: "no children", individual: individuals["I4"], birth: "1840", options: Options{Conditioning: FirstChildConditioning}, want: DefaultAdultAge},
+	}
+
+	for _, test := range tests {
+		birth, err := ParseDateValue(test.birth)
+		if err != nil {
+			t.Fatalf("test %q: %s", test.name, err)
+		}
+		if got := conditioningAge(test.individual, birth.Estimate, test.options); got != test.want {
+			t.Errorf("test %q: got %d, want %d", test.name, got, test.want)
+		}
+	}
+}
+
+func TestGetDeathStatsForAncestorsConditional(t *testing.T) {
+	femaleDeathStats := []DeathStat{
+		{Year: "1910", LifeExpectancyDays: 40 * 365, MedianAgeAtDeathDays: 50 * 365, ModalAgeAtDeathDays: 70 * 365},
+	}
+	femaleLifeTables := LifeTables{
+		1865: {Year: 1865, Rows: []LifeTableRow{{Age: 15, Ex: 45}}},
+		1910: {Year: 1910, Rows: []LifeTableRow{{Age: 0, Lx: 100000}, {Age: 15, Lx: 80000}, {Age: 100, Lx: 0}}},
+	}
+	ancestor := &gedcom.IndividualRecord{
+		Sex: "F",
+		Event: []*gedcom.EventRecord{
+			{Tag: "BIRT", Date: "1 JAN 1850"},
+			{Tag: "DEAT", Date: "1 JAN 1910"},
+		},
+	}
+	ancestors := map[*gedcom.IndividualRecord]AncestorPaths{ancestor: {1: 1}}
+	reference := ReferenceData{FemaleDeathStats: femaleDeathStats, FemaleLifeTables: femaleLifeTables}
+
+	got, _ := getDeathStatsForAncestors(nil, ancestors, ReferenceRegistry{DefaultCountry: reference}, Options{Conditioning: AdulthoodConditioning})
+	if len(got) != 1 {
+		t.Fatalf("expected 1 ancestor, got %d", len(got))
+	}
+	if got[0].ConditioningAge != 15 || got[0].LifeExpectancyDays != 60*365 {
+		t.Errorf("expected life expectancy of 60 years from age 15, got %d days from age %d", got[0].LifeExpectancyDays, got[0].ConditioningAge)
+	}
+	if want := got[0].AgeAtDeathDaysTotal - 60*365; got[0].LifeExpectancyDiffDays != want {
+		t.Errorf("expected life expectancy diff of %d days, got %d", want, got[0].LifeExpectancyDiffDays)
+	}
+	// 60 years and 15 leap days is 45 years and 15 days past 15, so she outlived just over 45/85 of
+	// those who reached 15.
+	if !got[0].HasSurvivalPercentile || got[0].SurvivalPercentile < 52.9 || got[0].SurvivalPercentile > 53 {
+		t.Errorf("expected a survival percentile of about 52.9, got %v", got[0].SurvivalPercentile)
+	}
+
+	// With no life table for the year she turned 18, life expectancy falls back to that at birth.
+	got, _ = getDeathStatsForAncestors(nil, ancestors, ReferenceRegistry{DefaultCountry: reference}, Options{Conditioning: AdulthoodConditioning, AdultAge: 18})
+	if got[0].ConditioningAge != 0 || got[0].LifeExpectancyDays != 40*365 {
+		t.Errorf("expected life expectancy of 40 years from birth, got %d days from age %d", got[0].LifeExpectancyDays, got[0].ConditioningAge)
+	}
+}
@@ -0,0 +1,180 @@
+package longevity
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/iand/gedcom"
+)
+
+// ReferenceRegistry holds a set of reference statistics for each country covered, keyed by Human
+// Mortality Database country code (e.g. GBRTENW for England and Wales).
+type ReferenceRegistry map[string]ReferenceData
+
+// DefaultCountry is the country of the bundled ONS statistics, which are used for ancestors whose
+// country can't be worked out or has no statistics of its own.
+const DefaultCountry = "GBRTENW"
+
+// countryAliases maps the ways a country or one of its constituent parts tends to be written in a
+// place name to its country code.
+var countryAliases = map[string]string{
+	"australia":                "AUS",
+	"austria":                  "AUT",
+	"belgium":                  "BEL",
+	"canada":                   "CAN",
+	"switzerland":              "CHE",
+	"germany":                  "DEUTNP",
+	"prussia":                  "DEUTNP",
+	"denmark":                  "DNK",
+	"spain":                    "ESP",
+	"finland":                  "FIN",
+	"france":                   "FRATNP",
+	"england":                  "GBRTENW",
+	"wales":                    "GBRTENW",
+	"england and wales":        "GBRTENW",
+	"scotland":                 "GBR_SCO",
+	"northern ireland":         "GBR_NIR",
+	"uk":                       "GBR_NP",
+	"u.k.":                     "GBR_NP",
+	"united kingdom":           "GBR_NP",
+	"great britain":            "GBR_NP",
+	"britain":                  "GBR_NP",
+	"gb":                       "GBR_NP",
+	"ireland":                  "IRL",
+	"eire":                     "IRL",
+	"republic of ireland":      "IRL",
+	"iceland":                  "ISL",
+	"italy":                    "ITA",
+	"netherlands":              "NLD",
+	"the netherlands":          "NLD",
+	"holland":                  "NLD",
+	"norway":                   "NOR",
+	"new zealand":              "NZL_NP",
+	"poland":                   "POL",
+	"portugal":                 "PRT",
+	"sweden":                   "SWE",
+	"usa":                      "USA",
+	"u.s.a.":                   "USA",
+	"us":                       "USA",
+	"u.s.":                     "USA",
+	"united states":            "USA",
+	"united states of america": "USA",
+	"america":                  "USA",
+}
+
+// usStates leaves out Georgia, which is also a country. "Atlanta, Georgia, USA" and "Atlanta, GA"
+// still resolve from their last part.
+var usStates = []string{
+	"alabama", "alaska", "arizona", "arkansas", "california", "colorado", "connecticut", "delaware",
+	"district of columbia", "florida", "hawaii", "idaho", "illinois", "indiana", "iowa",
+	"kansas", "kentucky", "louisiana", "maine", "maryland", "massachusetts", "michigan", "minnesota",
+	"mississippi", "missouri", "montana", "nebraska", "nevada", "new hampshire", "new jersey",
+	"new mexico", "new york", "north carolina", "north dakota", "ohio", "oklahoma", "oregon",
+	"pennsylvania", "rhode island", "south carolina", "south dakota", "tennessee", "texas", "utah",
+	"vermont", "virginia", "washington", "west virginia", "wisconsin", "wyoming",
+}
+
+var usStateAbbreviations = []string{
+	"al", "ak", "az", "ar", "ca", "co", "ct", "de", "dc", "fl", "ga", "hi", "id", "il", "in", "ia",
+	"ks", "ky", "la", "me", "md", "ma", "mi", "mn", "ms", "mo", "mt", "ne", "nv", "nh", "nj", "nm",
+	"ny", "nc", "nd", "oh", "ok", "or", "pa", "ri", "sc", "sd", "tn", "tx", "ut", "vt", "va", "wa",
+	"wv", "wi", "wy",
+}
+
+func init() {
+	for _, state := range usStates {
+		countryAliases[state] = "USA"
+	}
+}
+
+func isUSStateAbbreviation(part string) bool {
+	for _, abbreviation := range usStateAbbreviations {
+		if part == abbreviation {
+			return true
+		}
+	}
+	return false
+}
+
+// resolveCountry works out the country code for a GEDCOM place name, whose parts run from the most
+// to the least specific (e.g. "Leeds, Yorkshire, England"). Parts are tried from the end, so the
+// country is found before a town that happens to share a country's name. Two-letter US state
+// abbreviations are only recognised in the last part, where they can't be mistaken for anything
+// else in the place name.
+func resolveCountry(place string) (string, bool) {
+	parts := strings.Split(place, ",")
+	for i := len(parts) - 1; i >= 0; i-- {
+		part := strings.ToLower(strings.Join(strings.Fields(parts[i]), " "))
+		if part == "" {
+			continue
+		}
+		if code, ok := countryAliases[part]; ok {
+			return code, true
+		}
+		if i == len(parts)-1 && len(parts) > 1 && isUSStateAbbreviation(part) {
+			return "USA", true
+		}
+	}
+	return "", false
+}
+
+func eventPlace(individual *gedcom.IndividualRecord, tag string) string {
+	for _, event := range individual.Event {
+		if event.Tag == tag && event.Place.Name != "" {
+			return event.Place.Name
+		}
+	}
+	return ""
+}
+
+// ancestorCountry chooses the reference statistics for an ancestor from the places of the events
+// their dates came from: the place of death first, as the period statistics are for the year of
+// death, or the place of birth first with cohort comparison. The first place that resolves to a
+// country in the registry wins, and the default country is used if none do.
+func ancestorCountry(individual *gedcom.IndividualRecord, birthSource string, deathSource string, registry ReferenceRegistry, options Options) string {
+	sources := []string{deathSource, birthSource}
+	if options.Comparison == CohortComparison {
+		sources = []string{birthSource, deathSource}
+	}
+	for _, source := range sources {
+		if code, ok := resolveCountry(eventPlace(individual, source)); ok {
+			if _, covered := registry[code]; covered {
+				return code
+			}
+		}
+	}
+	if options.DefaultCountry != "" {
+		return options.DefaultCountry
+	}
+	return DefaultCountry
+}
+
+// Codes returns the country codes the registry has statistics for, in alphabetical order.
+func (r ReferenceRegistry) Codes() []string {
+	var codes []string
+	for code := range r {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+	return codes
+}
+
+// ParseCountryStats parses a country's death stats files given as CODE:MALE_CSV:FEMALE_CSV.
+func ParseCountryStats(value string) (string, string, string, error) {
+	parts := strings.Split(value, ":")
+	if len(parts) != 3 || parts[0] == "" || parts[1] == "" || parts[2] == "" {
+		return "", "", "", fmt.Errorf("country stats '%s' should be in the form CODE:MALE_CSV:FEMALE_CSV", value)
+	}
+	return strings.ToUpper(parts[0]), parts[1], parts[2], nil
+}
+
+// HasCohortLifeTables reports whether any country has cohort life tables for either sex.
+func (r ReferenceRegistry) HasCohortLifeTables() bool {
+	for _, reference := range r {
+		if reference.MaleCohortLifeTables != nil || reference.FemaleCohortLifeTables != nil {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,108 @@
+package longevity
+
+import (
+	"testing"
+
+	"github.com/iand/gedcom"
+)
+
+func TestResolveCountry(t *testing.T) {
+	tests := []struct {
+		place  string
+		want   string
+		wantOk bool
+	}{
+		{place: "Leeds, Yorkshire, England", want: "GBRTENW", wantOk: true},
+		{place: "Cardiff, Glamorgan, Wales", want: "GBRTENW", wantOk: true},
+		{place: "Glasgow, Lanarkshire, Scotland", want: "GBR_SCO", wantOk: true},
+		{place: "Belfast, Antrim, Northern Ireland", want: "GBR_NIR", wantOk: true},
+		{place: "London, UK", want: "GBR_NP", wantOk: true},
+		{place: "Cork, Ireland", want: "IRL", wantOk: true},
+		{place: "Boston, Suffolk, Massachusetts, USA", want: "USA", wantOk: true},
+		{place: "Boston, Lincolnshire, England", want: "GBRTENW", wantOk: true},
+		{place: "Springfield, Sangamon, Illinois", want: "USA", wantOk: true},
+		{place: "Portland, OR", want: "USA", wantOk: true},
+		{place: "Atlanta, Fulton, Georgia, USA", want: "USA", wantOk: true},
+		{place: "Atlanta, GA", want: "USA", wantOk: true},
+		{place: "Tbilisi, Georgia", wantOk: false},
+		{place: "Washington, Tyne and Wear, England", want: "GBRTENW", wantOk: true},
+		{place: "  paris ,  FRANCE ", want: "FRATNP", wantOk: true},
+		{place: "Leeds", wantOk: false},
+		{place: "OR", wantOk: false},
+		{place: "", wantOk: false},
+	}
+
+	for _, test := range tests {
+		got, ok := resolveCountry(test.place)
+		if got != test.want || ok != test.wantOk {
+			t.Errorf("place %q: got %q, %v, want %q, %v", test.place, got, ok, test.want, test.wantOk)
+		}
+	}
+}
+
+func TestParseCountryStats(t *testing.T) {
+	code, male, female, err := ParseCountryStats("gbr_sco:male.csv:female.csv")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if code != "GBR_SCO" || male != "male.csv" || female != "female.csv" {
+		t.Errorf("got %q, %q, %q", code, male, female)
+	}
+
+	for _, value := range []string{"GBR_SCO", "GBR_SCO:male.csv", ":male.csv:female.csv", "GBR_SCO:male.csv:"} {
+		if _, _, _, err := ParseCountryStats(value); err == nil {
+			t.Errorf("expected an error for %q", value)
+		}
+	}
+}
+
+func TestGetDeathStatsForAncestorsByCountry(t *testing.T) {
+	registry := ReferenceRegistry{
+		DefaultCountry: {MaleDeathStats: []DeathStat{{Year: "1900", MedianAgeAtDeathDays: 50 * 365}}},
+		"GBR_SCO":      {MaleDeathStats: []DeathStat{{Year: "1900", MedianAgeAtDeathDays: 45 * 365}}},
+		"USA":          {MaleDeathStats: []DeathStat{{Year: "1900", MedianAgeAtDeathDays: 55 * 365}}},
+	}
+
+	newAncestor := func(birthPlace string, deathPlace string) *gedcom.IndividualRecord {
+		return &gedcom.IndividualRecord{
+			Sex: "M",
+			Event: []*gedcom.EventRecord{
+				{Tag: "BIRT", Date: "1850", Place: gedcom.PlaceRecord{Name: birthPlace}},
+				{Tag: "BURI", Date: "1900", Place: gedcom.PlaceRecord{Name: deathPlace}},
+			},
+		}
+	}
+
+	tests := []struct {
+		name       string
+		ancestor   *gedcom.IndividualRecord
+		options    Options
+		wantCode   string
+		wantMedian int
+	}{
+		{name: "place of death", ancestor: newAncestor("Leeds, England", "Edinburgh, Scotland"), wantCode: "GBR_SCO", wantMedian: 45 * 365},
+		{name: "place of birth first for cohorts", ancestor: newAncestor("Leeds, England", "Edinburgh, Scotland"), options: Options{Comparison: CohortComparison}, wantCode: DefaultCountry},
+		{name: "falls back to place of birth", ancestor: newAncestor("Albany, New York", ""), wantCode: "USA", wantMedian: 55 * 365},
+		{name: "country without stats", ancestor: newAncestor("Paris, France", "Paris, France"), wantCode: DefaultCountry, wantMedian: 50 * 365},
+		{name: "configured default", ancestor: newAncestor("", ""), options: Options{DefaultCountry: "USA"}, wantCode: "USA", wantMedian: 55 * 365},
+	}
+
+	for _, test := range tests {
+		options := test.options
+		options.DeathFallbacks = []EventFallback{{Tag: "BURI"}}
+		if options.Comparison == CohortComparison {
+			if got := ancestorCountry(test.ancestor, "BIRT", "BURI", registry, options); got != test.wantCode {
+				t.Errorf("test %q: got %q, want %q", test.name, got, test.wantCode)
+			}
+			continue
+		}
+
+		got, _ := getDeathStatsForAncestors(nil, map[*gedcom.IndividualRecord]AncestorPaths{test.ancestor: {1: 1}}, registry, options)
+		if len(got) != 1 {
+			t.Fatalf("test %q: expected 1 ancestor, got %d", test.name, len(got))
+		}
+		if got[0].Country != test.wantCode || got[0].MedianDeathAgeDays != test.wantMedian {
+			t.Errorf("test %q: got %s with median %d, want %s with median %d", test.name, got[0].Country, got[0].MedianDeathAgeDays, test.wantCode, test.wantMedian)
+		}
+	}
+}
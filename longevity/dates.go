@@ -0,0 +1,120 @@
+package longevity
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/araddon/dateparse"
+)
+
+var months = []string{"January", "February", "March", "April", "May", "June", "July", "August", "September", "October", "November", "December"}
+
+func checkValidYear(dateStr string) error {
+	currentYear := time.Now().Year()
+	re := regexp.MustCompile(`\b\d{4}\b`)
+	yearStr := re.FindString(dateStr)
+	if yearStr == "" {
+		return fmt.Errorf("date '%s' does not contain a four-digit year", dateStr)
+	}
+	year, err := strconv.Atoi(yearStr)
+	if err != nil {
+		return fmt.Errorf("error converting year from date '%s' to an int", dateStr)
+	}
+	if year < 1500 || year > currentYear {
+		return fmt.Errorf("year in date '%s' is outside valid range", dateStr)
+	}
+	return nil
+}
+
+func yearRangeMidpoint(dateStr string) (time.Time, error) {
+	dateStr = strings.ReplaceAll(dateStr, " ", "")
+	parts := strings.Split(dateStr, "-")
+	if len(parts) != 2 {
+		return time.Time{}, fmt.Errorf("invalid date range: %s", dateStr)
+	}
+	startYear, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid start year in date range: %s", dateStr)
+	}
+	endYear, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid end year in date range: %s", dateStr)
+	}
+	start := time.Date(startYear, 1, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(endYear, 1, 1, 0, 0, 0, 0, time.UTC)
+	midpoint := start.Add(end.Sub(start) / 2)
+	return midpoint, nil
+}
+
+func cleanDate(dateStr string) string {
+	dateSuffixRegex := regexp.MustCompile(`([1-9])(st|nd|th|rd)`)
+	dateStr = dateSuffixRegex.ReplaceAllString(dateStr, "$1")
+	dateStr = strings.ReplaceAll(dateStr, "  ", " ")
+
+	for _, month := range months {
+		monthAbbr := month[:3]
+
+		regex := regexp.MustCompile(fmt.Sprintf(`(?i)\b%s\b`, monthAbbr))
+		dateStr = regex.ReplaceAllString(dateStr, month)
+
+		if monthAbbr == "Sep" {
+			monthAbbr = month[:4]
+			regex := regexp.MustCompile(fmt.Sprintf(`(?i)\b%s\b`, monthAbbr))
+			dateStr = regex.ReplaceAllString(dateStr, month)
+		}
+	}
+	return dateStr
+}
+
+// ParseDate parses a free-form date such as "21st June 1850" or "1900-1950", taking the midpoint of
+// a range of years.
+func ParseDate(dateStr string) (time.Time, error) {
+	err := checkValidYear(dateStr)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("no valid year found in date: %s", err)
+	}
+
+	dateStr = cleanDate(dateStr)
+
+	foundMonth := false
+	for _, month := range months {
+		if strings.HasPrefix(dateStr, month) {
+			foundMonth = true
+			break
+		}
+	}
+
+	if !foundMonth {
+		for i, r := range dateStr {
+			if r >= '0' && r <= '9' {
+				dateStr = dateStr[i:]
+				break
+			}
+		}
+	}
+
+	yearRangeRegex := regexp.MustCompile(`(\d{4})\s*-\s*(\d{4})`)
+	if yearRangeRegex.MatchString(dateStr) {
+		parsedDate, err := yearRangeMidpoint(dateStr)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("failed to find midpoint date in year range '%s': %s", dateStr, err)
+		}
+		return parsedDate, nil
+	}
+
+	for _, month := range months {
+		if strings.Contains(dateStr, month) && !regexp.MustCompile(`\b\d{1,2} `+month).MatchString(dateStr) {
+			dateStr = regexp.MustCompile(month).ReplaceAllString(dateStr, "1 "+month)
+		}
+	}
+
+	parsedDate, err := dateparse.ParseIn(dateStr, time.UTC)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to parse date %s: %s", dateStr, err)
+	}
+
+	return parsedDate, nil
+}
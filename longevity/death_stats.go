@@ -0,0 +1,122 @@
+package longevity
+
+import (
+	"embed"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// The ONS statistics for England and Wales are built into the binary so it works from any directory.
+//
+//go:embed male_death_stats.csv female_death_stats.csv
+var embeddedDeathStats embed.FS
+
+const (
+	MaleDeathStatsFileName   = "male_death_stats.csv"
+	FemaleDeathStatsFileName = "female_death_stats.csv"
+)
+
+// deathStatsColumns are the columns a death stats CSV must have, matched case-insensitively in any
+// order.
+var deathStatsColumns = []string{"Year", "Life expectancy at birth", "Median age at death", "Modal age at death"}
+
+// ParseDeathStats reads death stats from the CSV file at filepath.
+func ParseDeathStats(filepath string) ([]DeathStat, error) {
+	file, err := os.Open(filepath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	stats, err := ReadDeathStats(file)
+	if err != nil {
+		return nil, fmt.Errorf("error reading %s: %s", filepath, err)
+	}
+	return stats, nil
+}
+
+// ReadDeathStats reads death stats from a CSV with the columns in deathStatsColumns.
+func ReadDeathStats(r io.Reader) ([]DeathStat, error) {
+	records, err := csv.NewReader(r).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, fmt.Errorf("no header row")
+	}
+
+	columns := make([]int, len(deathStatsColumns))
+	for i, name := range deathStatsColumns {
+		columns[i] = -1
+		for j, header := range records[0] {
+			if strings.EqualFold(strings.TrimSpace(header), name) {
+				columns[i] = j
+				break
+			}
+		}
+		if columns[i] < 0 {
+			return nil, fmt.Errorf("missing column '%s'", name)
+		}
+	}
+
+	var DeathStats []DeathStat
+
+	for i, record := range records[1:] {
+		line := i + 2
+		values := make([]float64, len(columns)-1)
+		for j, column := range columns[1:] {
+			values[j], err = strconv.ParseFloat(strings.TrimSpace(record[column]), 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s on line %d: %s", strings.ToLower(deathStatsColumns[j+1]), line, err)
+			}
+		}
+		year := strings.TrimSpace(record[columns[0]])
+		if _, err := strconv.Atoi(year); err != nil {
+			return nil, fmt.Errorf("invalid year on line %d: %s", line, err)
+		}
+
+		DeathStats = append(DeathStats, DeathStat{
+			Year:                 year,
+			LifeExpectancy:       values[0],
+			LifeExpectancyDays:   int(values[0] * 365),
+			MedianAgeAtDeath:     values[1],
+			MedianAgeAtDeathDays: int(values[1] * 365),
+			ModalAgeAtDeath:      values[2],
+			ModalAgeAtDeathDays:  int(values[2] * 365),
+		})
+	}
+	return DeathStats, nil
+}
+
+// LoadDeathStats reads death stats from path if it's set, then from fileName in dir if that's set,
+// and otherwise from the embedded ONS statistics of the same name.
+func LoadDeathStats(path string, dir string, fileName string) ([]DeathStat, error) {
+	switch {
+	case path != "":
+		return ParseDeathStats(path)
+	case dir != "":
+		return ParseDeathStats(filepath.Join(dir, fileName))
+	}
+	file, err := embeddedDeathStats.Open(fileName)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return ReadDeathStats(file)
+}
+
+// DeathStatsSource describes where LoadDeathStats reads its statistics from.
+func DeathStatsSource(path string, dir string, fileName string) string {
+	switch {
+	case path != "":
+		return path
+	case dir != "":
+		return filepath.Join(dir, fileName)
+	}
+	return fileName + " (embedded)"
+}
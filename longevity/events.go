@@ -0,0 +1,84 @@
+package longevity
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/iand/gedcom"
+)
+
+// EventFallback stands in for a missing birth or death date with the date of a later event, such
+// as a christening or burial, minus the typical number of days between the two.
+type EventFallback struct {
+	Tag       string
+	DelayDays int
+}
+
+// TypicalBirthFallbacks and TypicalDeathFallbacks impute dates from christenings, baptisms, burials
+// and cremations with the usual delays before each. Fallbacks are off unless asked for, so that
+// adding them doesn't change the results for an existing tree.
+const TypicalBirthFallbacks = "CHR:30,BAPM:30"
+const TypicalDeathFallbacks = "BURI:4,CREM:7"
+
+// ParseFallbacks parses a comma-separated list of TAG:DAYS rules, e.g. "CHR:30,BAPM:30". The rules
+// are tried in order, and an empty string disables fallbacks.
+func ParseFallbacks(fallbacksStr string) ([]EventFallback, error) {
+	var fallbacks []EventFallback
+	for _, rule := range strings.Split(fallbacksStr, ",") {
+		rule = strings.TrimSpace(rule)
+		if rule == "" {
+			continue
+		}
+		tag, daysStr, found := strings.Cut(rule, ":")
+		if !found {
+			return nil, fmt.Errorf("fallback rule '%s' should be in the form TAG:DAYS", rule)
+		}
+		days, err := strconv.Atoi(strings.TrimSpace(daysStr))
+		if err != nil || days < 0 {
+			return nil, fmt.Errorf("invalid number of days in fallback rule '%s'", rule)
+		}
+		fallbacks = append(fallbacks, EventFallback{Tag: strings.ToUpper(strings.TrimSpace(tag)), DelayDays: days})
+	}
+	return fallbacks, nil
+}
+
+func findEvent(individual *gedcom.IndividualRecord, tag string) (DateValue, bool) {
+	for _, event := range individual.Event {
+		if event.Tag != tag {
+			continue
+		}
+		date, err := ParseDateValue(event.Date)
+		if err != nil {
+			continue
+		}
+		return date, true
+	}
+	return DateValue{}, false
+}
+
+// findEventDate returns the date of the individual's event with the given tag, or failing that an
+// imputed date from the first fallback event that has a usable date, along with the tag of the
+// event the date came from. An imputed date may have fallen anywhere up to twice the typical delay
+// before the fallback event.
+func findEventDate(individual *gedcom.IndividualRecord, tag string, fallbacks []EventFallback) (DateValue, string, bool) {
+	if date, ok := findEvent(individual, tag); ok {
+		return date, tag, true
+	}
+
+	for _, fallback := range fallbacks {
+		date, ok := findEvent(individual, fallback.Tag)
+		if !ok {
+			continue
+		}
+		date.Earliest = date.Earliest.AddDate(0, 0, -2*fallback.DelayDays)
+		date.Estimate = date.Estimate.AddDate(0, 0, -fallback.DelayDays)
+		return date, fallback.Tag, true
+	}
+	return DateValue{}, "", false
+}
+
+// IsImputed reports whether a date found for the event with the given tag came from a fallback event.
+func IsImputed(source string, tag string) bool {
+	return source != "" && source != tag
+}
@@ -0,0 +1,95 @@
+package longevity
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/iand/gedcom"
+)
+
+func TestParseFallbacks(t *testing.T) {
+	fallbacks, err := ParseFallbacks("chr:30, BAPM:14")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	want := []EventFallback{{Tag: "CHR", DelayDays: 30}, {Tag: "BAPM", DelayDays: 14}}
+	if !reflect.DeepEqual(fallbacks, want) {
+		t.Errorf("got %v, want %v", fallbacks, want)
+	}
+
+	fallbacks, err = ParseFallbacks("")
+	if err != nil || len(fallbacks) != 0 {
+		t.Errorf("expected no fallbacks and no error for an empty string, got %v and %v", fallbacks, err)
+	}
+
+	for _, invalid := range []string{"CHR", "CHR:soon", "CHR:-3"} {
+		if _, err := ParseFallbacks(invalid); err == nil {
+			t.Errorf("expected an error for %q", invalid)
+		}
+	}
+}
+
+func TestFindEventDate(t *testing.T) {
+	fallbacks := []EventFallback{{Tag: "CHR", DelayDays: 30}, {Tag: "BAPM", DelayDays: 10}}
+
+	recorded := &gedcom.IndividualRecord{Event: []*gedcom.EventRecord{
+		{Tag: "CHR", Date: "1 Mar 1850"},
+		{Tag: "BIRT", Date: "1 Feb 1850"},
+	}}
+	date, source, ok := findEventDate(recorded, "BIRT", fallbacks)
+	if !ok || source != "BIRT" || !date.Estimate.Equal(utcDate(1850, 2, 1)) {
+		t.Errorf("expected recorded birth on 1 Feb 1850, got %s from %s (found: %v)", date.Estimate, source, ok)
+	}
+
+	christened := &gedcom.IndividualRecord{Event: []*gedcom.EventRecord{
+		{Tag: "BIRT", Date: "not a date"},
+		{Tag: "CHR"},
+		{Tag: "BAPM", Date: "11 Mar 1850"},
+	}}
+	date, source, ok = findEventDate(christened, "BIRT", fallbacks)
+	if !ok || source != "BAPM" {
+		t.Fatalf("expected birth imputed from BAPM, got %q (found: %v)", source, ok)
+	}
+	if !date.Estimate.Equal(utcDate(1850, 3, 1)) {
+		t.Errorf("expected imputed birth on 1 Mar 1850, got %s", date.Estimate)
+	}
+	if !date.Earliest.Equal(utcDate(1850, 2, 19)) || !date.Latest.Equal(utcDate(1850, 3, 11)) {
+		t.Errorf("expected imputed birth between 19 Feb and 11 Mar 1850, got %s to %s", date.Earliest, date.Latest)
+	}
+
+	if _, _, ok := findEventDate(christened, "BIRT", nil); ok {
+		t.Error("expected no birth date without fallbacks")
+	}
+}
+
+func TestGetDeathStatsForAncestorsWithFallbacks(t *testing.T) {
+	femaleDeathStats := []DeathStat{
+		{Year: "1900", LifeExpectancyDays: 14600, MedianAgeAtDeathDays: 14600, ModalAgeAtDeathDays: 14600},
+	}
+	ancestor := &gedcom.IndividualRecord{
+		Sex: "F",
+		Event: []*gedcom.EventRecord{
+			{Tag: "CHR", Date: "31 Jan 1860"},
+			{Tag: "BURI", Date: "5 Jan 1900"},
+		},
+	}
+	options := Options{
+		BirthFallbacks: []EventFallback{{Tag: "CHR", DelayDays: 30}},
+		DeathFallbacks: []EventFallback{{Tag: "BURI", DelayDays: 4}},
+	}
+
+	if got, _ := getDeathStatsForAncestors(nil, map[*gedcom.IndividualRecord]AncestorPaths{ancestor: {1: 1}}, ReferenceRegistry{DefaultCountry: ReferenceData{FemaleDeathStats: femaleDeathStats}}, Options{}); len(got) != 0 {
+		t.Errorf("expected ancestor to be excluded without fallbacks, got %v", got)
+	}
+
+	got, _ := getDeathStatsForAncestors(nil, map[*gedcom.IndividualRecord]AncestorPaths{ancestor: {1: 1}}, ReferenceRegistry{DefaultCountry: ReferenceData{FemaleDeathStats: femaleDeathStats}}, options)
+	if len(got) != 1 {
+		t.Fatalf("expected 1 ancestor, got %d", len(got))
+	}
+	if got[0].BirthSource != "CHR" || got[0].DeathSource != "BURI" {
+		t.Errorf("expected sources CHR and BURI, got %s and %s", got[0].BirthSource, got[0].DeathSource)
+	}
+	if got[0].AgeAtDeathDaysTotal != 14610 {
+		t.Errorf("expected age at death of 14610 days, got %d", got[0].AgeAtDeathDaysTotal)
+	}
+}
@@ -0,0 +1,73 @@
+package longevity
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/iand/gedcom"
+)
+
+// Reasons an ancestor can be left out of the analysis.
+const (
+	NoBirthDateExclusion    = "no birth date"
+	NoDeathDateExclusion    = "no death date"
+	BadBirthDateExclusion   = "unparseable birth date"
+	BadDeathDateExclusion   = "unparseable death date"
+	NoStatsForYearExclusion = "no stats for year"
+	UnknownSexExclusion     = "unknown sex"
+)
+
+// Exclusion records an ancestor who was left out of the analysis and why.
+type Exclusion struct {
+	Xref               string
+	Name               string
+	GenerationsRemoved int
+	Paths              AncestorPaths
+	Reason             string
+	Detail             string
+}
+
+func newExclusion(individual *gedcom.IndividualRecord, paths AncestorPaths, reason string, detail string) Exclusion {
+	return Exclusion{
+		Xref:               individual.Xref,
+		Name:               IndividualName(individual),
+		GenerationsRemoved: nearestGeneration(paths),
+		Paths:              paths,
+		Reason:             reason,
+		Detail:             detail,
+	}
+}
+
+// dateExclusion explains why no date could be found for the event with the given tag: either none of
+// the event or its fallbacks have a date, or the first date there is couldn't be parsed.
+func dateExclusion(individual *gedcom.IndividualRecord, paths AncestorPaths, tag string, fallbacks []EventFallback) Exclusion {
+	missing, unparseable := NoDeathDateExclusion, BadDeathDateExclusion
+	if tag == "BIRT" {
+		missing, unparseable = NoBirthDateExclusion, BadBirthDateExclusion
+	}
+
+	tags := []string{tag}
+	for _, fallback := range fallbacks {
+		tags = append(tags, fallback.Tag)
+	}
+	for _, eventTag := range tags {
+		for _, event := range individual.Event {
+			if event.Tag != eventTag || strings.TrimSpace(event.Date) == "" {
+				continue
+			}
+			_, err := ParseDateValue(event.Date)
+			return newExclusion(individual, paths, unparseable, fmt.Sprintf("%s date '%s': %s", eventTag, event.Date, err))
+		}
+	}
+	return newExclusion(individual, paths, missing, "no dated "+strings.Join(tags, ", ")+" event")
+}
+
+func sortExclusions(exclusions []Exclusion) {
+	sort.SliceStable(exclusions, func(i, j int) bool {
+		if exclusions[i].GenerationsRemoved != exclusions[j].GenerationsRemoved {
+			return exclusions[i].GenerationsRemoved < exclusions[j].GenerationsRemoved
+		}
+		return exclusions[i].Xref < exclusions[j].Xref
+	})
+}
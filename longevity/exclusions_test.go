@@ -0,0 +1,97 @@
+package longevity
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const exclusionsTestTree = `0 HEAD
+0 @I1@ INDI
+1 NAME Subject /Smith/
+1 FAMC @F1@
+0 @I2@ INDI
+1 NAME Living /Smith/
+1 SEX M
+1 BIRT
+2 DATE 1900
+1 FAMS @F1@
+0 @I3@ INDI
+1 NAME Muddled /Jones/
+1 SEX F
+1 BIRT
+2 DATE the spring after the flood
+1 DEAT
+2 DATE 1950
+1 FAMS @F1@
+1 FAMC @F2@
+0 @I4@ INDI
+1 NAME Early /Jones/
+1 SEX M
+1 BIRT
+2 DATE 1700
+1 DEAT
+2 DATE 1760
+1 FAMS @F2@
+0 @I5@ INDI
+1 NAME Unknown /Brown/
+1 SEX F
+1 FAMS @F2@
+0 @F1@ FAM
+1 HUSB @I2@
+1 WIFE @I3@
+1 CHIL @I1@
+0 @F2@ FAM
+1 HUSB @I4@
+1 WIFE @I5@
+1 CHIL @I3@
+0 TRLR
+`
+
+func TestGetDeathStatsForAncestorsExclusions(t *testing.T) {
+	g := decodeTestTree(t, exclusionsTestTree)
+	ancestors, err := GetAncestors(individualsByXref(g)["I1"])
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	registry := ReferenceRegistry{DefaultCountry: {
+		MaleDeathStats:   []DeathStat{{Year: "1950"}},
+		FemaleDeathStats: []DeathStat{{Year: "1950"}},
+	}}
+
+	deaths, exclusions := getDeathStatsForAncestors(nil, ancestors, registry, Options{DeathFallbacks: []EventFallback{{Tag: "BURI", DelayDays: 4}}})
+	if len(deaths) != 0 {
+		t.Errorf("expected every ancestor to be excluded, got %d", len(deaths))
+	}
+
+	type summary struct {
+		xref, reason string
+		generation   int
+	}
+	var got []summary
+	for _, exclusion := range exclusions {
+		got = append(got, summary{exclusion.Xref, exclusion.Reason, exclusion.GenerationsRemoved})
+	}
+	want := []summary{
+		{"I2", NoDeathDateExclusion, 1},
+		{"I3", BadBirthDateExclusion, 1},
+		{"I4", NoStatsForYearExclusion, 2},
+		{"I5", NoBirthDateExclusion, 2},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+
+	if exclusions[0].Detail != "no dated DEAT, BURI event" {
+		t.Errorf("unexpected detail for a missing date: %s", exclusions[0].Detail)
+	}
+	if !strings.HasPrefix(exclusions[1].Detail, "BIRT date 'the spring after the flood'") {
+		t.Errorf("unexpected detail for an unparseable date: %s", exclusions[1].Detail)
+	}
+	if exclusions[2].Detail != "no GBRTENW stats for men died in 1760" {
+		t.Errorf("unexpected detail for a year without stats: %s", exclusions[2].Detail)
+	}
+	if exclusions[1].Name != "Muddled Jones" {
+		t.Errorf("expected the excluded ancestor's name, got %q", exclusions[1].Name)
+	}
+}
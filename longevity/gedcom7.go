@@ -0,0 +1,103 @@
+package longevity
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/iand/gedcom"
+)
+
+// voidPointer stands in GEDCOM 7 for a link to an individual or family that isn't in the file, such
+// as the unknown father in "1 HUSB @VOID@".
+const voidPointer = "@VOID@"
+
+var lineBreakRegex = regexp.MustCompile(`\r\n|\r|\n`)
+
+type gedcomLine struct {
+	level int
+	tag   string
+	value string
+}
+
+// parseGedcomLine splits a line into its level, tag and value, skipping any xref.
+func parseGedcomLine(line string) (gedcomLine, bool) {
+	levelStr, rest, ok := strings.Cut(line, " ")
+	if !ok {
+		return gedcomLine{}, false
+	}
+	level, err := strconv.Atoi(levelStr)
+	if err != nil {
+		return gedcomLine{}, false
+	}
+	if strings.HasPrefix(rest, "@") {
+		_, rest, _ = strings.Cut(rest, " ")
+	}
+	tag, value, _ := strings.Cut(rest, " ")
+	return gedcomLine{level: level, tag: tag, value: value}, tag != ""
+}
+
+// endOfStructure returns the index of the first line after the structure starting at the given
+// line, that is the next line at the same level or above.
+func endOfStructure(lines []string, start int, level int) int {
+	for i := start + 1; i < len(lines); i++ {
+		if line, ok := parseGedcomLine(lines[i]); ok && line.level <= level {
+			return i
+		}
+	}
+	return len(lines)
+}
+
+// foldDatePhrase combines a GEDCOM 7 date with its PHRASE the way GEDCOM 5.5.1 wrote them, as an
+// interpreted date or, with no date, a date phrase on its own.
+func foldDatePhrase(date string, phrase string) string {
+	if date == "" {
+		return "(" + phrase + ")"
+	}
+	return "INT " + date + " (" + phrase + ")"
+}
+
+// convertGedcom7 rewrites the parts of a GEDCOM 7 file the decoder would misread as their GEDCOM
+// 5.5.1 equivalents. Links to @VOID@ are dropped along with their substructures rather than making
+// every unknown parent the same made-up individual, and a date's PHRASE is folded into the date.
+// Everything else, such as SNOTE records and SCHMA extension tags, is kept for the decoder to store
+// as user-defined tags, and GEDCOM 7 calendars are understood by ParseDateValue.
+func convertGedcom7(data []byte) []byte {
+	lines := lineBreakRegex.Split(strings.TrimPrefix(string(data), byteOrderMark), -1)
+	converted := make([]string, 0, len(lines))
+	for i := 0; i < len(lines); i++ {
+		line, ok := parseGedcomLine(lines[i])
+		switch {
+		case !ok:
+			converted = append(converted, lines[i])
+		case line.value == voidPointer:
+			i = endOfStructure(lines, i, line.level) - 1
+		case line.tag == "DATE":
+			end := endOfStructure(lines, i, line.level)
+			var phrase string
+			var substructures []string
+			for j := i + 1; j < end; j++ {
+				if child, ok := parseGedcomLine(lines[j]); ok && child.level == line.level+1 && child.tag == "PHRASE" {
+					phrase = child.value
+					continue
+				}
+				substructures = append(substructures, lines[j])
+			}
+			if phrase == "" {
+				converted = append(converted, lines[i])
+			} else {
+				converted = append(converted, fmt.Sprintf("%d DATE %s", line.level, foldDatePhrase(line.value, phrase)))
+			}
+			converted = append(converted, substructures...)
+			i = end - 1
+		default:
+			converted = append(converted, lines[i])
+		}
+	}
+	return []byte(strings.Join(converted, "\n"))
+}
+
+func decodeGedcom7(data []byte) (*gedcom.Gedcom, error) {
+	return decodeGedcom(convertGedcom7(data))
+}
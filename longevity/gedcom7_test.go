@@ -0,0 +1,39 @@
+package longevity
+
+import "testing"
+
+func TestConvertGedcom7(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{
+			name:  "void pointer",
+			input: "0 @F1@ FAM\r\n1 HUSB @VOID@\r\n2 PHRASE Unknown\r\n1 WIFE @I2@\r\n",
+			want:  "0 @F1@ FAM\n1 WIFE @I2@\n",
+		},
+		{
+			name:  "date with phrase",
+			input: "1 BIRT\n2 DATE ABT 1850\n3 PHRASE Around the census\n3 TIME 10:00\n2 PLAC London\n",
+			want:  "1 BIRT\n2 DATE INT ABT 1850 (Around the census)\n3 TIME 10:00\n2 PLAC London\n",
+		},
+		{
+			name:  "phrase only",
+			input: "1 DEAT\n2 DATE\n3 PHRASE In the war\n",
+			want:  "1 DEAT\n2 DATE (In the war)\n",
+		},
+		{
+			name:  "unchanged",
+			input: "\ufeff0 HEAD\n1 SCHMA\n2 TAG _LIVE https://example.com/living\n0 @N1@ SNOTE A note\n1 DEAT\n2 DATE JULIAN 1700\n",
+			want:  "0 HEAD\n1 SCHMA\n2 TAG _LIVE https://example.com/living\n0 @N1@ SNOTE A note\n1 DEAT\n2 DATE JULIAN 1700\n",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := string(convertGedcom7([]byte(test.input))); got != test.want {
+				t.Errorf("got %q, want %q", got, test.want)
+			}
+		})
+	}
+}
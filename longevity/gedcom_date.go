@@ -0,0 +1,319 @@
+package longevity
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DateValue is a GEDCOM date value resolved to the interval it could fall in. Estimate is the single
+// date used where a point is needed: the date itself for exact and approximate dates, and the
+// midpoint of the interval for ranges and open-ended dates.
+type DateValue struct {
+	Qualifier string
+	Phrase    string
+	Earliest  time.Time
+	Latest    time.Time
+	Estimate  time.Time
+}
+
+// approximateMarginYears is how far either side of an ABT, CAL or EST date the true date may fall.
+var approximateMarginYears = map[string]int{
+	"ABT": 2,
+	"CAL": 1,
+	"EST": 3,
+}
+
+// openEndedMarginYears bounds the open side of BEF, AFT, FROM and TO dates.
+const openEndedMarginYears = 10
+
+var qualifierAliases = map[string]string{
+	"ABT":        "ABT",
+	"ABOUT":      "ABT",
+	"AROUND":     "ABT",
+	"C":          "ABT",
+	"CA":         "ABT",
+	"CIRCA":      "ABT",
+	"CAL":        "CAL",
+	"CALC":       "CAL",
+	"CALCULATED": "CAL",
+	"EST":        "EST",
+	"ESTIMATED":  "EST",
+	"BEF":        "BEF",
+	"BEFORE":     "BEF",
+	"AFT":        "AFT",
+	"AFTER":      "AFT",
+	"BET":        "BET",
+	"BETWEEN":    "BET",
+	"BTW":        "BET",
+	"FROM":       "FROM",
+	"TO":         "TO",
+	"INT":        "INT",
+}
+
+var (
+	calendarEscapeRegex     = regexp.MustCompile(`^(?:@#D([A-Z ]+)@|(GREGORIAN|JULIAN|FRENCH_R|HEBREW)\b)\s*`)
+	interpretedDateRegex    = regexp.MustCompile(`^(.*?)\s*\((.*)\)$`)
+	betweenSeparatorRegex   = regexp.MustCompile(`(?i)\s+(?:and|&)\s+`)
+	periodSeparatorRegex    = regexp.MustCompile(`(?i)\s+to\s+`)
+	legacyYearRangeRegex    = regexp.MustCompile(`^(\d{4})\s*-\s*(\d{4})$`)
+	dualYearRegex           = regexp.MustCompile(`\b(\d{2})(\d{2})/(\d{2})\b`)
+	standardDatePartRegex   = regexp.MustCompile(`^(?:(\d{1,2})\s+)?(?:([A-Za-z]+)\s+)?(\d{4})$`)
+	datePhraseOnlyRegex     = regexp.MustCompile(`^\(.*\)$`)
+	qualifierFirstWordRegex = regexp.MustCompile(`^([A-Za-z]+)\.?(?:\s+|$)`)
+)
+
+// julianOffsetDays returns how many days the Gregorian calendar runs ahead of the Julian one on a
+// Julian date. The gap widens on 29 February of century years that are not Gregorian leap years, so
+// January and February of those years still take the previous century's offset.
+func julianOffsetDays(year int, month time.Month) int {
+	if month <= time.February {
+		year--
+	}
+	return year/100 - year/400 - 2
+}
+
+// daysInMonth returns the length of a month in the Julian or Gregorian calendar.
+func daysInMonth(year int, month time.Month, julian bool) int {
+	if julian && month == time.February && year%4 == 0 {
+		return 29
+	}
+	return time.Date(year, month+1, 0, 0, 0, 0, 0, time.UTC).Day()
+}
+
+// calendarDate returns the Gregorian date of a day in the Julian or Gregorian calendar. A Julian
+// 29 February that the Gregorian calendar lacks rolls over to 1 March before the offset is added,
+// which lands it on the right day.
+func calendarDate(year int, month time.Month, day int, julian bool) time.Time {
+	date := time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+	if julian {
+		date = date.AddDate(0, 0, julianOffsetDays(year, month))
+	}
+	return date
+}
+
+// midpoint returns the day halfway between start and end, dropping any half day.
+func midpoint(start time.Time, end time.Time) time.Time {
+	middle := start.Add(end.Sub(start) / 2)
+	return time.Date(middle.Year(), middle.Month(), middle.Day(), 0, 0, 0, 0, start.Location())
+}
+
+func monthNumber(name string) (time.Month, bool) {
+	for i, month := range months {
+		if strings.EqualFold(name, month) {
+			return time.Month(i + 1), true
+		}
+	}
+	return 0, false
+}
+
+// parseCalendar strips a leading calendar from a date, either a GEDCOM 5.5.1 escape such as
+// @#DJULIAN@ or a GEDCOM 7 keyword such as JULIAN, and reports whether the date is Julian. A date
+// with no calendar of its own keeps the one it was given.
+func parseCalendar(dateStr string, julian bool) (string, bool, error) {
+	matches := calendarEscapeRegex.FindStringSubmatch(dateStr)
+	if matches == nil {
+		return dateStr, julian, nil
+	}
+	calendar := strings.TrimSpace(matches[1] + matches[2])
+	switch calendar {
+	case "GREGORIAN":
+		julian = false
+	case "JULIAN":
+		julian = true
+	default:
+		return "", false, fmt.Errorf("unsupported calendar '%s' in date %s", calendar, dateStr)
+	}
+	return dateStr[len(matches[0]):], julian, nil
+}
+
+// parseDatePart parses a single date with no qualifier, returning the first and last day it could
+// refer to and the date assumed when a single day is needed (the first day of a month or year).
+// GEDCOM 7 puts the calendar on each date, as in BET JULIAN 1700 AND 1710, so a part may switch it.
+func parseDatePart(dateStr string, julian bool) (DateValue, error) {
+	dateStr, julian, err := parseCalendar(strings.TrimSpace(dateStr), julian)
+	if err != nil {
+		return DateValue{}, err
+	}
+	if err := checkValidYear(dateStr); err != nil {
+		return DateValue{}, fmt.Errorf("no valid year found in date: %s", err)
+	}
+
+	if matches := dualYearRegex.FindStringSubmatch(dateStr); matches != nil {
+		century, _ := strconv.Atoi(matches[1])
+		yearInCentury, _ := strconv.Atoi(matches[2])
+		dualYear, _ := strconv.Atoi(matches[3])
+		if dualYear < yearInCentury {
+			century++
+		}
+		dateStr = strings.Replace(dateStr, matches[0], strconv.Itoa(century*100+dualYear), 1)
+	}
+
+	var part DateValue
+	cleaned := cleanDate(dateStr)
+	if matches := standardDatePartRegex.FindStringSubmatch(cleaned); matches != nil {
+		year, _ := strconv.Atoi(matches[3])
+		switch {
+		case matches[2] == "":
+			part.Earliest = calendarDate(year, time.January, 1, julian)
+			part.Latest = calendarDate(year, time.December, 31, julian)
+		default:
+			month, ok := monthNumber(matches[2])
+			if !ok {
+				return DateValue{}, fmt.Errorf("unknown month '%s' in date %s", matches[2], dateStr)
+			}
+			if matches[1] == "" {
+				part.Earliest = calendarDate(year, month, 1, julian)
+				part.Latest = calendarDate(year, month, daysInMonth(year, month, julian), julian)
+			} else {
+				day, _ := strconv.Atoi(matches[1])
+				if day < 1 || day > daysInMonth(year, month, julian) {
+					return DateValue{}, fmt.Errorf("invalid day in date %s", dateStr)
+				}
+				part.Earliest = calendarDate(year, month, day, julian)
+				part.Latest = part.Earliest
+			}
+		}
+		part.Estimate = part.Earliest
+	} else {
+		parsedDate, err := ParseDate(dateStr)
+		if err != nil {
+			return DateValue{}, err
+		}
+		if julian {
+			parsedDate = parsedDate.AddDate(0, 0, julianOffsetDays(parsedDate.Year(), parsedDate.Month()))
+		}
+		part.Earliest, part.Latest, part.Estimate = parsedDate, parsedDate, parsedDate
+	}
+	return part, nil
+}
+
+func parseDateRange(qualifier string, startStr string, endStr string, julian bool) (DateValue, error) {
+	start, err := parseDatePart(startStr, julian)
+	if err != nil {
+		return DateValue{}, err
+	}
+	end, err := parseDatePart(endStr, julian)
+	if err != nil {
+		return DateValue{}, err
+	}
+	if end.Latest.Before(start.Earliest) {
+		return DateValue{}, fmt.Errorf("date range ends before it starts: %s to %s", startStr, endStr)
+	}
+	return DateValue{
+		Qualifier: qualifier,
+		Earliest:  start.Earliest,
+		Latest:    end.Latest,
+		Estimate:  midpoint(start.Estimate, end.Estimate),
+	}, nil
+}
+
+// ParseDateValue parses any GEDCOM 5.5.1 date value (exact, ABT, CAL, EST, BEF, AFT, BET...AND,
+// FROM...TO and INT, with optional @#DGREGORIAN@ or @#DJULIAN@ escapes) or GEDCOM 7 date value
+// (with GREGORIAN or JULIAN before any date) as well as the informal dates accepted by ParseDate,
+// such as "About 1800" or "1905-1907".
+func ParseDateValue(dateStr string) (DateValue, error) {
+	dateStr = strings.TrimSpace(dateStr)
+	if dateStr == "" {
+		return DateValue{}, fmt.Errorf("date is empty")
+	}
+
+	dateStr, julian, err := parseCalendar(dateStr, false)
+	if err != nil {
+		return DateValue{}, err
+	}
+
+	if datePhraseOnlyRegex.MatchString(dateStr) {
+		return DateValue{}, fmt.Errorf("date phrase %s does not contain a date", dateStr)
+	}
+
+	qualifier := ""
+	rest := dateStr
+	if matches := qualifierFirstWordRegex.FindStringSubmatch(dateStr); matches != nil {
+		if alias, ok := qualifierAliases[strings.ToUpper(matches[1])]; ok {
+			qualifier = alias
+			rest = strings.TrimSpace(dateStr[len(matches[0]):])
+		}
+	}
+
+	switch qualifier {
+	case "":
+		if matches := legacyYearRangeRegex.FindStringSubmatch(rest); matches != nil {
+			return parseDateRange("BET", matches[1], matches[2], julian)
+		}
+		return parseDatePart(rest, julian)
+
+	case "ABT", "CAL", "EST":
+		part, err := parseDatePart(rest, julian)
+		if err != nil {
+			return DateValue{}, err
+		}
+		margin := approximateMarginYears[qualifier]
+		part.Qualifier = qualifier
+		part.Earliest = part.Earliest.AddDate(-margin, 0, 0)
+		part.Latest = part.Latest.AddDate(margin, 0, 0)
+		return part, nil
+
+	case "BEF", "TO":
+		part, err := parseDatePart(rest, julian)
+		if err != nil {
+			return DateValue{}, err
+		}
+		latest := part.Latest
+		if qualifier == "BEF" {
+			latest = part.Earliest.AddDate(0, 0, -1)
+		}
+		earliest := latest.AddDate(-openEndedMarginYears, 0, 0)
+		return DateValue{Qualifier: qualifier, Earliest: earliest, Latest: latest, Estimate: midpoint(earliest, latest)}, nil
+
+	case "AFT":
+		part, err := parseDatePart(rest, julian)
+		if err != nil {
+			return DateValue{}, err
+		}
+		earliest := part.Latest.AddDate(0, 0, 1)
+		latest := earliest.AddDate(openEndedMarginYears, 0, 0)
+		return DateValue{Qualifier: qualifier, Earliest: earliest, Latest: latest, Estimate: midpoint(earliest, latest)}, nil
+
+	case "BET":
+		bounds := betweenSeparatorRegex.Split(rest, 2)
+		if len(bounds) != 2 {
+			if matches := legacyYearRangeRegex.FindStringSubmatch(rest); matches != nil {
+				bounds = matches[1:]
+			} else {
+				return DateValue{}, fmt.Errorf("date range %s is missing AND", dateStr)
+			}
+		}
+		return parseDateRange(qualifier, bounds[0], bounds[1], julian)
+
+	case "FROM":
+		bounds := periodSeparatorRegex.Split(rest, 2)
+		if len(bounds) == 2 {
+			return parseDateRange(qualifier, bounds[0], bounds[1], julian)
+		}
+		part, err := parseDatePart(rest, julian)
+		if err != nil {
+			return DateValue{}, err
+		}
+		earliest := part.Earliest
+		latest := earliest.AddDate(openEndedMarginYears, 0, 0)
+		return DateValue{Qualifier: qualifier, Earliest: earliest, Latest: latest, Estimate: midpoint(earliest, latest)}, nil
+
+	case "INT":
+		matches := interpretedDateRegex.FindStringSubmatch(rest)
+		if matches == nil {
+			return DateValue{}, fmt.Errorf("interpreted date %s is missing its phrase", dateStr)
+		}
+		part, err := ParseDateValue(matches[1])
+		if err != nil {
+			return DateValue{}, err
+		}
+		part.Qualifier = qualifier
+		part.Phrase = matches[2]
+		return part, nil
+	}
+
+	return DateValue{}, fmt.Errorf("unsupported date qualifier '%s' in date %s", qualifier, dateStr)
+}
@@ -0,0 +1,64 @@
+package longevity
+
+import (
+	"testing"
+	"time"
+)
+
+func utcDate(year int, month time.Month, day int) time.Time {
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+func TestParseDateValue(t *testing.T) {
+	testCases := map[string]DateValue{
+		"12 MAR 1850":               {Earliest: utcDate(1850, 3, 12), Latest: utcDate(1850, 3, 12), Estimate: utcDate(1850, 3, 12)},
+		"MAR 1850":                  {Earliest: utcDate(1850, 3, 1), Latest: utcDate(1850, 3, 31), Estimate: utcDate(1850, 3, 1)},
+		"1850":                      {Earliest: utcDate(1850, 1, 1), Latest: utcDate(1850, 12, 31), Estimate: utcDate(1850, 1, 1)},
+		"21st June 1850":            {Earliest: utcDate(1850, 6, 21), Latest: utcDate(1850, 6, 21), Estimate: utcDate(1850, 6, 21)},
+		"ABT 1820":                  {Qualifier: "ABT", Earliest: utcDate(1818, 1, 1), Latest: utcDate(1822, 12, 31), Estimate: utcDate(1820, 1, 1)},
+		"abt. 1800":                 {Qualifier: "ABT", Earliest: utcDate(1798, 1, 1), Latest: utcDate(1802, 12, 31), Estimate: utcDate(1800, 1, 1)},
+		"CAL 1 JAN 1900":            {Qualifier: "CAL", Earliest: utcDate(1899, 1, 1), Latest: utcDate(1901, 1, 1), Estimate: utcDate(1900, 1, 1)},
+		"EST 1900":                  {Qualifier: "EST", Earliest: utcDate(1897, 1, 1), Latest: utcDate(1903, 12, 31), Estimate: utcDate(1900, 1, 1)},
+		"BEF 1890":                  {Qualifier: "BEF", Earliest: utcDate(1879, 12, 31), Latest: utcDate(1889, 12, 31), Estimate: utcDate(1884, 12, 30)},
+		"AFT 1890":                  {Qualifier: "AFT", Earliest: utcDate(1891, 1, 1), Latest: utcDate(1901, 1, 1), Estimate: utcDate(1896, 1, 1)},
+		"BET 1880 AND 1885":         {Qualifier: "BET", Earliest: utcDate(1880, 1, 1), Latest: utcDate(1885, 12, 31), Estimate: utcDate(1882, 7, 2)},
+		"FROM MAR 1900 TO MAY 1900": {Qualifier: "FROM", Earliest: utcDate(1900, 3, 1), Latest: utcDate(1900, 5, 31), Estimate: utcDate(1900, 3, 31)},
+		"TO 1900":                   {Qualifier: "TO", Earliest: utcDate(1890, 12, 31), Latest: utcDate(1900, 12, 31), Estimate: utcDate(1895, 12, 31)},
+		"1905-1907":                 {Qualifier: "BET", Earliest: utcDate(1905, 1, 1), Latest: utcDate(1907, 12, 31), Estimate: utcDate(1906, 1, 1)},
+		"INT 1850 (about the time of the census)": {Qualifier: "INT", Phrase: "about the time of the census", Earliest: utcDate(1850, 1, 1), Latest: utcDate(1850, 12, 31), Estimate: utcDate(1850, 1, 1)},
+		"@#DJULIAN@ 1 MAR 1700":                   {Earliest: utcDate(1700, 3, 12), Latest: utcDate(1700, 3, 12), Estimate: utcDate(1700, 3, 12)},
+		"@#DJULIAN@ 1 FEB 1700":                   {Earliest: utcDate(1700, 2, 11), Latest: utcDate(1700, 2, 11), Estimate: utcDate(1700, 2, 11)},
+		"@#DJULIAN@ 29 FEB 1700":                  {Earliest: utcDate(1700, 3, 11), Latest: utcDate(1700, 3, 11), Estimate: utcDate(1700, 3, 11)},
+		"@#DJULIAN@ FEB 1700":                     {Earliest: utcDate(1700, 2, 11), Latest: utcDate(1700, 3, 11), Estimate: utcDate(1700, 2, 11)},
+		"@#DGREGORIAN@ 1 MAR 1700":                {Earliest: utcDate(1700, 3, 1), Latest: utcDate(1700, 3, 1), Estimate: utcDate(1700, 3, 1)},
+		"1 FEB 1750/51":                           {Earliest: utcDate(1751, 2, 1), Latest: utcDate(1751, 2, 1), Estimate: utcDate(1751, 2, 1)},
+		"JULIAN 1 MAR 1700":                       {Earliest: utcDate(1700, 3, 12), Latest: utcDate(1700, 3, 12), Estimate: utcDate(1700, 3, 12)},
+		"BET JULIAN 1 MAR 1700 AND 20 MAR 1700":   {Qualifier: "BET", Earliest: utcDate(1700, 3, 12), Latest: utcDate(1700, 3, 20), Estimate: utcDate(1700, 3, 16)},
+	}
+
+	for dateStr, expected := range testCases {
+		t.Run(dateStr, func(t *testing.T) {
+			parsed, err := ParseDateValue(dateStr)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if parsed.Qualifier != expected.Qualifier || parsed.Phrase != expected.Phrase {
+				t.Errorf("expected qualifier %q and phrase %q, got %q and %q", expected.Qualifier, expected.Phrase, parsed.Qualifier, parsed.Phrase)
+			}
+			if !parsed.Earliest.Equal(expected.Earliest) || !parsed.Latest.Equal(expected.Latest) {
+				t.Errorf("expected interval %s to %s, got %s to %s", expected.Earliest, expected.Latest, parsed.Earliest, parsed.Latest)
+			}
+			if !parsed.Estimate.Equal(expected.Estimate) {
+				t.Errorf("expected estimate %s, got %s", expected.Estimate, parsed.Estimate)
+			}
+		})
+	}
+}
+
+func TestParseDateValueErrors(t *testing.T) {
+	for _, dateStr := range []string{"", "(sometime in spring)", "BET 1885 AND 1880", "BET 1880", "@#DHEBREW@ 5600", "FRENCH_R 1 VEND 10", "31 FEB 1900", "not a date"} {
+		if _, err := ParseDateValue(dateStr); err == nil {
+			t.Errorf("expected an error parsing %q", dateStr)
+		}
+	}
+}
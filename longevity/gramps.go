@@ -0,0 +1,350 @@
+package longevity
+
+import (
+	"encoding/xml"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/iand/gedcom"
+)
+
+// Gramps XML (https://gramps-project.org/wiki/index.php/Gramps_XML) links records by handle and
+// keeps places as a hierarchy. The structs below hold just the parts the analysis needs, which
+// decodeGramps turns into the same records the GEDCOM decoder produces.
+type grampsDatabase struct {
+	Events   []grampsEvent  `xml:"events>event"`
+	People   grampsPeople   `xml:"people"`
+	Families []grampsFamily `xml:"families>family"`
+	Places   []grampsPlace  `xml:"places>placeobj"`
+}
+
+type grampsRef struct {
+	Hlink string `xml:"hlink,attr"`
+}
+
+type grampsEventRef struct {
+	Hlink string `xml:"hlink,attr"`
+	Role  string `xml:"role,attr"`
+}
+
+type grampsDateVal struct {
+	Val      string `xml:"val,attr"`
+	Type     string `xml:"type,attr"`
+	Quality  string `xml:"quality,attr"`
+	Calendar string `xml:"cformat,attr"`
+}
+
+type grampsDateRange struct {
+	Start    string `xml:"start,attr"`
+	Stop     string `xml:"stop,attr"`
+	Calendar string `xml:"cformat,attr"`
+}
+
+type grampsDateStr struct {
+	Val string `xml:"val,attr"`
+}
+
+type grampsEvent struct {
+	Handle    string           `xml:"handle,attr"`
+	Type      string           `xml:"type"`
+	DateVal   *grampsDateVal   `xml:"dateval"`
+	DateRange *grampsDateRange `xml:"daterange"`
+	DateSpan  *grampsDateRange `xml:"datespan"`
+	DateStr   *grampsDateStr   `xml:"datestr"`
+	Place     grampsRef        `xml:"place"`
+}
+
+type grampsSurname struct {
+	Prefix string `xml:"prefix,attr"`
+	Name   string `xml:",chardata"`
+}
+
+type grampsName struct {
+	Alt      string          `xml:"alt,attr"`
+	First    string          `xml:"first"`
+	Surnames []grampsSurname `xml:"surname"`
+	Suffix   string          `xml:"suffix"`
+}
+
+type grampsPeople struct {
+	Home   string         `xml:"home,attr"`
+	People []grampsPerson `xml:"person"`
+}
+
+type grampsPerson struct {
+	Handle    string           `xml:"handle,attr"`
+	ID        string           `xml:"id,attr"`
+	Gender    string           `xml:"gender"`
+	Names     []grampsName     `xml:"name"`
+	EventRefs []grampsEventRef `xml:"eventref"`
+	ChildOf   []grampsRef      `xml:"childof"`
+	ParentIn  []grampsRef      `xml:"parentin"`
+}
+
+type grampsFamily struct {
+	Handle    string           `xml:"handle,attr"`
+	ID        string           `xml:"id,attr"`
+	Father    grampsRef        `xml:"father"`
+	Mother    grampsRef        `xml:"mother"`
+	EventRefs []grampsEventRef `xml:"eventref"`
+	Children  []grampsRef      `xml:"childref"`
+}
+
+type grampsPname struct {
+	Value string `xml:"value,attr"`
+}
+
+type grampsPlace struct {
+	Handle   string        `xml:"handle,attr"`
+	Title    string        `xml:"ptitle"`
+	Names    []grampsPname `xml:"pname"`
+	Enclosed []grampsRef   `xml:"placeref"`
+}
+
+// grampsEventTags maps Gramps event types to the GEDCOM tags the analysis looks for. Any other type
+// becomes an EVEN with the Gramps type as its TYPE.
+var grampsEventTags = map[string]string{
+	"Birth":             "BIRT",
+	"Death":             "DEAT",
+	"Burial":            "BURI",
+	"Cremation":         "CREM",
+	"Christening":       "CHR",
+	"Baptism":           "BAPM",
+	"Adult Christening": "CHRA",
+	"Adopted":           "ADOP",
+	"Bar Mitzvah":       "BARM",
+	"Bas Mitzvah":       "BASM",
+	"Blessing":          "BLES",
+	"Confirmation":      "CONF",
+	"First Communion":   "FCOM",
+	"Ordination":        "ORDN",
+	"Naturalization":    "NATU",
+	"Emigration":        "EMIG",
+	"Immigration":       "IMMI",
+	"Census":            "CENS",
+	"Probate":           "PROB",
+	"Will":              "WILL",
+	"Graduation":        "GRAD",
+	"Retirement":        "RETI",
+	"Marriage":          "MARR",
+	"Marriage Banns":    "MARB",
+	"Marriage Contract": "MARC",
+	"Marriage License":  "MARL",
+	"Engagement":        "ENGA",
+	"Divorce":           "DIV",
+	"Divorce Filing":    "DIVF",
+	"Annulment":         "ANUL",
+}
+
+// grampsDateModifiers maps the type of a Gramps dateval to the GEDCOM qualifier with the same
+// meaning.
+var grampsDateModifiers = map[string]string{
+	"before": "BEF",
+	"after":  "AFT",
+	"about":  "ABT",
+	"from":   "FROM",
+	"to":     "TO",
+}
+
+// grampsDateQualities maps the quality of a Gramps date with no modifier to a GEDCOM qualifier.
+var grampsDateQualities = map[string]string{
+	"estimated":  "EST",
+	"calculated": "CAL",
+}
+
+// grampsDatePart turns a Gramps date such as 1850-03-12, 1850-03 or 1850 into a GEDCOM date. A zero
+// month or day is unknown, and a date with no year gives "".
+func grampsDatePart(val string) string {
+	parts := strings.Split(val, "-")
+	year, err := strconv.Atoi(parts[0])
+	if err != nil || year == 0 {
+		return ""
+	}
+	date := fmt.Sprintf("%04d", year)
+	if len(parts) < 2 {
+		return date
+	}
+	month, err := strconv.Atoi(parts[1])
+	if err != nil || month < 1 || month > len(months) {
+		return date
+	}
+	date = strings.ToUpper(months[month-1][:3]) + " " + date
+	if len(parts) < 3 {
+		return date
+	}
+	if day, err := strconv.Atoi(parts[2]); err == nil && day > 0 {
+		date = strconv.Itoa(day) + " " + date
+	}
+	return date
+}
+
+// grampsCalendar returns the GEDCOM calendar escape for a Gramps calendar, so that Julian dates are
+// converted and dates in calendars ParseDateValue doesn't know are reported as such.
+func grampsCalendar(calendar string) string {
+	if calendar == "" || strings.EqualFold(calendar, "Gregorian") {
+		return ""
+	}
+	return "@#D" + strings.ToUpper(calendar) + "@ "
+}
+
+// grampsDate writes an event's Gramps date as the equivalent GEDCOM date value. Ranges and spans
+// become BET...AND and FROM...TO, modifiers and quality flags become qualifiers (a modifier wins, as
+// GEDCOM can't combine them) and a text-only date becomes a date phrase.
+func grampsDate(event grampsEvent) string {
+	switch {
+	case event.DateVal != nil:
+		date := grampsDatePart(event.DateVal.Val)
+		if date == "" {
+			return ""
+		}
+		if qualifier, ok := grampsDateModifiers[event.DateVal.Type]; ok {
+			date = qualifier + " " + date
+		} else if qualifier, ok := grampsDateQualities[event.DateVal.Quality]; ok {
+			date = qualifier + " " + date
+		}
+		return grampsCalendar(event.DateVal.Calendar) + date
+	case event.DateRange != nil:
+		return grampsCalendar(event.DateRange.Calendar) + "BET " + grampsDatePart(event.DateRange.Start) + " AND " + grampsDatePart(event.DateRange.Stop)
+	case event.DateSpan != nil:
+		return grampsCalendar(event.DateSpan.Calendar) + "FROM " + grampsDatePart(event.DateSpan.Start) + " TO " + grampsDatePart(event.DateSpan.Stop)
+	case event.DateStr != nil && event.DateStr.Val != "":
+		return "(" + event.DateStr.Val + ")"
+	}
+	return ""
+}
+
+// grampsPlaceName returns a place's title, or failing that its name followed by the names of the
+// places enclosing it, such as "Leeds, Yorkshire, England".
+func grampsPlaceName(places map[string]grampsPlace, handle string) string {
+	place, ok := places[handle]
+	if !ok {
+		return ""
+	}
+	if place.Title != "" {
+		return place.Title
+	}
+	var names []string
+	seen := map[string]bool{}
+	for ok && !seen[handle] {
+		seen[handle] = true
+		if len(place.Names) > 0 && place.Names[0].Value != "" {
+			names = append(names, place.Names[0].Value)
+		}
+		if len(place.Enclosed) == 0 {
+			break
+		}
+		handle = place.Enclosed[0].Hlink
+		place, ok = places[handle]
+	}
+	return strings.Join(names, ", ")
+}
+
+// grampsPersonName writes a Gramps name the GEDCOM way, with slashes around the surname.
+func grampsPersonName(name grampsName) string {
+	var surnames []string
+	for _, surname := range name.Surnames {
+		surnames = append(surnames, strings.TrimSpace(surname.Prefix+" "+surname.Name))
+	}
+	full := strings.TrimSpace(name.First + " /" + strings.Join(surnames, " ") + "/")
+	if name.Suffix != "" {
+		full += " " + name.Suffix
+	}
+	return full
+}
+
+// grampsEvents returns the events someone took part in as the principal, leaving out those where
+// they were only a witness, officiant or the like so that attending a burial doesn't date a death.
+func grampsEvents(refs []grampsEventRef, events map[string]grampsEvent, places map[string]grampsPlace) []*gedcom.EventRecord {
+	var records []*gedcom.EventRecord
+	for _, ref := range refs {
+		if ref.Role != "" && ref.Role != "Primary" && ref.Role != "Family" {
+			continue
+		}
+		event, ok := events[ref.Hlink]
+		if !ok {
+			continue
+		}
+		record := &gedcom.EventRecord{Tag: "EVEN", Type: event.Type, Date: grampsDate(event)}
+		if tag, ok := grampsEventTags[event.Type]; ok {
+			record.Tag, record.Type = tag, ""
+		}
+		record.Place.Name = grampsPlaceName(places, event.Place.Hlink)
+		records = append(records, record)
+	}
+	return records
+}
+
+// decodeGramps reads a Gramps XML database into the records the GEDCOM decoder produces. The home
+// person comes first, so they're the subject unless another is chosen.
+func decodeGramps(data []byte) (*gedcom.Gedcom, error) {
+	var database grampsDatabase
+	if err := xml.Unmarshal(data, &database); err != nil {
+		return nil, fmt.Errorf("decoding Gramps XML: %v", err)
+	}
+
+	events := map[string]grampsEvent{}
+	for _, event := range database.Events {
+		events[event.Handle] = event
+	}
+	places := map[string]grampsPlace{}
+	for _, place := range database.Places {
+		places[place.Handle] = place
+	}
+
+	g := &gedcom.Gedcom{}
+	individuals := map[string]*gedcom.IndividualRecord{}
+	for _, person := range database.People.People {
+		individual := &gedcom.IndividualRecord{
+			Xref:  person.ID,
+			Sex:   person.Gender,
+			Event: grampsEvents(person.EventRefs, events, places),
+		}
+		for _, name := range person.Names {
+			record := &gedcom.NameRecord{Name: grampsPersonName(name)}
+			if name.Alt == "1" {
+				individual.Name = append(individual.Name, record)
+			} else {
+				individual.Name = append([]*gedcom.NameRecord{record}, individual.Name...)
+			}
+		}
+		individuals[person.Handle] = individual
+		if person.Handle == database.People.Home {
+			g.Individual = append([]*gedcom.IndividualRecord{individual}, g.Individual...)
+		} else {
+			g.Individual = append(g.Individual, individual)
+		}
+	}
+
+	families := map[string]*gedcom.FamilyRecord{}
+	for _, family := range database.Families {
+		record := &gedcom.FamilyRecord{
+			Xref:    family.ID,
+			Husband: individuals[family.Father.Hlink],
+			Wife:    individuals[family.Mother.Hlink],
+			Event:   grampsEvents(family.EventRefs, events, places),
+		}
+		for _, child := range family.Children {
+			if individual, ok := individuals[child.Hlink]; ok {
+				record.Child = append(record.Child, individual)
+			}
+		}
+		families[family.Handle] = record
+		g.Family = append(g.Family, record)
+	}
+
+	for _, person := range database.People.People {
+		individual := individuals[person.Handle]
+		for _, ref := range person.ChildOf {
+			if family, ok := families[ref.Hlink]; ok {
+				individual.Parents = append(individual.Parents, &gedcom.FamilyLinkRecord{Family: family})
+			}
+		}
+		for _, ref := range person.ParentIn {
+			if family, ok := families[ref.Hlink]; ok {
+				individual.Family = append(individual.Family, &gedcom.FamilyLinkRecord{Family: family})
+			}
+		}
+	}
+	return g, nil
+}
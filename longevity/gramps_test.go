@@ -0,0 +1,210 @@
+package longevity
+
+import (
+	"bytes"
+	"compress/gzip"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// grampsTestTree is the same family as gedcom5TestTree exported from Gramps, with the father's
+// mother known only by a text date and the mother a witness at a burial.
+const grampsTestTree = `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE database PUBLIC "-//Gramps//DTD Gramps XML 1.7.1//EN"
+"http://gramps-project.org/xml/1.7.1/grampsxml.dtd">
+<database xmlns="http://gramps-project.org/xml/1.7.1/">
+  <header>
+    <created date="2024-05-01" version="5.2.2"/>
+  </header>
+  <events>
+    <event handle="_e1" id="E0001">
+      <type>Birth</type>
+      <dateval val="1960" quality="estimated"/>
+    </event>
+    <event handle="_e2" id="E0002">
+      <type>Birth</type>
+      <dateval val="1900-01-01"/>
+    </event>
+    <event handle="_e3" id="E0003">
+      <type>Death</type>
+      <dateval val="1970-01-01"/>
+      <place hlink="_p1"/>
+    </event>
+    <event handle="_e4" id="E0004">
+      <type>Birth</type>
+      <dateval val="1905-01-01"/>
+    </event>
+    <event handle="_e5" id="E0005">
+      <type>Death</type>
+      <dateval val="1979-12-23" cformat="Julian"/>
+    </event>
+    <event handle="_e6" id="E0006">
+      <type>Birth</type>
+      <datestr val="The winter before the famine"/>
+    </event>
+    <event handle="_e7" id="E0007">
+      <type>Burial</type>
+      <dateval val="1950-06-01"/>
+    </event>
+  </events>
+  <people home="_i1">
+    <person handle="_i2" id="I2">
+      <gender>M</gender>
+      <name type="Birth Name">
+        <first>Tom</first>
+        <surname>Father</surname>
+      </name>
+      <eventref hlink="_e2" role="Primary"/>
+      <eventref hlink="_e3" role="Primary"/>
+      <childof hlink="_f2"/>
+      <parentin hlink="_f1"/>
+    </person>
+    <person handle="_i1" id="I1">
+      <gender>F</gender>
+      <name type="Birth Name">
+        <first>Ann</first>
+        <surname>Child</surname>
+      </name>
+      <eventref hlink="_e1" role="Primary"/>
+      <childof hlink="_f1"/>
+    </person>
+    <person handle="_i3" id="I3">
+      <gender>F</gender>
+      <name alt="1" type="Married Name">
+        <first>Sue</first>
+        <surname>Father</surname>
+      </name>
+      <name type="Birth Name">
+        <first>Sue</first>
+        <surname>Mother</surname>
+      </name>
+      <eventref hlink="_e4" role="Primary"/>
+      <eventref hlink="_e5" role="Primary"/>
+      <eventref hlink="_e7" role="Witness"/>
+      <parentin hlink="_f1"/>
+    </person>
+    <person handle="_i4" id="I4">
+      <gender>U</gender>
+      <name type="Birth Name">
+        <first>Grace</first>
+        <surname prefix="de">Father</surname>
+      </name>
+      <eventref hlink="_e6" role="Primary"/>
+      <parentin hlink="_f2"/>
+    </person>
+  </people>
+  <families>
+    <family handle="_f1" id="F1">
+      <rel type="Married"/>
+      <father hlink="_i2"/>
+      <mother hlink="_i3"/>
+      <childref hlink="_i1"/>
+    </family>
+    <family handle="_f2" id="F2">
+      <mother hlink="_i4"/>
+      <childref hlink="_i2"/>
+    </family>
+  </families>
+  <places>
+    <placeobj handle="_p1" id="P0001" type="City">
+      <pname value="London"/>
+      <placeref hlink="_p2"/>
+    </placeobj>
+    <placeobj handle="_p2" id="P0002" type="Country">
+      <pname value="England"/>
+    </placeobj>
+  </places>
+</database>
+`
+
+func gzipped(t *testing.T, content string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	writer := gzip.NewWriter(&buf)
+	writer.Write([]byte(content))
+	if err := writer.Close(); err != nil {
+		t.Fatalf("unexpected error compressing: %s", err)
+	}
+	return buf.Bytes()
+}
+
+func TestGrampsDate(t *testing.T) {
+	tests := []struct {
+		event grampsEvent
+		want  string
+	}{
+		{event: grampsEvent{DateVal: &grampsDateVal{Val: "1850-03-12"}}, want: "12 MAR 1850"},
+		{event: grampsEvent{DateVal: &grampsDateVal{Val: "1850-03"}}, want: "MAR 1850"},
+		{event: grampsEvent{DateVal: &grampsDateVal{Val: "1850-00-12"}}, want: "1850"},
+		{event: grampsEvent{DateVal: &grampsDateVal{Val: "0000-03-12"}}, want: ""},
+		{event: grampsEvent{DateVal: &grampsDateVal{Val: "1850", Type: "about"}}, want: "ABT 1850"},
+		{event: grampsEvent{DateVal: &grampsDateVal{Val: "1850", Type: "before", Quality: "estimated"}}, want: "BEF 1850"},
+		{event: grampsEvent{DateVal: &grampsDateVal{Val: "1850", Quality: "calculated"}}, want: "CAL 1850"},
+		{event: grampsEvent{DateVal: &grampsDateVal{Val: "1700-03-01", Calendar: "Julian"}}, want: "@#DJULIAN@ 1 MAR 1700"},
+		{event: grampsEvent{DateRange: &grampsDateRange{Start: "1880", Stop: "1885"}}, want: "BET 1880 AND 1885"},
+		{event: grampsEvent{DateSpan: &grampsDateRange{Start: "1900-03", Stop: "1900-05"}}, want: "FROM MAR 1900 TO MAY 1900"},
+		{event: grampsEvent{DateStr: &grampsDateStr{Val: "In the war"}}, want: "(In the war)"},
+		{event: grampsEvent{}, want: ""},
+	}
+	for _, test := range tests {
+		if got := grampsDate(test.event); got != test.want {
+			t.Errorf("got %q for %+v, want %q", got, test.event, test.want)
+		}
+	}
+}
+
+func TestReadTreeGramps(t *testing.T) {
+	for name, data := range map[string][]byte{
+		"xml":     []byte(grampsTestTree),
+		"gzipped": gzipped(t, grampsTestTree),
+	} {
+		t.Run(name, func(t *testing.T) {
+			if format := DetectTreeFormat(data); format != GrampsFormat {
+				t.Fatalf("got format %q, want %q", format, GrampsFormat)
+			}
+			tree, err := ReadTree(data, DefaultMaxUnpackedTreeBytes)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			expectedTree, err := ReadTree([]byte(gedcom5TestTree), DefaultMaxUnpackedTreeBytes)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			result, expected := analyzeTestTree(t, tree), analyzeTestTree(t, expectedTree)
+			if result.Subject.Xref != "I1" {
+				t.Errorf("got subject %s, want the home person I1", result.Subject.Xref)
+			}
+			if !reflect.DeepEqual(ancestorsByXref(result.Ancestors), ancestorsByXref(expected.Ancestors)) {
+				t.Errorf("got ancestors %+v, want the same as GEDCOM %+v", result.Ancestors, expected.Ancestors)
+			}
+			if len(result.Exclusions) != 1 || result.Exclusions[0].Xref != "I4" || !strings.Contains(result.Exclusions[0].Detail, "The winter before the famine") {
+				t.Errorf("got exclusions %+v, want I4 with the text date", result.Exclusions)
+			}
+
+			individuals := map[string]int{}
+			for i, individual := range tree.Individual {
+				individuals[individual.Xref] = i
+			}
+			tom := tree.Individual[individuals["I2"]]
+			if place := eventPlace(tom, "DEAT"); place != "London, England" {
+				t.Errorf("got place of death %q, want the place hierarchy", place)
+			}
+			sue := tree.Individual[individuals["I3"]]
+			if got := IndividualName(sue); got != "Sue Mother" {
+				t.Errorf("got name %q, want the birth name before the alternate name", got)
+			}
+			if _, ok := findEvent(sue, "BURI"); ok {
+				t.Error("expected a burial Sue only witnessed to be left out of her events")
+			}
+			if got := IndividualName(tree.Individual[individuals["I4"]]); got != "Grace de Father" {
+				t.Errorf("got name %q, want the surname prefix kept", got)
+			}
+		})
+	}
+
+	if _, err := ReadTree(gzipped(t, grampsTestTree), int64(len(grampsTestTree)-1)); err == nil || !strings.Contains(err.Error(), "larger than") {
+		t.Errorf("got error %v, want an unpacked size error", err)
+	}
+}
@@ -0,0 +1,463 @@
+package longevity
+
+import (
+	"bufio"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Human Mortality Database (https://www.mortality.org/) statistics come as whitespace-aligned text
+// files, one per country and statistic. These are the ones that can be read.
+const (
+	hmdFemalePeriodLifeTables = "fltper_1x1"
+	hmdMalePeriodLifeTables   = "mltper_1x1"
+	hmdFemaleCohortLifeTables = "fltcoh_1x1"
+	hmdMaleCohortLifeTables   = "mltcoh_1x1"
+	hmdPeriodLifeTables       = "bltper_1x1"
+	hmdCohortLifeTables       = "bltcoh_1x1"
+	hmdDeathRates             = "Mx_1x1"
+	hmdLifeExpectancy         = "E0per"
+)
+
+// hmdFileRegex matches HMD file names, with the country code prefix used in bulk downloads (e.g.
+// GBRTENW.fltper_1x1.txt) or without it, as in the per-country downloads.
+var hmdFileRegex = regexp.MustCompile(`^(?:([A-Za-z_]+)\.)?(fltper_1x1|mltper_1x1|fltcoh_1x1|mltcoh_1x1|bltper_1x1|bltcoh_1x1|Mx_1x1|E0per)\.txt$`)
+
+// infantSeparationFactor is the average fraction of the first year of life lived by babies who die
+// in it, used when a life table has to be built from death rates. Babies dying in their first year
+// mostly die in their first weeks, so it's much lower than the half year assumed at other ages.
+const infantSeparationFactor = 0.1
+
+// readHMDFile reads the rows of an HMD text file into maps keyed by column name, which are case
+// sensitive as life tables have both lx and Lx columns. Rows for a year that's listed twice because
+// of a change of territory (e.g. "1919-" and "1919+") are read for the later territory only, and
+// the "+" is dropped from years and open-ended age groups.
+func readHMDFile(path string) ([]map[string]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var header []string
+	var rows []map[string]string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		if header == nil {
+			if fields[0] == "Year" {
+				header = fields
+			}
+			continue
+		}
+		if len(fields) != len(header) {
+			return nil, fmt.Errorf("expected %d columns in %s but found %d: %s", len(header), path, len(fields), scanner.Text())
+		}
+		if strings.HasSuffix(fields[0], "-") {
+			continue
+		}
+		row := map[string]string{}
+		for i, field := range fields {
+			row[header[i]] = strings.TrimSuffix(field, "+")
+		}
+		rows = append(rows, row)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if header == nil {
+		return nil, fmt.Errorf("no header row found in %s", path)
+	}
+	return rows, nil
+}
+
+func hmdInt(row map[string]string, column string) (int, error) {
+	value, err := strconv.Atoi(row[column])
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s '%s'", column, row[column])
+	}
+	return value, nil
+}
+
+// hmdFloat parses a value, reporting false for the "." HMD uses for missing values.
+func hmdFloat(row map[string]string, column string) (float64, bool, error) {
+	value, ok := row[column]
+	if !ok {
+		return 0, false, fmt.Errorf("missing %s column", column)
+	}
+	if value == "." {
+		return 0, false, nil
+	}
+	parsed, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, false, fmt.Errorf("invalid %s '%s'", column, value)
+	}
+	return parsed, true, nil
+}
+
+// parseHMDLifeTables reads an HMD life table file (fltper_1x1, mltper_1x1, fltcoh_1x1 or
+// mltcoh_1x1). Years with missing values, such as cohorts that are still alive, are left out.
+func parseHMDLifeTables(path string) (LifeTables, error) {
+	rows, err := readHMDFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	tables := LifeTables{}
+	incomplete := map[int]bool{}
+	for _, row := range rows {
+		year, err := hmdInt(row, "Year")
+		if err != nil {
+			return nil, fmt.Errorf("error reading %s: %s", path, err)
+		}
+		age, err := hmdInt(row, "Age")
+		if err != nil {
+			return nil, fmt.Errorf("error reading %s: %s", path, err)
+		}
+
+		lifeTableRow := LifeTableRow{Age: age}
+		for _, column := range []struct {
+			name  string
+			value *float64
+		}{{"qx", &lifeTableRow.Qx}, {"lx", &lifeTableRow.Lx}, {"dx", &lifeTableRow.Dx}, {"ex", &lifeTableRow.Ex}} {
+			value, ok, err := hmdFloat(row, column.name)
+			if err != nil {
+				return nil, fmt.Errorf("error reading %s: %s", path, err)
+			}
+			if !ok {
+				incomplete[year] = true
+			}
+			*column.value = value
+		}
+
+		if tables[year] == nil {
+			tables[year] = &LifeTable{Year: year}
+		}
+		tables[year].Rows = append(tables[year].Rows, lifeTableRow)
+	}
+
+	for year := range incomplete {
+		delete(tables, year)
+	}
+	for _, table := range tables {
+		sort.Slice(table.Rows, func(i, j int) bool {
+			return table.Rows[i].Age < table.Rows[j].Age
+		})
+	}
+	return tables, nil
+}
+
+// lifeTableFromRates builds a life table from death rates by single year of age, assuming deaths
+// are spread evenly through each year of age apart from the first.
+func lifeTableFromRates(year int, ages []int, rates []float64) *LifeTable {
+	table := &LifeTable{Year: year, Rows: make([]LifeTableRow, len(ages))}
+	personYears := make([]float64, len(ages))
+	survivors := float64(lifeTableRadix)
+	last := len(ages) - 1
+
+	for i, age := range ages {
+		mx := rates[i]
+		separation := 0.5
+		if age == 0 {
+			separation = infantSeparationFactor
+		}
+
+		qx := 1.0
+		if i < last {
+			qx = mx / (1 + (1-separation)*mx)
+			if qx > 1 {
+				qx = 1
+			}
+		}
+		dx := survivors * qx
+
+		switch {
+		case i < last:
+			personYears[i] = survivors - (1-separation)*dx
+		case mx > 0:
+			personYears[i] = survivors / mx
+		default:
+			personYears[i] = survivors * separation
+		}
+
+		table.Rows[i] = LifeTableRow{Age: age, Qx: qx, Lx: survivors, Dx: dx}
+		survivors -= dx
+	}
+
+	remainingPersonYears := 0.0
+	for i := last; i >= 0; i-- {
+		remainingPersonYears += personYears[i]
+		if table.Rows[i].Lx > 0 {
+			table.Rows[i].Ex = remainingPersonYears / table.Rows[i].Lx
+		}
+	}
+	return table
+}
+
+// parseHMDDeathRates reads an HMD Mx_1x1 file and builds male, female and both-sexes life tables
+// from the death rates. Years with missing rates are left out.
+func parseHMDDeathRates(path string) (LifeTables, LifeTables, LifeTables, error) {
+	rows, err := readHMDFile(path)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	type rates struct {
+		ages       []int
+		rates      []float64
+		incomplete bool
+	}
+	bySex := map[string]map[int]*rates{"Male": {}, "Female": {}, "Total": {}}
+	for _, row := range rows {
+		year, err := hmdInt(row, "Year")
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("error reading %s: %s", path, err)
+		}
+		age, err := hmdInt(row, "Age")
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("error reading %s: %s", path, err)
+		}
+		for sex, years := range bySex {
+			rate, ok, err := hmdFloat(row, sex)
+			if err != nil {
+				return nil, nil, nil, fmt.Errorf("error reading %s: %s", path, err)
+			}
+			if years[year] == nil {
+				years[year] = &rates{}
+			}
+			years[year].ages = append(years[year].ages, age)
+			years[year].rates = append(years[year].rates, rate)
+			years[year].incomplete = years[year].incomplete || !ok
+		}
+	}
+
+	tables := map[string]LifeTables{"Male": {}, "Female": {}, "Total": {}}
+	for sex, years := range bySex {
+		for year, yearRates := range years {
+			if yearRates.incomplete {
+				continue
+			}
+			tables[sex][year] = lifeTableFromRates(year, yearRates.ages, yearRates.rates)
+		}
+	}
+	return tables["Male"], tables["Female"], tables["Total"], nil
+}
+
+// parseHMDLifeExpectancy reads an HMD E0per file of period life expectancy at birth by year, for
+// men, women and both sexes.
+func parseHMDLifeExpectancy(path string) (map[int]float64, map[int]float64, map[int]float64, error) {
+	rows, err := readHMDFile(path)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	male, female, total := map[int]float64{}, map[int]float64{}, map[int]float64{}
+	for _, row := range rows {
+		year, err := hmdInt(row, "Year")
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("error reading %s: %s", path, err)
+		}
+		for sex, byYear := range map[string]map[int]float64{"Male": male, "Female": female, "Total": total} {
+			value, ok, err := hmdFloat(row, sex)
+			if err != nil {
+				return nil, nil, nil, fmt.Errorf("error reading %s: %s", path, err)
+			}
+			if ok {
+				byYear[year] = value
+			}
+		}
+	}
+	return male, female, total, nil
+}
+
+// hmdDeathStats summarises period life tables as death stats, with life expectancy at birth taken
+// from E0per where it's available.
+func hmdDeathStats(tables LifeTables, lifeExpectancy map[int]float64) []DeathStat {
+	var years []int
+	for year := range tables {
+		years = append(years, year)
+	}
+	sort.Ints(years)
+
+	var stats []DeathStat
+	for _, year := range years {
+		stat, ok := tables[year].deathStat()
+		if !ok {
+			continue
+		}
+		if e0, ok := lifeExpectancy[year]; ok {
+			stat.LifeExpectancy = e0
+			stat.LifeExpectancyDays = int(e0 * 365)
+		}
+		stats = append(stats, stat)
+	}
+	return stats
+}
+
+// hmdCountryCode works out which country a file belongs to from its name or, failing that, the
+// nearest directory that isn't one of HMD's own (e.g. GBRTENW/STATS/fltper_1x1.txt).
+func hmdCountryCode(path string, prefix string) string {
+	if prefix != "" {
+		return strings.ToUpper(prefix)
+	}
+	for dir := filepath.Dir(path); dir != "." && dir != string(filepath.Separator); dir = filepath.Dir(dir) {
+		name := filepath.Base(dir)
+		if !strings.EqualFold(name, "STATS") && !hmdFileRegex.MatchString(name+".txt") {
+			return strings.ToUpper(name)
+		}
+	}
+	return ""
+}
+
+// LoadHMDDirectory reads every HMD file under dir, in either the per-country layout
+// (GBRTENW/STATS/fltper_1x1.txt) or the bulk download layout (lt_female/fltper_1x1/GBRTENW.fltper_1x1.txt),
+// and builds reference data for each country found. Period life tables are preferred to death
+// rates where a country has both, and median and modal ages at death are derived from them.
+func LoadHMDDirectory(dir string) (ReferenceRegistry, error) {
+	files := map[string]map[string]string{}
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			return nil
+		}
+		matches := hmdFileRegex.FindStringSubmatch(entry.Name())
+		if matches == nil {
+			return nil
+		}
+		relativePath, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		code := hmdCountryCode(relativePath, matches[1])
+		if code == "" {
+			return fmt.Errorf("can't tell which country %s is for", path)
+		}
+		if files[code] == nil {
+			files[code] = map[string]string{}
+		}
+		files[code][matches[2]] = path
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no HMD files found in %s", dir)
+	}
+
+	registry := ReferenceRegistry{}
+	for code, countryFiles := range files {
+		var reference ReferenceData
+		var err error
+
+		if path, ok := countryFiles[hmdDeathRates]; ok {
+			reference.MaleLifeTables, reference.FemaleLifeTables, reference.CombinedLifeTables, err = parseHMDDeathRates(path)
+			if err != nil {
+				return nil, err
+			}
+		}
+		for _, lifeTables := range []struct {
+			kind   string
+			tables *LifeTables
+		}{
+			{hmdMalePeriodLifeTables, &reference.MaleLifeTables},
+			{hmdFemalePeriodLifeTables, &reference.FemaleLifeTables},
+			{hmdMaleCohortLifeTables, &reference.MaleCohortLifeTables},
+			{hmdFemaleCohortLifeTables, &reference.FemaleCohortLifeTables},
+			{hmdPeriodLifeTables, &reference.CombinedLifeTables},
+			{hmdCohortLifeTables, &reference.CombinedCohortLifeTables},
+		} {
+			path, ok := countryFiles[lifeTables.kind]
+			if !ok {
+				continue
+			}
+			*lifeTables.tables, err = parseHMDLifeTables(path)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		var maleLifeExpectancy, femaleLifeExpectancy, combinedLifeExpectancy map[int]float64
+		if path, ok := countryFiles[hmdLifeExpectancy]; ok {
+			maleLifeExpectancy, femaleLifeExpectancy, combinedLifeExpectancy, err = parseHMDLifeExpectancy(path)
+			if err != nil {
+				return nil, err
+			}
+		}
+		reference.MaleDeathStats = hmdDeathStats(reference.MaleLifeTables, maleLifeExpectancy)
+		reference.FemaleDeathStats = hmdDeathStats(reference.FemaleLifeTables, femaleLifeExpectancy)
+		reference.CombinedDeathStats = hmdDeathStats(reference.CombinedLifeTables, combinedLifeExpectancy)
+		registry[code] = reference
+	}
+	return registry, nil
+}
+
+// hasDeathStats reports whether the reference data has death stats to compare ancestors with, for
+// either sex or both together. Life tables and life expectancies alone aren't enough.
+func (r ReferenceData) hasDeathStats() bool {
+	for _, stats := range [][]DeathStat{r.MaleDeathStats, r.FemaleDeathStats, r.CombinedDeathStats, r.MaleExternalDeathStats, r.FemaleExternalDeathStats, r.CombinedExternalDeathStats} {
+		if len(stats) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// Merge adds the reference data from other to the registry, filling in only what each country
+// doesn't already have, so statistics loaded earlier take precedence. It's an error for other to
+// have a country with no death stats, e.g. one with only E0per files.
+func (r ReferenceRegistry) Merge(other ReferenceRegistry) error {
+	for _, code := range other.Codes() {
+		if !other[code].hasDeathStats() {
+			return fmt.Errorf("no usable statistics for %s", code)
+		}
+	}
+	for code, reference := range other {
+		existing := r[code]
+		mergeDeathStats := []struct {
+			existing  *[]DeathStat
+			reference []DeathStat
+		}{
+			{&existing.MaleDeathStats, reference.MaleDeathStats},
+			{&existing.FemaleDeathStats, reference.FemaleDeathStats},
+			{&existing.CombinedDeathStats, reference.CombinedDeathStats},
+			{&existing.MaleExternalDeathStats, reference.MaleExternalDeathStats},
+			{&existing.FemaleExternalDeathStats, reference.FemaleExternalDeathStats},
+			{&existing.CombinedExternalDeathStats, reference.CombinedExternalDeathStats},
+		}
+		for _, m := range mergeDeathStats {
+			if *m.existing == nil {
+				*m.existing = m.reference
+			}
+		}
+		mergeLifeTables := []struct {
+			existing  *LifeTables
+			reference LifeTables
+		}{
+			{&existing.MaleLifeTables, reference.MaleLifeTables},
+			{&existing.FemaleLifeTables, reference.FemaleLifeTables},
+			{&existing.CombinedLifeTables, reference.CombinedLifeTables},
+			{&existing.MaleCohortLifeTables, reference.MaleCohortLifeTables},
+			{&existing.FemaleCohortLifeTables, reference.FemaleCohortLifeTables},
+			{&existing.CombinedCohortLifeTables, reference.CombinedCohortLifeTables},
+		}
+		for _, m := range mergeLifeTables {
+			if *m.existing == nil {
+				*m.existing = m.reference
+			}
+		}
+		r[code] = existing
+	}
+	return nil
+}
@@ -0,0 +1,227 @@
+package longevity
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const hmdFemaleLifeTableFixture = `England and Wales, Life tables (period 1x1), Females	Last modified: 01 Feb 2023;  Methods Protocol: v6 (2017)
+
+  Year          Age         mx       qx    ax      lx      dx      Lx       Tx     ex
+  1900            0    0.25000  0.20000  0.20  100000   20000   84000  5000000  50.00
+  1900           10    0.13000  0.12500  0.50   80000   10000   75000  4000000  45.00
+  1900           11    0.50000  0.42857  0.50   70000   30000   55000   135000   3.00
+  1900          12+    0.50000  1.00000  2.00   40000   40000   80000    80000   2.00
+  1901            0    0.25000  0.20000  0.20  100000   20000   84000  5000000  50.00
+  1901          1+           .        .     .       .       .       .       .       .
+`
+
+const hmdDeathRatesFixture = `England and Wales, Death rates (period 1x1)	Last modified: 01 Feb 2023;  Methods Protocol: v6 (2017)
+
+  Year          Age             Female            Male           Total
+  1919-           0           0.500000        0.500000        0.500000
+  1919-         1+            0.500000        0.500000        0.500000
+  1919+           0           0.100000        0.200000        0.150000
+  1919+         1+            0.200000        0.400000        0.300000
+`
+
+const hmdLifeExpectancyFixture = `England and Wales, Life expectancy at birth (period)	Last modified: 01 Feb 2023;  Methods Protocol: v6 (2017)
+
+  Year      Female    Male     Total
+  1900      51.00    47.00    49.00
+  1919      55.50        .    54.00
+`
+
+func writeHMDFile(t *testing.T, dir string, name string, contents string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatalf("failed to create %s: %s", filepath.Dir(path), err)
+	}
+	if err := os.WriteFile(path, []byte(contents), 0o644); err != nil {
+		t.Fatalf("failed to write %s: %s", path, err)
+	}
+	return path
+}
+
+func TestReadHMDFile(t *testing.T) {
+	path := writeHMDFile(t, t.TempDir(), "Mx_1x1.txt", hmdDeathRatesFixture)
+
+	rows, err := readHMDFile(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	want := []map[string]string{
+		{"Year": "1919", "Age": "0", "Female": "0.100000", "Male": "0.200000", "Total": "0.150000"},
+		{"Year": "1919", "Age": "1", "Female": "0.200000", "Male": "0.400000", "Total": "0.300000"},
+	}
+	if !reflect.DeepEqual(rows, want) {
+		t.Errorf("got %v, want %v", rows, want)
+	}
+
+	path = writeHMDFile(t, t.TempDir(), "fltper_1x1.txt", "Not an HMD file\n")
+	if _, err := readHMDFile(path); err == nil {
+		t.Error("expected an error for a file without a header row")
+	}
+}
+
+func TestParseHMDLifeTables(t *testing.T) {
+	path := writeHMDFile(t, t.TempDir(), "fltper_1x1.txt", hmdFemaleLifeTableFixture)
+
+	tables, err := parseHMDLifeTables(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	// 1901 is missing values for its last age group, so is left out.
+	want := LifeTables{
+		1900: {Year: 1900, Rows: []LifeTableRow{
+			{Age: 0, Qx: 0.2, Lx: 100000, Dx: 20000, Ex: 50},
+			{Age: 10, Qx: 0.125, Lx: 80000, Dx: 10000, Ex: 45},
+			{Age: 11, Qx: 0.42857, Lx: 70000, Dx: 30000, Ex: 3},
+			{Age: 12, Qx: 1, Lx: 40000, Dx: 40000, Ex: 2},
+		}},
+	}
+	if len(tables) != 1 || !reflect.DeepEqual(tables[1900], want[1900]) {
+		t.Errorf("got %+v, want %+v", tables[1900], want[1900])
+	}
+}
+
+func TestLifeTableFromRates(t *testing.T) {
+	table := lifeTableFromRates(1919, []int{0, 1}, []float64{0.1, 0.2})
+
+	q0 := 0.1 / (1 + (1-infantSeparationFactor)*0.1)
+	l1 := lifeTableRadix * (1 - q0)
+	personYears0 := lifeTableRadix - (1-infantSeparationFactor)*lifeTableRadix*q0
+	personYears1 := l1 / 0.2
+	want := []LifeTableRow{
+		{Age: 0, Qx: q0, Lx: lifeTableRadix, Dx: lifeTableRadix * q0, Ex: (personYears0 + personYears1) / lifeTableRadix},
+		{Age: 1, Qx: 1, Lx: l1, Dx: l1, Ex: 5},
+	}
+
+	for i, row := range table.Rows {
+		if row.Age != want[i].Age || math.Abs(row.Qx-want[i].Qx) > 1e-9 || math.Abs(row.Lx-want[i].Lx) > 1e-6 ||
+			math.Abs(row.Dx-want[i].Dx) > 1e-6 || math.Abs(row.Ex-want[i].Ex) > 1e-9 {
+			t.Errorf("row %d: got %+v, want %+v", i, row, want[i])
+		}
+	}
+}
+
+func TestParseHMDDeathRatesAndLifeExpectancy(t *testing.T) {
+	dir := t.TempDir()
+
+	male, female, total, err := parseHMDDeathRates(writeHMDFile(t, dir, "Mx_1x1.txt", hmdDeathRatesFixture))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(male) != 1 || len(female) != 1 || len(total) != 1 {
+		t.Fatalf("expected one year of rates for each sex and both together, got %d, %d and %d", len(male), len(female), len(total))
+	}
+	if got := male[1919].Rows[1].Ex; math.Abs(got-2.5) > 1e-9 {
+		t.Errorf("expected male life expectancy of 2.5 at age 1, got %v", got)
+	}
+	if got := female[1919].Rows[1].Ex; math.Abs(got-5) > 1e-9 {
+		t.Errorf("expected female life expectancy of 5 at age 1, got %v", got)
+	}
+	if got := total[1919].Rows[1].Ex; math.Abs(got-1/0.3) > 1e-9 {
+		t.Errorf("expected both-sexes life expectancy of 3.33 at age 1, got %v", got)
+	}
+
+	maleE0, femaleE0, totalE0, err := parseHMDLifeExpectancy(writeHMDFile(t, dir, "E0per.txt", hmdLifeExpectancyFixture))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !reflect.DeepEqual(maleE0, map[int]float64{1900: 47}) {
+		t.Errorf("got %v for males", maleE0)
+	}
+	if !reflect.DeepEqual(femaleE0, map[int]float64{1900: 51, 1919: 55.5}) {
+		t.Errorf("got %v for females", femaleE0)
+	}
+	if !reflect.DeepEqual(totalE0, map[int]float64{1900: 49, 1919: 54}) {
+		t.Errorf("got %v for both sexes", totalE0)
+	}
+}
+
+func TestLoadHMDDirectory(t *testing.T) {
+	dir := t.TempDir()
+	// The per-country layout, and the bulk download layout with the country in the file name.
+	writeHMDFile(t, dir, filepath.Join("GBRTENW", "STATS", "fltper_1x1.txt"), hmdFemaleLifeTableFixture)
+	writeHMDFile(t, dir, filepath.Join("GBRTENW", "STATS", "E0per.txt"), hmdLifeExpectancyFixture)
+	writeHMDFile(t, dir, filepath.Join("rates", "Mx_1x1", "GBR_SCO.Mx_1x1.txt"), hmdDeathRatesFixture)
+	writeHMDFile(t, dir, filepath.Join("GBRTENW", "STATS", "readme.txt"), "ignored")
+
+	registry, err := LoadHMDDirectory(dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if got := registry.Codes(); !reflect.DeepEqual(got, []string{"GBRTENW", "GBR_SCO"}) {
+		t.Fatalf("got countries %v", got)
+	}
+
+	englandAndWales := registry["GBRTENW"]
+	if len(englandAndWales.FemaleDeathStats) != 1 || englandAndWales.MaleDeathStats != nil {
+		t.Fatalf("expected female stats only for GBRTENW, got %+v", englandAndWales)
+	}
+	stat := englandAndWales.FemaleDeathStats[0]
+	if stat.Year != "1900" || stat.LifeExpectancy != 51 || stat.LifeExpectancyDays != 51*365 {
+		t.Errorf("expected 1900 life expectancy to come from E0per, got %+v", stat)
+	}
+	// Half of the 100,000 births have died two thirds of the way from 11 to 12, and the most deaths in
+	// a single year of age are at 11, where the open-ended age group's deaths push the mode up to 12.
+	if want := 11 + 2.0/3; math.Abs(stat.MedianAgeAtDeath-want) > 1e-9 {
+		t.Errorf("expected median age at death of %v, got %v", want, stat.MedianAgeAtDeath)
+	}
+	if stat.ModalAgeAtDeath != 12 {
+		t.Errorf("expected modal age at death of 12, got %v", stat.ModalAgeAtDeath)
+	}
+
+	scotland := registry["GBR_SCO"]
+	if len(scotland.MaleLifeTables) != 1 || len(scotland.FemaleLifeTables) != 1 {
+		t.Errorf("expected GBR_SCO life tables built from death rates, got %+v", scotland)
+	}
+
+	if _, err := LoadHMDDirectory(t.TempDir()); err == nil {
+		t.Error("expected an error for a directory with no HMD files")
+	}
+}
+
+func TestReferenceRegistryMerge(t *testing.T) {
+	ons := []DeathStat{{Year: "1900"}}
+	hmd := []DeathStat{{Year: "1901"}}
+	tables := LifeTables{1900: {Year: 1900}}
+
+	registry := ReferenceRegistry{DefaultCountry: {MaleDeathStats: ons}}
+	err := registry.Merge(ReferenceRegistry{
+		DefaultCountry: {MaleDeathStats: hmd, MaleLifeTables: tables, FemaleExternalDeathStats: hmd},
+		"USA":          {FemaleDeathStats: hmd, CombinedExternalDeathStats: hmd},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	want := ReferenceRegistry{
+		DefaultCountry: {MaleDeathStats: ons, MaleLifeTables: tables, FemaleExternalDeathStats: hmd},
+		"USA":          {FemaleDeathStats: hmd, CombinedExternalDeathStats: hmd},
+	}
+	if !reflect.DeepEqual(registry, want) {
+		t.Errorf("got %+v, want %+v", registry, want)
+	}
+
+	if err := registry.Merge(ReferenceRegistry{"CAN": {MaleLifeTables: tables}}); err == nil {
+		t.Error("expected an error for a country with no death stats")
+	}
+
+	dir := t.TempDir()
+	writeHMDFile(t, dir, filepath.Join("USA", "STATS", "E0per.txt"), hmdLifeExpectancyFixture)
+	lifeExpectancyOnly, err := LoadHMDDirectory(dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if err := registry.Merge(lifeExpectancyOnly); err == nil || !strings.Contains(err.Error(), "USA") {
+		t.Errorf("expected an error for a country with only E0per, got %v", err)
+	}
+}
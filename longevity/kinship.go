@@ -0,0 +1,101 @@
+package longevity
+
+import (
+	"github.com/iand/gedcom"
+)
+
+type individualPair struct {
+	a, b *gedcom.IndividualRecord
+}
+
+// kinshipCalculator works out coefficients of kinship and inbreeding from the pedigree using the
+// recursive method: the kinship of two different people is the average of the kinship between the
+// younger of the two and each of the other's parents. Results are memoised as the same pairs come up
+// again and again in a collapsed pedigree.
+type kinshipCalculator struct {
+	depths     map[*gedcom.IndividualRecord]int
+	kinships   map[individualPair]float64
+	inbreeding map[*gedcom.IndividualRecord]float64
+}
+
+func newKinshipCalculator() *kinshipCalculator {
+	return &kinshipCalculator{
+		depths:     map[*gedcom.IndividualRecord]int{},
+		kinships:   map[individualPair]float64{},
+		inbreeding: map[*gedcom.IndividualRecord]float64{},
+	}
+}
+
+// biologicalParents returns the first father and first mother recorded for the individual.
+func biologicalParents(individual *gedcom.IndividualRecord) (*gedcom.IndividualRecord, *gedcom.IndividualRecord) {
+	var father, mother *gedcom.IndividualRecord
+	for _, parentRecord := range individual.Parents {
+		if parentRecord.Family == nil {
+			continue
+		}
+		if father == nil {
+			father = parentRecord.Family.Husband
+		}
+		if mother == nil {
+			mother = parentRecord.Family.Wife
+		}
+	}
+	return father, mother
+}
+
+// depth is the number of generations in the individual's longest line of recorded ancestry, so
+// anyone's depth is greater than that of all of their ancestors.
+func (k *kinshipCalculator) depth(individual *gedcom.IndividualRecord) int {
+	if individual == nil {
+		return -1
+	}
+	if depth, ok := k.depths[individual]; ok {
+		return depth
+	}
+	// Guards against loops in a malformed pedigree while the depth is being worked out.
+	k.depths[individual] = 0
+
+	father, mother := biologicalParents(individual)
+	depth := k.depth(father) + 1
+	if motherDepth := k.depth(mother) + 1; motherDepth > depth {
+		depth = motherDepth
+	}
+	k.depths[individual] = depth
+	return depth
+}
+
+func (k *kinshipCalculator) kinship(a *gedcom.IndividualRecord, b *gedcom.IndividualRecord) float64 {
+	if a == nil || b == nil {
+		return 0
+	}
+	if a == b {
+		return (1 + k.inbreedingCoefficient(a)) / 2
+	}
+	if k.depth(a) < k.depth(b) {
+		a, b = b, a
+	}
+	pair := individualPair{a, b}
+	if kinship, ok := k.kinships[pair]; ok {
+		return kinship
+	}
+
+	father, mother := biologicalParents(a)
+	kinship := (k.kinship(father, b) + k.kinship(mother, b)) / 2
+	k.kinships[pair] = kinship
+	return kinship
+}
+
+// inbreedingCoefficient is the probability that the two copies of a gene the individual inherited
+// from their parents are identical by descent, i.e. the kinship between their parents.
+func (k *kinshipCalculator) inbreedingCoefficient(individual *gedcom.IndividualRecord) float64 {
+	if inbreeding, ok := k.inbreeding[individual]; ok {
+		return inbreeding
+	}
+	// Guards against loops in a malformed pedigree while the coefficient is being worked out.
+	k.inbreeding[individual] = 0
+
+	father, mother := biologicalParents(individual)
+	inbreeding := k.kinship(father, mother)
+	k.inbreeding[individual] = inbreeding
+	return inbreeding
+}
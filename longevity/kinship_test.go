@@ -0,0 +1,99 @@
+package longevity
+
+import (
+	"math"
+	"testing"
+
+	"github.com/iand/gedcom"
+)
+
+const siblingMarriageTree = `0 HEAD
+0 @P1@ INDI
+1 FAMS @F1@
+0 @P2@ INDI
+1 FAMS @F1@
+0 @A@ INDI
+1 FAMC @F1@
+1 FAMS @F2@
+0 @B@ INDI
+1 FAMC @F1@
+1 FAMS @F2@
+0 @C@ INDI
+1 FAMC @F2@
+0 @F1@ FAM
+1 HUSB @P1@
+1 WIFE @P2@
+1 CHIL @A@
+1 CHIL @B@
+0 @F2@ FAM
+1 HUSB @A@
+1 WIFE @B@
+1 CHIL @C@
+0 TRLR
+`
+
+func individualsByXref(g *gedcom.Gedcom) map[string]*gedcom.IndividualRecord {
+	individuals := map[string]*gedcom.IndividualRecord{}
+	for _, individual := range g.Individual {
+		individuals[individual.Xref] = individual
+	}
+	return individuals
+}
+
+func TestInbreedingCoefficient(t *testing.T) {
+	tests := []struct {
+		name  string
+		tree  string
+		xref  string
+		wantF float64
+	}{
+		{name: "child of siblings", tree: siblingMarriageTree, xref: "C", wantF: 0.25},
+		{name: "siblings' parent", tree: siblingMarriageTree, xref: "A", wantF: 0},
+		{name: "child of half-uncle and niece", tree: collapsedPedigreeTree, xref: "S", wantF: 0.0625},
+	}
+
+	for _, test := range tests {
+		individuals := individualsByXref(decodeTestTree(t, test.tree))
+		got := newKinshipCalculator().inbreedingCoefficient(individuals[test.xref])
+		if math.Abs(got-test.wantF) > 1e-9 {
+			t.Errorf("test %q: got %v, want %v", test.name, got, test.wantF)
+		}
+	}
+}
+
+func TestKinship(t *testing.T) {
+	individuals := individualsByXref(decodeTestTree(t, siblingMarriageTree))
+	calculator := newKinshipCalculator()
+
+	if got := calculator.kinship(individuals["A"], individuals["B"]); math.Abs(got-0.25) > 1e-9 {
+		t.Errorf("expected siblings to have a kinship of 0.25, got %v", got)
+	}
+	// C's parents are siblings, so C is related to them more closely than an ordinary child.
+	if got := calculator.kinship(individuals["C"], individuals["A"]); math.Abs(got-0.375) > 1e-9 {
+		t.Errorf("expected a kinship of 0.375 between C and their father, got %v", got)
+	}
+	if got := calculator.kinship(individuals["C"], individuals["P1"]); math.Abs(got-0.25) > 1e-9 {
+		t.Errorf("expected a kinship of 0.25 between C and their doubled grandfather, got %v", got)
+	}
+}
+
+func TestCalculateWeightedAveragesKinship(t *testing.T) {
+	// The child of a brother and sister: their parents are each more closely related to them than an
+	// ordinary parent, while the grandfather's two paths count for the same under either scheme.
+	ancestors := []AncestorDeath{
+		{Gender: "m", GenerationsRemoved: 1, Paths: AncestorPaths{1: 1}, MedianAgeAtDeathDiffDays: 0, KinshipCoefficient: 0.375},
+		{Gender: "f", GenerationsRemoved: 1, Paths: AncestorPaths{1: 1}, MedianAgeAtDeathDiffDays: 0, KinshipCoefficient: 0.375},
+		{Gender: "m", GenerationsRemoved: 2, Paths: AncestorPaths{2: 2}, MedianAgeAtDeathDiffDays: 900, KinshipCoefficient: 0.25},
+	}
+
+	// Generational weights are 2, 2 and 1 for each of the last ancestor's two paths; kinship weights
+	// are 0.75, 0.75 and 0.5.
+	_, gotMedian, _ := CalculateWeightedAverages(ancestors, "", GenerationalWeighter{})
+	if gotMedian != 300 {
+		t.Errorf("generational: got %d, want 300", gotMedian)
+	}
+	_, gotMedian, _ = CalculateWeightedAverages(ancestors, "", KinshipWeighter{})
+	if gotMedian != 225 {
+		t.Errorf("kinship: got %d, want 225", gotMedian)
+	}
+}
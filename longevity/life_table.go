@@ -0,0 +1,329 @@
+package longevity
+
+import (
+	"encoding/csv"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// LifeTableRow is one single year of age in a period life table: Qx is the probability of dying
+// before reaching the next age, Lx the number of survivors to Age out of the table's radix, Dx the
+// number of those who die before the next age and Ex the further number of years someone who has
+// reached Age can expect to live.
+type LifeTableRow struct {
+	Age int
+	Qx  float64
+	Lx  float64
+	Dx  float64
+	Ex  float64
+}
+
+// Comparison modes choose whether ancestors are compared with the period statistics for the year
+// they died or the cohort life table for the year they were born.
+const (
+	PeriodComparison = "period"
+	CohortComparison = "cohort"
+)
+
+var ComparisonModes = []string{PeriodComparison, CohortComparison}
+
+func checkComparisonMode(mode string) error {
+	for _, known := range ComparisonModes {
+		if mode == known {
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown comparison '%s', expected one of %v", mode, ComparisonModes)
+}
+
+// lifeTableRadix is the number of births assumed when survivors have to be derived from qx.
+const lifeTableRadix = 100000
+
+type LifeTable struct {
+	Year int
+	Rows []LifeTableRow
+}
+
+// LifeTables holds a life table for each year covered, keyed by year.
+type LifeTables map[int]*LifeTable
+
+func (r ReferenceData) lifeTables(sex string) LifeTables {
+	switch sex {
+	case "m":
+		return r.MaleLifeTables
+	case "f":
+		return r.FemaleLifeTables
+	}
+	return r.CombinedLifeTables
+}
+
+func (r ReferenceData) cohortLifeTables(sex string) LifeTables {
+	switch sex {
+	case "m":
+		return r.MaleCohortLifeTables
+	case "f":
+		return r.FemaleCohortLifeTables
+	}
+	return r.CombinedCohortLifeTables
+}
+
+// expectedAgeAtDeath returns the age someone who has survived to the given age can expect to die at.
+// Ages beyond the end of the table use its last, open-ended, age group.
+func (t *LifeTable) expectedAgeAtDeath(age int) (float64, bool) {
+	if t == nil || len(t.Rows) == 0 || age < t.Rows[0].Age {
+		return 0, false
+	}
+	i := sort.Search(len(t.Rows), func(i int) bool {
+		return t.Rows[i].Age >= age
+	})
+	if i == len(t.Rows) {
+		last := t.Rows[len(t.Rows)-1]
+		return float64(last.Age) + last.Ex, true
+	}
+	if t.Rows[i].Age != age {
+		return 0, false
+	}
+	return float64(age) + t.Rows[i].Ex, true
+}
+
+// hasSurvivors reports whether the table has the survivor counts needed to place an age at death
+// within its distribution.
+func (t *LifeTable) hasSurvivors() bool {
+	return t != nil && len(t.Rows) > 0 && t.Rows[0].Lx > 0
+}
+
+// survivorsAt returns the number of survivors to an exact, possibly fractional, age, interpolating
+// linearly between single years of age. Beyond the last, open-ended, age group survivors die off
+// exponentially at the rate implied by its life expectancy.
+func (t *LifeTable) survivorsAt(age float64) (float64, bool) {
+	if !t.hasSurvivors() || age < float64(t.Rows[0].Age) {
+		return 0, false
+	}
+	for i, row := range t.Rows {
+		if i == len(t.Rows)-1 {
+			if row.Ex <= 0 {
+				return 0, true
+			}
+			return row.Lx * math.Exp(-(age-float64(row.Age))/row.Ex), true
+		}
+		next := t.Rows[i+1]
+		if age < float64(next.Age) {
+			fraction := (age - float64(row.Age)) / float64(next.Age-row.Age)
+			return row.Lx - fraction*(row.Lx-next.Lx), true
+		}
+	}
+	return 0, false
+}
+
+// survivalPercentile returns the percentage of people who had survived to fromAge who died younger
+// than ageAtDeathDays, i.e. the percentage of their peers the person outlived.
+func (t *LifeTable) survivalPercentile(ageAtDeathDays int, fromAge int) (float64, bool) {
+	survivorsFrom, ok := t.survivorsAt(float64(fromAge))
+	if !ok || survivorsFrom <= 0 {
+		return 0, false
+	}
+	survivorsAtDeath, ok := t.survivorsAt(float64(ageAtDeathDays) / 365)
+	if !ok {
+		return 0, false
+	}
+	percentile := 100 * (1 - survivorsAtDeath/survivorsFrom)
+	return math.Max(0, math.Min(100, percentile)), true
+}
+
+// minimumModalAge skips the deaths in infancy, so the modal age at death is the adult mode as in
+// the ONS figures.
+const minimumModalAge = 10
+
+// medianAgeAtDeath returns the age by which half of the table's births have died.
+func (t *LifeTable) medianAgeAtDeath() (float64, bool) {
+	if !t.hasSurvivors() {
+		return 0, false
+	}
+	half := t.Rows[0].Lx / 2
+	for i := 0; i+1 < len(t.Rows); i++ {
+		row, next := t.Rows[i], t.Rows[i+1]
+		if next.Lx <= half {
+			return float64(row.Age) + float64(next.Age-row.Age)*(row.Lx-half)/(row.Lx-next.Lx), true
+		}
+	}
+	last := t.Rows[len(t.Rows)-1]
+	return float64(last.Age) + last.Ex*math.Log(last.Lx/half), true
+}
+
+// modalAgeAtDeath returns the adult age at which most deaths occur, placing it within the single
+// year of age with the most deaths by fitting a parabola through that year's deaths and those of
+// its neighbours (Kannisto's method).
+func (t *LifeTable) modalAgeAtDeath() (float64, bool) {
+	if !t.hasSurvivors() {
+		return 0, false
+	}
+	mode := -1
+	for i, row := range t.Rows {
+		if row.Age >= minimumModalAge && i+1 < len(t.Rows) && (mode < 0 || row.Dx > t.Rows[mode].Dx) {
+			mode = i
+		}
+	}
+	if mode < 0 {
+		return 0, false
+	}
+	row := t.Rows[mode]
+	if mode == 0 {
+		return float64(row.Age), true
+	}
+	rise, fall := row.Dx-t.Rows[mode-1].Dx, row.Dx-t.Rows[mode+1].Dx
+	if rise+fall <= 0 {
+		return float64(row.Age), true
+	}
+	// The last age group is open-ended, so may have more deaths than the mode's single year.
+	return float64(row.Age) + math.Min(1, rise/(rise+fall)), true
+}
+
+// deathStat summarises the table as life expectancy at birth and median and modal ages at death,
+// for comparisons made against life tables rather than summary statistics.
+func (t *LifeTable) deathStat() (DeathStat, bool) {
+	median, ok := t.medianAgeAtDeath()
+	if !ok {
+		return DeathStat{}, false
+	}
+	modal, ok := t.modalAgeAtDeath()
+	if !ok {
+		return DeathStat{}, false
+	}
+	lifeExpectancy, ok := t.expectedAgeAtDeath(0)
+	if !ok {
+		return DeathStat{}, false
+	}
+	return DeathStat{
+		Year:                 strconv.Itoa(t.Year),
+		LifeExpectancy:       lifeExpectancy,
+		LifeExpectancyDays:   int(lifeExpectancy * 365),
+		MedianAgeAtDeath:     median,
+		MedianAgeAtDeathDays: int(median * 365),
+		ModalAgeAtDeath:      modal,
+		ModalAgeAtDeathDays:  int(modal * 365),
+	}, true
+}
+
+// completeColumns fills in whichever of qx, lx and dx the source didn't provide from the others.
+func (t *LifeTable) completeColumns(hasQx bool, hasLx bool, hasDx bool) {
+	rows := t.Rows
+	if !hasLx && hasQx {
+		survivors := float64(lifeTableRadix)
+		for i := range rows {
+			rows[i].Lx = survivors
+			survivors *= 1 - rows[i].Qx
+		}
+		hasLx = true
+	}
+	if !hasLx {
+		return
+	}
+	for i := range rows {
+		if !hasDx {
+			rows[i].Dx = rows[i].Lx
+			if i+1 < len(rows) {
+				rows[i].Dx -= rows[i+1].Lx
+			}
+		}
+		if !hasQx && rows[i].Lx > 0 {
+			rows[i].Qx = rows[i].Dx / rows[i].Lx
+		}
+	}
+}
+
+// lifeTableColumns maps the lower-case name of each column in the header to its index. Names are
+// matched regardless of case, but a column whose name is already lower case wins over one that only
+// matches once folded, as HMD-style tables have both lx (survivors) and Lx (person-years lived).
+func lifeTableColumns(header []string, required ...string) (map[string]int, error) {
+	columns := map[string]int{}
+	exact := map[string]bool{}
+	for i, name := range header {
+		name = strings.TrimSpace(name)
+		key := strings.ToLower(name)
+		if _, ok := columns[key]; ok && (exact[key] || name != key) {
+			continue
+		}
+		columns[key] = i
+		exact[key] = name == key
+	}
+	for _, name := range required {
+		if _, ok := columns[name]; !ok {
+			return nil, fmt.Errorf("life table is missing the %s column", name)
+		}
+	}
+	return columns, nil
+}
+
+// ParseLifeTables reads period life tables for a single sex from a CSV file with a header row and
+// one row per year and single year of age. The Year, Age and ex columns are required, and the qx, lx
+// and dx columns are optional as long as there's enough to derive the others (qx or lx). Columns may
+// come in any order and any case, though an lx column is taken over Lx (person-years lived), and the
+// last age group may be open-ended, e.g. "110+".
+func ParseLifeTables(filepath string) (LifeTables, error) {
+	file, err := os.Open(filepath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	records, err := csv.NewReader(file).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, fmt.Errorf("life table file %s is empty", filepath)
+	}
+
+	columns, err := lifeTableColumns(records[0], "year", "age", "ex")
+	if err != nil {
+		return nil, err
+	}
+
+	_, hasQx := columns["qx"]
+	_, hasLx := columns["lx"]
+	_, hasDx := columns["dx"]
+
+	tables := LifeTables{}
+	for i, record := range records[1:] {
+		line := i + 2
+		year, err := strconv.Atoi(strings.TrimSpace(record[columns["year"]]))
+		if err != nil {
+			return nil, fmt.Errorf("invalid year on line %d: %s", line, err)
+		}
+		age, err := strconv.Atoi(strings.TrimSuffix(strings.TrimSpace(record[columns["age"]]), "+"))
+		if err != nil {
+			return nil, fmt.Errorf("invalid age on line %d: %s", line, err)
+		}
+		row := LifeTableRow{Age: age}
+		for _, column := range []struct {
+			name  string
+			value *float64
+		}{{"qx", &row.Qx}, {"lx", &row.Lx}, {"dx", &row.Dx}, {"ex", &row.Ex}} {
+			index, ok := columns[column.name]
+			if !ok {
+				continue
+			}
+			*column.value, err = strconv.ParseFloat(strings.TrimSpace(record[index]), 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s on line %d: %s", column.name, line, err)
+			}
+		}
+
+		if tables[year] == nil {
+			tables[year] = &LifeTable{Year: year}
+		}
+		tables[year].Rows = append(tables[year].Rows, row)
+	}
+
+	for _, table := range tables {
+		sort.Slice(table.Rows, func(i, j int) bool {
+			return table.Rows[i].Age < table.Rows[j].Age
+		})
+		table.completeColumns(hasQx, hasLx, hasDx)
+	}
+	return tables, nil
+}
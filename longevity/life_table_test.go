@@ -0,0 +1,269 @@
+package longevity
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/iand/gedcom"
+)
+
+func writeTestFile(t *testing.T, name string, contents string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(contents), 0o644); err != nil {
+		t.Fatalf("failed to write %s: %s", name, err)
+	}
+	return path
+}
+
+func TestParseLifeTables(t *testing.T) {
+	path := writeTestFile(t, "life_tables.csv", `Age,Year,ex
+1,1850,45.5
+0,1850,40.0
+2,1850,46.0
+100+,1850,1.5
+0,1851,41.0
+`)
+
+	tables, err := ParseLifeTables(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	want := LifeTables{
+		1850: {Year: 1850, Rows: []LifeTableRow{{Age: 0, Ex: 40}, {Age: 1, Ex: 45.5}, {Age: 2, Ex: 46}, {Age: 100, Ex: 1.5}}},
+		1851: {Year: 1851, Rows: []LifeTableRow{{Age: 0, Ex: 41}}},
+	}
+	if !reflect.DeepEqual(tables, want) {
+		t.Errorf("got %+v, want %+v", tables, want)
+	}
+
+	if _, err := ParseLifeTables(writeTestFile(t, "missing_ex.csv", "Year,Age\n1850,0\n")); err == nil {
+		t.Error("expected an error for a life table without an ex column")
+	}
+	if _, err := ParseLifeTables(writeTestFile(t, "bad_age.csv", "Year,Age,ex\n1850,infant,40\n")); err == nil {
+		t.Error("expected an error for an invalid age")
+	}
+}
+
+func TestExpectedAgeAtDeath(t *testing.T) {
+	table := &LifeTable{Year: 1850, Rows: []LifeTableRow{{Age: 0, Ex: 40}, {Age: 15, Ex: 45.5}, {Age: 100, Ex: 1.5}}}
+
+	tests := []struct {
+		age    int
+		want   float64
+		wantOk bool
+	}{
+		{age: 0, want: 40, wantOk: true},
+		{age: 15, want: 60.5, wantOk: true},
+		{age: 16, wantOk: false},
+		{age: 105, want: 101.5, wantOk: true},
+	}
+
+	for _, test := range tests {
+		got, ok := table.expectedAgeAtDeath(test.age)
+		if ok != test.wantOk || got != test.want {
+			t.Errorf("age %d: got %v, %v, want %v, %v", test.age, got, ok, test.want, test.wantOk)
+		}
+	}
+
+	var missing *LifeTable
+	if _, ok := missing.expectedAgeAtDeath(15); ok {
+		t.Error("expected no life expectancy from a missing life table")
+	}
+}
+
+func TestParseLifeTablesDerivesColumns(t *testing.T) {
+	path := writeTestFile(t, "life_tables.csv", `Year,Age,qx,ex
+1850,0,0.2,40
+1850,1,0.5,45
+1850,2+,1,30
+`)
+
+	tables, err := ParseLifeTables(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	want := []LifeTableRow{
+		{Age: 0, Qx: 0.2, Lx: 100000, Dx: 20000, Ex: 40},
+		{Age: 1, Qx: 0.5, Lx: 80000, Dx: 40000, Ex: 45},
+		{Age: 2, Qx: 1, Lx: 40000, Dx: 40000, Ex: 30},
+	}
+	if !reflect.DeepEqual(tables[1850].Rows, want) {
+		t.Errorf("got %+v, want %+v", tables[1850].Rows, want)
+	}
+
+	path = writeTestFile(t, "life_tables_lx.csv", `Year,Age,lx,ex
+1850,0,1000,40
+1850,1,750,45
+`)
+	tables, err = ParseLifeTables(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	want = []LifeTableRow{
+		{Age: 0, Qx: 0.25, Lx: 1000, Dx: 250, Ex: 40},
+		{Age: 1, Qx: 1, Lx: 750, Dx: 750, Ex: 45},
+	}
+	if !reflect.DeepEqual(tables[1850].Rows, want) {
+		t.Errorf("got %+v, want %+v", tables[1850].Rows, want)
+	}
+
+	// Person-years lived (Lx) mustn't be mistaken for survivors (lx), whichever comes first.
+	for _, file := range []string{
+		"Year,Age,qx,lx,dx,Lx,Tx,ex\n1850,0,0.1,100000,10000,91000,4000000,40\n",
+		"Year,Age,qx,Lx,dx,lx,Tx,ex\n1850,0,0.1,91000,10000,100000,4000000,40\n",
+	} {
+		path = writeTestFile(t, "life_tables_hmd.csv", file)
+		tables, err = ParseLifeTables(path)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if row := tables[1850].Rows[0]; row.Lx != 100000 {
+			t.Errorf("file %q: expected lx of 100000, got %+v", file, row)
+		}
+	}
+}
+
+func TestSurvivalPercentile(t *testing.T) {
+	table := &LifeTable{Year: 1900, Rows: []LifeTableRow{
+		{Age: 0, Lx: 100000},
+		{Age: 15, Lx: 80000},
+		{Age: 60, Lx: 40000},
+		{Age: 90, Lx: 10000, Ex: 5},
+	}}
+
+	tests := []struct {
+		name           string
+		ageAtDeathDays int
+		fromAge        int
+		want           float64
+		wantOk         bool
+	}{
+		{name: "from birth", ageAtDeathDays: 60 * 365, want: 60, wantOk: true},
+		{name: "interpolated", ageAtDeathDays: 75 * 365, want: 75, wantOk: true},
+		{name: "conditional", ageAtDeathDays: 60 * 365, fromAge: 15, want: 50, wantOk: true},
+		{name: "open-ended", ageAtDeathDays: 95 * 365, want: 100 - 10*math.Exp(-1), wantOk: true},
+	}
+
+	for _, test := range tests {
+		got, ok := table.survivalPercentile(test.ageAtDeathDays, test.fromAge)
+		if ok != test.wantOk || math.Abs(got-test.want) > 1e-9 {
+			t.Errorf("test %q: got %v, %v, want %v, %v", test.name, got, ok, test.want, test.wantOk)
+		}
+	}
+
+	exOnly := &LifeTable{Year: 1900, Rows: []LifeTableRow{{Age: 0, Ex: 40}}}
+	if _, ok := exOnly.survivalPercentile(60*365, 0); ok {
+		t.Error("expected no percentile from a life table without survivors")
+	}
+}
+
+func TestLifeTableDeathStat(t *testing.T) {
+	table := &LifeTable{Year: 1850, Rows: []LifeTableRow{
+		{Age: 0, Lx: 1000, Dx: 300, Ex: 40},
+		{Age: 10, Lx: 700, Dx: 100},
+		{Age: 50, Lx: 600, Dx: 200},
+		{Age: 70, Lx: 400, Dx: 300},
+		{Age: 71, Lx: 100, Dx: 100, Ex: 2},
+	}}
+	// Half of the 1000 births have died between 50 and 70, 60% of the way from 600 to 400
+	// survivors. The most deaths are at 70, with 100 more than at 50 and 200 more than at 71.
+	modal := 70 + 1.0/3
+	want := DeathStat{
+		Year:                 "1850",
+		LifeExpectancy:       40,
+		LifeExpectancyDays:   40 * 365,
+		MedianAgeAtDeath:     60,
+		MedianAgeAtDeathDays: 60 * 365,
+		ModalAgeAtDeath:      modal,
+		ModalAgeAtDeathDays:  int(modal * 365),
+	}
+
+	got, ok := table.deathStat()
+	if !ok {
+		t.Fatal("expected a death stat")
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+
+	exOnly := &LifeTable{Year: 1850, Rows: []LifeTableRow{{Age: 0, Ex: 40}}}
+	if _, ok := exOnly.deathStat(); ok {
+		t.Error("expected no death stat from a life table without survivors")
+	}
+}
+
+func TestGetDeathStatsForAncestorsCohort(t *testing.T) {
+	femaleCohortLifeTables := LifeTables{
+		1850: {Year: 1850, Rows: []LifeTableRow{
+			{Age: 0, Lx: 100000, Dx: 20000, Ex: 45},
+			{Age: 15, Lx: 80000, Dx: 20000, Ex: 40},
+			{Age: 50, Lx: 60000, Dx: 50000, Ex: 20},
+			{Age: 80, Lx: 10000, Dx: 10000, Ex: 5},
+		}},
+	}
+	ancestor := &gedcom.IndividualRecord{
+		Sex: "F",
+		Event: []*gedcom.EventRecord{
+			{Tag: "BIRT", Date: "1 JAN 1850"},
+			{Tag: "DEAT", Date: "1 JAN 1930"},
+		},
+	}
+	ancestors := map[*gedcom.IndividualRecord]AncestorPaths{ancestor: {1: 1}}
+	reference := ReferenceData{FemaleCohortLifeTables: femaleCohortLifeTables}
+
+	// There are no period statistics for 1930, so she's only included when compared with her cohort.
+	if got, _ := getDeathStatsForAncestors(nil, ancestors, ReferenceRegistry{DefaultCountry: reference}, Options{}); len(got) != 0 {
+		t.Errorf("expected no ancestors with period comparison, got %d", len(got))
+	}
+
+	got, _ := getDeathStatsForAncestors(nil, ancestors, ReferenceRegistry{DefaultCountry: reference}, Options{Comparison: CohortComparison, Conditioning: AdulthoodConditioning})
+	if len(got) != 1 {
+		t.Fatalf("expected 1 ancestor, got %d", len(got))
+	}
+	if got[0].ConditioningAge != 15 || got[0].LifeExpectancyDays != 55*365 {
+		t.Errorf("expected life expectancy of 55 years from age 15, got %d days from age %d", got[0].LifeExpectancyDays, got[0].ConditioningAge)
+	}
+	// She died 20 leap days past her 80th birthday, by when just over seven eighths of the women who
+	// reached 15 had died.
+	if !got[0].HasSurvivalPercentile || math.Abs(got[0].SurvivalPercentile-87.6) > 0.1 {
+		t.Errorf("expected a survival percentile of about 87.6, got %v", got[0].SurvivalPercentile)
+	}
+	if got[0].MedianDeathAgeDays != int((50+30*0.2)*365) {
+		t.Errorf("expected a median age at death of 56 years, got %d days", got[0].MedianDeathAgeDays)
+	}
+}
+
+func TestCalculateWeightedPercentile(t *testing.T) {
+	ancestors := []AncestorDeath{
+		{Gender: "f", GenerationsRemoved: 1, SurvivalPercentile: 80, HasSurvivalPercentile: true},
+		{Gender: "m", GenerationsRemoved: 1, SurvivalPercentile: 40, HasSurvivalPercentile: true},
+		{Gender: "m", GenerationsRemoved: 2, SurvivalPercentile: 70, HasSurvivalPercentile: true},
+		{Gender: "m", GenerationsRemoved: 2},
+	}
+
+	tests := []struct {
+		gender string
+		want   float64
+	}{
+		{gender: "f", want: 80},
+		{gender: "m", want: 50},
+		{gender: "", want: 62},
+	}
+
+	for _, test := range tests {
+		got, ok := CalculateWeightedPercentile(ancestors, test.gender, GenerationalWeighter{})
+		if !ok || math.Abs(got-test.want) > 1e-9 {
+			t.Errorf("gender %q: got %v, %v, want %v", test.gender, got, ok, test.want)
+		}
+	}
+
+	if _, ok := CalculateWeightedPercentile(ancestors[3:], "", GenerationalWeighter{}); ok {
+		t.Error("expected no percentile when no ancestor has one")
+	}
+}
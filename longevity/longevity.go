@@ -0,0 +1,375 @@
+// Package longevity compares the ages at death of a subject's direct ancestors, read from a GEDCOM
+// tree, with mortality statistics for when and where they lived. An Analyzer does the comparison for
+// a set of reference statistics and Options, and returns a Result for each subject.
+package longevity
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/iand/gedcom"
+)
+
+// DeathStat holds the statistics for one year, in years and in days.
+type DeathStat struct {
+	Year                 string
+	LifeExpectancy       float64
+	LifeExpectancyDays   int
+	MedianAgeAtDeath     float64
+	MedianAgeAtDeathDays int
+	ModalAgeAtDeath      float64
+	ModalAgeAtDeathDays  int
+}
+
+// ReferenceData holds the statistics ancestors are compared against. The period life tables are
+// optional and keyed by calendar year, while the cohort life tables are keyed by year of birth and
+// are only used for cohort comparison. The external death stats are only used with the external
+// year policy.
+type ReferenceData struct {
+	MaleDeathStats         []DeathStat
+	FemaleDeathStats       []DeathStat
+	MaleLifeTables         LifeTables
+	FemaleLifeTables       LifeTables
+	MaleCohortLifeTables   LifeTables
+	FemaleCohortLifeTables LifeTables
+	// External death stats, e.g. parish reconstitution estimates, cover years the main stats don't.
+	MaleExternalDeathStats   []DeathStat
+	FemaleExternalDeathStats []DeathStat
+	// Statistics for both sexes together are only used for ancestors of unknown sex.
+	CombinedDeathStats         []DeathStat
+	CombinedExternalDeathStats []DeathStat
+	CombinedLifeTables         LifeTables
+	CombinedCohortLifeTables   LifeTables
+}
+
+// AncestorPaths records every line of descent from an ancestor to the subject, as the number of
+// distinct paths at each generation distance. Pedigree collapse (e.g. a cousin marriage) gives an
+// ancestor more than one path.
+type AncestorPaths map[int]int
+
+// AncestorDeath is an ancestor's age at death compared with the statistics for them. Diffs are the
+// ancestor's age at death less the statistic, in days.
+type AncestorDeath struct {
+	Xref                     string
+	Name                     string
+	Year                     int
+	BirthYear                int
+	GenerationsRemoved       int
+	Paths                    AncestorPaths
+	Gender                   string
+	SexInferred              bool
+	Country                  string
+	StatsYear                int
+	StatsMatch               string
+	AgeAtDeathDaysTotal      int
+	AgeAtDeathMinDays        int
+	AgeAtDeathMaxDays        int
+	AgeAtDeathYears          int
+	AgeAtDeathDays           int
+	LifeExpectancyDiffDays   int
+	MedianAgeAtDeathDiffDays int
+	ModalAgeAtDeathDiffDays  int
+	ModalDeathAgeDays        int
+	MedianDeathAgeDays       int
+	LifeExpectancyDays       int
+	ConditioningAge          int
+	SurvivalPercentile       float64
+	HasSurvivalPercentile    bool
+	BirthSource              string
+	DeathSource              string
+	InbreedingCoefficient    float64
+	KinshipCoefficient       float64
+}
+
+// CalculateWeightedAverages returns the weighted mean life expectancy, median and modal age at death
+// diffs of the ancestors of the given sex, or all of them for "".
+func CalculateWeightedAverages(ancestors []AncestorDeath, gender string, weighter Weighter) (int, int, int) {
+	var totalLifeExpectancyDiffDays, totalMedianAgeAtDeathDiffDays, totalModalAgeAtDeathDiffDays float64
+	var weightSum float64
+
+	weights := weighter.Weights(ancestors)
+
+	for i, ancestor := range ancestors {
+		if ancestor.Gender == gender || gender == "" {
+			weight := weights[i]
+			totalLifeExpectancyDiffDays += float64(ancestor.LifeExpectancyDiffDays) * weight
+			totalMedianAgeAtDeathDiffDays += float64(ancestor.MedianAgeAtDeathDiffDays) * weight
+			totalModalAgeAtDeathDiffDays += float64(ancestor.ModalAgeAtDeathDiffDays) * weight
+			weightSum += weight
+		}
+	}
+
+	if weightSum == 0 {
+		return 0, 0, 0
+	}
+
+	return int(totalLifeExpectancyDiffDays / weightSum), int(totalMedianAgeAtDeathDiffDays / weightSum), int(totalModalAgeAtDeathDiffDays / weightSum)
+}
+
+// CalculateWeightedUncertainty returns how far below and above the weighted averages the true
+// values could lie given the uncertainty in the ancestors' birth and death dates. Each ancestor's
+// reference statistics are fixed, so the same band applies to every diff.
+func CalculateWeightedUncertainty(ancestors []AncestorDeath, gender string, weighter Weighter) (int, int) {
+	var totalLowerDays, totalUpperDays float64
+	var weightSum float64
+
+	weights := weighter.Weights(ancestors)
+
+	for i, ancestor := range ancestors {
+		if ancestor.Gender == gender || gender == "" {
+			weight := weights[i]
+			totalLowerDays += float64(ancestor.AgeAtDeathMinDays-ancestor.AgeAtDeathDaysTotal) * weight
+			totalUpperDays += float64(ancestor.AgeAtDeathMaxDays-ancestor.AgeAtDeathDaysTotal) * weight
+			weightSum += weight
+		}
+	}
+
+	if weightSum == 0 {
+		return 0, 0
+	}
+
+	return int(totalLowerDays / weightSum), int(totalUpperDays / weightSum)
+}
+
+// CalculateWeightedPercentile returns the weighted mean survival percentile of the ancestors who
+// could be placed in a life table, and whether there were any.
+func CalculateWeightedPercentile(ancestors []AncestorDeath, gender string, weighter Weighter) (float64, bool) {
+	var totalPercentile float64
+	var weightSum float64
+
+	weights := weighter.Weights(ancestors)
+
+	for i, ancestor := range ancestors {
+		if (ancestor.Gender == gender || gender == "") && ancestor.HasSurvivalPercentile {
+			totalPercentile += ancestor.SurvivalPercentile * weights[i]
+			weightSum += weights[i]
+		}
+	}
+
+	if weightSum == 0 {
+		return 0, false
+	}
+
+	return totalPercentile / weightSum, true
+}
+
+// getDeathStatsForAncestors compares each ancestor's age at death with the statistics for the year
+// they died, from the country chosen by ancestorCountry. Years without statistics are handled by
+// options.YearPolicy, and ancestors who can't be compared are returned as exclusions. Life
+// expectancy is conditional on surviving to the age chosen by options.Conditioning where a life
+// table covers the year the ancestor reached that age, and from birth otherwise. Where there's a
+// life table for the year of death, the ancestor is also placed within its survival distribution,
+// conditional on the same age. With cohort comparison, every statistic comes from the cohort life
+// table for the year the ancestor was born instead. Each ancestor's kinship with the subject is
+// recorded for kinship weighting.
+func getDeathStatsForAncestors(subject *gedcom.IndividualRecord, ancestors map[*gedcom.IndividualRecord]AncestorPaths, registry ReferenceRegistry, options Options) ([]AncestorDeath, []Exclusion) {
+	var ancestorDeaths []AncestorDeath
+	var exclusions []Exclusion
+	kinship := newKinshipCalculator()
+	for individual, paths := range ancestors {
+		birth, birthSource, hasBirth := findEventDate(individual, "BIRT", options.BirthFallbacks)
+		death, deathSource, hasDeath := findEventDate(individual, "DEAT", options.DeathFallbacks)
+		if !hasBirth {
+			exclusions = append(exclusions, dateExclusion(individual, paths, "BIRT", options.BirthFallbacks))
+			continue
+		}
+		if !hasDeath {
+			exclusions = append(exclusions, dateExclusion(individual, paths, "DEAT", options.DeathFallbacks))
+			continue
+		}
+		birthDate, deathDate := birth.Estimate, death.Estimate
+
+		ageAtDeathDaysTotal := daysBetween(birthDate, deathDate)
+		ageAtDeathMinDays := daysBetween(birth.Latest, death.Earliest)
+		if ageAtDeathMinDays < 0 {
+			ageAtDeathMinDays = 0
+		}
+		ageAtDeathMaxDays := daysBetween(birth.Earliest, death.Latest)
+
+		country := ancestorCountry(individual, birthSource, deathSource, registry, options)
+		reference := registry[country]
+
+		gender, sexInferred, knownSex := ancestorSex(individual, options.UnknownSex)
+		if !knownSex {
+			exclusions = append(exclusions, newExclusion(individual, paths, UnknownSexExclusion, fmt.Sprintf("sex recorded as '%s'", individual.Sex)))
+			continue
+		}
+		deathStats, externalDeathStats := reference.deathStats(gender)
+
+		var deathStat DeathStat
+		var cohortTable *LifeTable
+		statsMatch := ExactYearPolicy
+		statsForYear := false
+		if options.Comparison == CohortComparison {
+			cohortTable = reference.cohortLifeTables(gender)[birthDate.Year()]
+			deathStat, statsForYear = cohortTable.deathStat()
+		} else {
+			deathStat, statsMatch, statsForYear = findDeathStat(deathStats, externalDeathStats, deathDate.Year(), options.YearPolicy)
+		}
+
+		if !statsForYear {
+			detail := fmt.Sprintf("no %s stats for %s died in %d", country, describeSex(gender), deathDate.Year())
+			if options.Comparison == CohortComparison {
+				detail = fmt.Sprintf("no %s cohort life table for %s born in %d", country, describeSex(gender), birthDate.Year())
+			}
+			exclusions = append(exclusions, newExclusion(individual, paths, NoStatsForYearExclusion, detail))
+			continue
+		}
+
+		ageAtDeathYears, ageAtDeathDays := DaysToYearsAndDays(ageAtDeathDaysTotal)
+
+		lifeExpectancyDays := deathStat.LifeExpectancyDays
+		survivedTo := conditioningAge(individual, birthDate, options)
+		if survivedTo > ageInYears(birthDate, deathDate) {
+			survivedTo = ageInYears(birthDate, deathDate)
+		}
+		conditionalTable, percentileTable := cohortTable, cohortTable
+		if options.Comparison != CohortComparison {
+			conditionalTable = reference.lifeTables(gender)[birthDate.Year()+survivedTo]
+			percentileTable = reference.lifeTables(gender)[deathDate.Year()]
+		}
+		if survivedTo > 0 {
+			if conditionalDays, ok := conditionalLifeExpectancyDays(conditionalTable, survivedTo); ok {
+				lifeExpectancyDays = conditionalDays
+			} else {
+				survivedTo = 0
+			}
+		}
+
+		survivalPercentile, hasSurvivalPercentile := percentileTable.survivalPercentile(ageAtDeathDaysTotal, survivedTo)
+
+		ancestorDeaths = append(ancestorDeaths, AncestorDeath{
+			Xref:                     individual.Xref,
+			Name:                     IndividualName(individual),
+			Year:                     deathDate.Year(),
+			BirthYear:                birthDate.Year(),
+			GenerationsRemoved:       nearestGeneration(paths),
+			Paths:                    paths,
+			Gender:                   gender,
+			SexInferred:              sexInferred,
+			Country:                  country,
+			StatsYear:                statYear(deathStat),
+			StatsMatch:               statsMatch,
+			AgeAtDeathYears:          ageAtDeathYears,
+			AgeAtDeathDays:           ageAtDeathDays,
+			AgeAtDeathDaysTotal:      ageAtDeathDaysTotal,
+			AgeAtDeathMinDays:        ageAtDeathMinDays,
+			AgeAtDeathMaxDays:        ageAtDeathMaxDays,
+			LifeExpectancyDiffDays:   ageAtDeathDaysTotal - lifeExpectancyDays,
+			MedianAgeAtDeathDiffDays: ageAtDeathDaysTotal - deathStat.MedianAgeAtDeathDays,
+			ModalAgeAtDeathDiffDays:  ageAtDeathDaysTotal - deathStat.ModalAgeAtDeathDays,
+			ModalDeathAgeDays:        deathStat.ModalAgeAtDeathDays,
+			MedianDeathAgeDays:       deathStat.MedianAgeAtDeathDays,
+			LifeExpectancyDays:       lifeExpectancyDays,
+			ConditioningAge:          survivedTo,
+			SurvivalPercentile:       survivalPercentile,
+			HasSurvivalPercentile:    hasSurvivalPercentile,
+			BirthSource:              birthSource,
+			DeathSource:              deathSource,
+			InbreedingCoefficient:    kinship.inbreedingCoefficient(individual),
+			KinshipCoefficient:       kinship.kinship(subject, individual),
+		})
+	}
+	sortExclusions(exclusions)
+	return ancestorDeaths, exclusions
+}
+
+// EarliestYear returns the first year the stats cover.
+func EarliestYear(stats []DeathStat) (int, error) {
+	var earliest int
+	for _, s := range stats {
+		year, err := strconv.Atoi(s.Year)
+		if err != nil {
+			return 0, fmt.Errorf("error parsing year '%s': %s", s.Year, err)
+		}
+		if earliest == 0 || year < earliest {
+			earliest = year
+		}
+	}
+	return earliest, nil
+}
+
+// GetParents returns the individual's parents from every family they're a child of.
+func GetParents(individual *gedcom.IndividualRecord) ([]*gedcom.IndividualRecord, error) {
+	var parents []*gedcom.IndividualRecord
+
+	for _, parentRecord := range individual.Parents {
+		if parentRecord.Family.Husband != nil {
+			parents = append(parents, parentRecord.Family.Husband)
+		}
+
+		if parentRecord.Family.Wife != nil {
+			parents = append(parents, parentRecord.Family.Wife)
+		}
+	}
+	return parents, nil
+}
+
+// DaysToYearsAndDays splits a number of days into whole years and the days left over.
+func DaysToYearsAndDays(daysTotal int) (int, int) {
+	years := daysTotal / 365
+	days := int(math.Abs(float64(daysTotal % 365)))
+	return years, days
+}
+
+func daysBetween(start time.Time, end time.Time) int {
+	return int(end.Unix()-start.Unix()) / 60 / 60 / 24
+}
+
+// GetAncestors walks up the pedigree a generation at a time, counting the paths by which each
+// ancestor is reached at each generation, so an ancestor reached through pedigree collapse has
+// every one of their paths recorded.
+func GetAncestors(subject *gedcom.IndividualRecord) (map[*gedcom.IndividualRecord]AncestorPaths, error) {
+	ancestors := map[*gedcom.IndividualRecord]AncestorPaths{}
+	frontier := map[*gedcom.IndividualRecord]int{subject: 1}
+
+	for generation := 1; len(frontier) > 0; generation++ {
+		// A pedigree can't be deeper than the number of people in it, so going further means
+		// someone has been recorded as their own ancestor.
+		if generation > len(ancestors)+1 {
+			return nil, fmt.Errorf("pedigree contains a loop: an individual is recorded as their own ancestor")
+		}
+
+		next := map[*gedcom.IndividualRecord]int{}
+		for individual, pathCount := range frontier {
+			parents, err := GetParents(individual)
+			if err != nil {
+				return nil, err
+			}
+			for _, parent := range parents {
+				next[parent] += pathCount
+			}
+		}
+
+		for parent, pathCount := range next {
+			if ancestors[parent] == nil {
+				ancestors[parent] = AncestorPaths{}
+			}
+			ancestors[parent][generation] += pathCount
+		}
+		frontier = next
+	}
+
+	return ancestors, nil
+}
+
+func nearestGeneration(paths AncestorPaths) int {
+	nearest := 0
+	for generation := range paths {
+		if nearest == 0 || generation < nearest {
+			nearest = generation
+		}
+	}
+	return nearest
+}
+
+// Count returns the total number of lines of descent, across every generation.
+func (paths AncestorPaths) Count() int {
+	count := 0
+	for _, pathsAtGeneration := range paths {
+		count += pathsAtGeneration
+	}
+	return count
+}
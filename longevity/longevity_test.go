@@ -0,0 +1,688 @@
+package longevity
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/iand/gedcom"
+)
+
+func TestParseDeathStats(t *testing.T) {
+	stats, err := ParseDeathStats("male_death_stats.csv")
+	if err != nil {
+		t.Errorf("Error parsing death stats: %v", err)
+	}
+	if len(stats) == 0 {
+		t.Error("Expected at least one death stat, got none")
+	}
+}
+
+func TestReadDeathStats(t *testing.T) {
+	stats, err := ReadDeathStats(strings.NewReader(`"Modal age at death","year","Median Age At Death","Life expectancy at birth"
+"75.5","1841","44.5","40.5"
+`))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	want := []DeathStat{{
+		Year:                 "1841",
+		LifeExpectancy:       40.5,
+		LifeExpectancyDays:   14782,
+		MedianAgeAtDeath:     44.5,
+		MedianAgeAtDeathDays: 16242,
+		ModalAgeAtDeath:      75.5,
+		ModalAgeAtDeathDays:  27557,
+	}}
+	if !reflect.DeepEqual(stats, want) {
+		t.Errorf("got %+v, want %+v", stats, want)
+	}
+
+	_, err = ReadDeathStats(strings.NewReader("Year,Life expectancy at birth,Modal age at death\n1841,40.5,75.5\n"))
+	if err == nil || !strings.Contains(err.Error(), "Median age at death") {
+		t.Errorf("expected an error naming the missing column, got %v", err)
+	}
+
+	_, err = ReadDeathStats(strings.NewReader("Year,Life expectancy at birth,Median age at death,Modal age at death\n1841,40.5,unknown,75.5\n"))
+	if err == nil || !strings.Contains(err.Error(), "median age at death on line 2") {
+		t.Errorf("expected an error naming the invalid value, got %v", err)
+	}
+}
+
+func TestLoadDeathStats(t *testing.T) {
+	embedded, err := LoadDeathStats("", "", MaleDeathStatsFileName)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	fromFile, err := ParseDeathStats(MaleDeathStatsFileName)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !reflect.DeepEqual(embedded, fromFile) {
+		t.Error("expected the embedded stats to match the bundled file")
+	}
+
+	dir := t.TempDir()
+	stats := "Year,Life expectancy at birth,Median age at death,Modal age at death\n1700,30,35,70\n"
+	if err := os.WriteFile(filepath.Join(dir, FemaleDeathStatsFileName), []byte(stats), 0o644); err != nil {
+		t.Fatalf("failed to write stats: %s", err)
+	}
+	fromDir, err := LoadDeathStats("", dir, FemaleDeathStatsFileName)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(fromDir) != 1 || fromDir[0].Year != "1700" {
+		t.Errorf("expected stats from the stats directory, got %+v", fromDir)
+	}
+
+	if _, err := LoadDeathStats(filepath.Join(dir, "missing.csv"), dir, FemaleDeathStatsFileName); err == nil {
+		t.Error("expected an error for a missing stats file")
+	}
+}
+
+func TestCheckValidYear(t *testing.T) {
+	tests := []struct {
+		dateStr  string
+		expected error
+	}{
+		{dateStr: "1 Jan 2010", expected: nil},
+		{dateStr: "1 Jan a year", expected: fmt.Errorf("date '1 Jan a year' does not contain a four-digit year")},
+		{dateStr: "1 Jan 9999", expected: fmt.Errorf("year in date '1 Jan 9999' is outside valid range")},
+	}
+
+	for _, test := range tests {
+		err := checkValidYear(test.dateStr)
+		if err != nil {
+			if err.Error() != test.expected.Error() {
+				t.Errorf("unexpected error for dateStr %q: got %q, want %q", test.dateStr, err, test.expected)
+			}
+		} else if test.expected != nil {
+			t.Errorf("did not get expected error for dateStr %q: got nil, want %q", test.dateStr, test.expected)
+		}
+	}
+}
+
+func TestGetDeathStatsForAncestors(t *testing.T) {
+	maleDeathStats := []DeathStat{
+		{Year: "1900", LifeExpectancy: 40.0, LifeExpectancyDays: int(40.0) * 365, MedianAgeAtDeath: 40.0, MedianAgeAtDeathDays: int(40.0) * 365, ModalAgeAtDeath: 40.0, ModalAgeAtDeathDays: int(40.0) * 365},
+		{Year: "1950", LifeExpectancy: 49.3, MedianAgeAtDeath: 46.5, ModalAgeAtDeath: 43.8},
+	}
+
+	femaleDeathStats := []DeathStat{
+		{Year: "1800", LifeExpectancy: 50.5, MedianAgeAtDeath: 48.3, ModalAgeAtDeath: 45.6},
+		{Year: "1850", LifeExpectancy: 50.9, MedianAgeAtDeath: 48.5, ModalAgeAtDeath: 45.7},
+	}
+
+	validAncestor := &gedcom.IndividualRecord{
+		Sex: "m",
+		Event: []*gedcom.EventRecord{
+			{
+				Tag:  "BIRT",
+				Date: "1 Jan 1860",
+			},
+			{
+				Tag:  "DEAT",
+				Date: "1 Jan 1900",
+			},
+		},
+	}
+
+	invalidAncestor1 := &gedcom.IndividualRecord{
+		Sex: "f",
+		Event: []*gedcom.EventRecord{
+			{
+				Tag:  "BIRT",
+				Date: "1 Jan 1922",
+			},
+		},
+	}
+
+	invalidAncestor2 := &gedcom.IndividualRecord{
+		Sex: "m",
+		Event: []*gedcom.EventRecord{
+			{
+				Tag:  "DEAT",
+				Date: "1 Jan 1950",
+			},
+		},
+	}
+
+	invalidAncestor3 := &gedcom.IndividualRecord{
+		Sex: "f",
+		Event: []*gedcom.EventRecord{
+			{
+				Tag:  "BIRT",
+				Date: "1 Jan 1900",
+			},
+			{
+				Tag:  "DEAT",
+				Date: "not a date",
+			},
+		},
+	}
+
+	subject := &gedcom.IndividualRecord{
+		Parents: []*gedcom.FamilyLinkRecord{
+			{Family: &gedcom.FamilyRecord{Husband: validAncestor, Wife: invalidAncestor1}},
+			{Family: &gedcom.FamilyRecord{Husband: invalidAncestor2, Wife: invalidAncestor3}},
+		},
+	}
+
+	tests := []struct {
+		name             string
+		subject          *gedcom.IndividualRecord
+		maleDeathStats   []DeathStat
+		femaleDeathStats []DeathStat
+		want             []AncestorDeath
+	}{
+		{
+			name:             "valid ancestor",
+			subject:          subject,
+			maleDeathStats:   maleDeathStats,
+			femaleDeathStats: femaleDeathStats,
+			want: []AncestorDeath{
+				{
+					Name:                     "(unnamed)",
+					Year:                     1900,
+					BirthYear:                1860,
+					GenerationsRemoved:       1,
+					Paths:                    AncestorPaths{1: 1},
+					Gender:                   "m",
+					Country:                  DefaultCountry,
+					StatsYear:                1900,
+					StatsMatch:               ExactYearPolicy,
+					AgeAtDeathYears:          40,
+					AgeAtDeathDays:           10,
+					AgeAtDeathDaysTotal:      14610,
+					AgeAtDeathMinDays:        14610,
+					AgeAtDeathMaxDays:        14610,
+					LifeExpectancyDiffDays:   10,
+					MedianAgeAtDeathDiffDays: 10,
+					ModalAgeAtDeathDiffDays:  10,
+					ModalDeathAgeDays:        14600,
+					MedianDeathAgeDays:       14600,
+					LifeExpectancyDays:       14600,
+					BirthSource:              "BIRT",
+					DeathSource:              "DEAT",
+					KinshipCoefficient:       0.25,
+				},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		analyzer, err := NewAnalyzer(ReferenceRegistry{DefaultCountry: ReferenceData{MaleDeathStats: test.maleDeathStats, FemaleDeathStats: test.femaleDeathStats}}, Options{})
+		if err != nil {
+			t.Fatalf("test %q: unexpected error: %s", test.name, err)
+		}
+		result, err := analyzer.AnalyzeSubject(test.subject)
+		if err != nil {
+			t.Fatalf("test %q: unexpected error: %s", test.name, err)
+		}
+		if !reflect.DeepEqual(result.Ancestors, test.want) {
+			t.Errorf("test %q: got %v, want %v", test.name, result.Ancestors, test.want)
+		}
+		if len(result.Exclusions) != 3 {
+			t.Errorf("test %q: expected 3 exclusions, got %v", test.name, result.Exclusions)
+		}
+	}
+
+}
+
+func TestYearRangeMidpoint(t *testing.T) {
+	testCases := map[string]time.Time{
+		"2005-2007":   time.Date(2006, 1, 1, 0, 0, 0, 0, time.UTC),
+		"2004 - 2007": time.Date(2005, 7, 2, 0, 0, 0, 0, time.UTC),
+		"1900-  1950": time.Date(1925, 1, 1, 0, 0, 0, 0, time.UTC),
+	}
+
+	for dateStr, expectedParsedDate := range testCases {
+		t.Run(dateStr, func(t *testing.T) {
+			parsed, err := yearRangeMidpoint(dateStr)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if !parsed.Equal(expectedParsedDate) {
+				t.Errorf("expected '%s' to parse as '%s' but got '%s'", dateStr, expectedParsedDate, parsed)
+			}
+		})
+	}
+}
+
+func TestCleanDate(t *testing.T) {
+	testCases := map[string]string{
+		"1st December 2010": "1 December 2010",
+		"2nd Feb 1810":      "2 February 1810",
+		"3rd  Sept  1900":   "3 September 1900",
+		"4th June 1890":     "4 June 1890",
+	}
+
+	for dateStr, expectedCleanedDateStr := range testCases {
+		t.Run(dateStr, func(t *testing.T) {
+			cleanedDateStr := cleanDate(dateStr)
+			if cleanedDateStr != expectedCleanedDateStr {
+				t.Errorf("expected '%s' to parse as '%s' but got '%s'", dateStr, expectedCleanedDateStr, cleanedDateStr)
+			}
+		})
+	}
+}
+func TestParseDate(t *testing.T) {
+	testCases := map[string]time.Time{
+		"2009":             time.Date(2009, 1, 1, 0, 0, 0, 0, time.UTC),
+		"15 February 1943": time.Date(1943, 2, 15, 0, 0, 0, 0, time.UTC),
+		"15 Feb 1943":      time.Date(1943, 2, 15, 0, 0, 0, 0, time.UTC),
+		"Sep 1984":         time.Date(1984, 9, 1, 0, 0, 0, 0, time.UTC),
+		"Sept 1984":        time.Date(1984, 9, 1, 0, 0, 0, 0, time.UTC),
+		"2 Sept 1984":      time.Date(1984, 9, 2, 0, 0, 0, 0, time.UTC),
+		"About 1800":       time.Date(1800, 1, 1, 0, 0, 0, 0, time.UTC),
+		"about 1800":       time.Date(1800, 1, 1, 0, 0, 0, 0, time.UTC),
+		"abt 1800":         time.Date(1800, 1, 1, 0, 0, 0, 0, time.UTC),
+		"abt. 1800":        time.Date(1800, 1, 1, 0, 0, 0, 0, time.UTC),
+		"15  March  1900":  time.Date(1900, 3, 15, 0, 0, 0, 0, time.UTC),
+		"21st June 1850":   time.Date(1850, 6, 21, 0, 0, 0, 0, time.UTC),
+		"05/12/1851":       time.Date(1851, 5, 12, 0, 0, 0, 0, time.UTC),
+		"5/12/1851":        time.Date(1851, 5, 12, 0, 0, 0, 0, time.UTC),
+	}
+
+	for dateStr, expectedParsedDate := range testCases {
+		t.Run(dateStr, func(t *testing.T) {
+			parsed, err := ParseDate(dateStr)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if !parsed.Equal(expectedParsedDate) {
+				t.Errorf("expected '%s' to parse as '%s' but got '%s'", dateStr, expectedParsedDate, parsed)
+			}
+		})
+	}
+}
+
+func TestEarliestYear(t *testing.T) {
+	stats := []DeathStat{
+		{Year: "2000"},
+		{Year: "2010"},
+		{Year: "2020"},
+	}
+	year, err := EarliestYear(stats)
+	if err != nil {
+		t.Errorf("Error getting earliest year: %v", err)
+	}
+	if year != 2000 {
+		t.Errorf("Expected earliest year to be 2000, got %d", year)
+	}
+
+	if _, err := EarliestYear([]DeathStat{{Year: "20x0"}}); err == nil {
+		t.Errorf("Expected an error for an unparseable year")
+	}
+}
+
+func TestGetParents(t *testing.T) {
+	individual := &gedcom.IndividualRecord{
+		Parents: []*gedcom.FamilyLinkRecord{
+			{
+				Family: &gedcom.FamilyRecord{
+					Husband: &gedcom.IndividualRecord{},
+					Wife:    &gedcom.IndividualRecord{},
+				},
+			},
+		},
+	}
+	father := individual.Parents[0].Family.Husband
+	mother := individual.Parents[0].Family.Wife
+
+	parents, err := GetParents(individual)
+	if err != nil {
+		t.Errorf("Error getting parents: %v", err)
+	}
+
+	if len(parents) != 2 {
+		t.Errorf("Expected 2 parents, got %d", len(parents))
+	}
+
+	if !containsIndividual(parents, father) {
+		t.Errorf("Expected father to be in parent list")
+	}
+	if !containsIndividual(parents, mother) {
+		t.Errorf("Expected mother to be in parent list")
+	}
+}
+
+func TestGetParentsMultipleFamilyLinkRecords(t *testing.T) {
+	individual := &gedcom.IndividualRecord{
+		Parents: []*gedcom.FamilyLinkRecord{
+			{
+				Family: &gedcom.FamilyRecord{
+					Husband: &gedcom.IndividualRecord{},
+				},
+			},
+			{
+				Family: &gedcom.FamilyRecord{
+					Wife: &gedcom.IndividualRecord{},
+				},
+			},
+		},
+	}
+	father := individual.Parents[0].Family.Husband
+	mother := individual.Parents[1].Family.Wife
+
+	parents, err := GetParents(individual)
+	if err != nil {
+		t.Errorf("Error getting parents: %v", err)
+	}
+
+	if len(parents) != 2 {
+		t.Errorf("Expected 2 parents, got %d", len(parents))
+	}
+
+	if !containsIndividual(parents, father) {
+		t.Errorf("Expected father to be in parent list")
+	}
+
+	if !containsIndividual(parents, mother) {
+		t.Errorf("Expected mother to be in parent list")
+	}
+}
+
+func containsIndividual(individuals []*gedcom.IndividualRecord, individual *gedcom.IndividualRecord) bool {
+	for _, i := range individuals {
+		if i == individual {
+			return true
+		}
+	}
+	return false
+}
+func TestGetAncestors(t *testing.T) {
+	subject := &gedcom.IndividualRecord{
+		Parents: []*gedcom.FamilyLinkRecord{
+			{
+				Family: &gedcom.FamilyRecord{
+					Husband: &gedcom.IndividualRecord{
+						Parents: []*gedcom.FamilyLinkRecord{
+							{
+								Family: &gedcom.FamilyRecord{
+									Husband: &gedcom.IndividualRecord{},
+									Wife:    &gedcom.IndividualRecord{},
+								},
+							},
+						},
+					},
+					Wife: &gedcom.IndividualRecord{
+						Parents: []*gedcom.FamilyLinkRecord{
+							{
+								Family: &gedcom.FamilyRecord{
+									Husband: &gedcom.IndividualRecord{},
+									Wife:    &gedcom.IndividualRecord{},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+	father := subject.Parents[0].Family.Husband
+	mother := subject.Parents[0].Family.Wife
+	fathersMother := father.Parents[0].Family.Wife
+	fathersFather := father.Parents[0].Family.Husband
+	mothersMother := mother.Parents[0].Family.Wife
+	mothersFather := mother.Parents[0].Family.Husband
+
+	ancestors, err := GetAncestors(subject)
+	if err != nil {
+		t.Errorf("Error getting ancestors: %v", err)
+	}
+
+	if len(ancestors) != 6 {
+		t.Errorf("Expected 6 ancestors, got %d", len(ancestors))
+	}
+
+	if !reflect.DeepEqual(ancestors[father], AncestorPaths{1: 1}) {
+		t.Errorf("Expected father to be 1 generation removed, got %v", ancestors[father])
+	}
+
+	if !reflect.DeepEqual(ancestors[mother], AncestorPaths{1: 1}) {
+		t.Errorf("Expected mother to be 1 generation removed, got %v", ancestors[mother])
+	}
+
+	if !reflect.DeepEqual(ancestors[fathersMother], AncestorPaths{2: 1}) {
+		t.Errorf("Expected paternal grandmother to be 2 generation removed, got %v", ancestors[fathersMother])
+	}
+
+	if !reflect.DeepEqual(ancestors[fathersFather], AncestorPaths{2: 1}) {
+		t.Errorf("Expected paternal grandfather to be 2 generation removed, got %v", ancestors[fathersFather])
+	}
+
+	if !reflect.DeepEqual(ancestors[mothersFather], AncestorPaths{2: 1}) {
+		t.Errorf("Expected maternal grandfather to be 2 generation removed, got %v", ancestors[mothersFather])
+	}
+
+	if !reflect.DeepEqual(ancestors[mothersMother], AncestorPaths{2: 1}) {
+		t.Errorf("Expected maternal grandmother to be 2 generation removed, got %v", ancestors[mothersMother])
+	}
+}
+
+func TestCalculateWeightedAverages(t *testing.T) {
+	ancestors := []AncestorDeath{
+		{
+			Gender:                   "f",
+			LifeExpectancyDiffDays:   4,
+			MedianAgeAtDeathDiffDays: 5,
+			ModalAgeAtDeathDiffDays:  6,
+			GenerationsRemoved:       1,
+		},
+		{
+			Gender:                   "f",
+			LifeExpectancyDiffDays:   10,
+			MedianAgeAtDeathDiffDays: 11,
+			ModalAgeAtDeathDiffDays:  12,
+			GenerationsRemoved:       2,
+		},
+		{
+			Gender:                   "m",
+			LifeExpectancyDiffDays:   50,
+			MedianAgeAtDeathDiffDays: 500,
+			ModalAgeAtDeathDiffDays:  10,
+			GenerationsRemoved:       1,
+		},
+		{
+			Gender:                   "m",
+			LifeExpectancyDiffDays:   100,
+			MedianAgeAtDeathDiffDays: 1000,
+			ModalAgeAtDeathDiffDays:  20,
+			GenerationsRemoved:       3,
+		},
+	}
+
+	tests := []struct {
+		name       string
+		ancestors  []AncestorDeath
+		gender     string
+		wantLife   int
+		wantMedian int
+		wantModal  int
+	}{
+		{
+			name:       "female",
+			ancestors:  ancestors,
+			gender:     "f",
+			wantLife:   6,
+			wantMedian: 7,
+			wantModal:  8,
+		},
+		{
+			name:       "male",
+			ancestors:  ancestors,
+			gender:     "m",
+			wantLife:   60,
+			wantMedian: 600,
+			wantModal:  12,
+		},
+		{
+			name:       "all",
+			ancestors:  ancestors,
+			gender:     "",
+			wantLife:   30,
+			wantMedian: 276,
+			wantModal:  9,
+		},
+	}
+
+	for _, test := range tests {
+		gotLife, gotMedian, gotModal := CalculateWeightedAverages(test.ancestors, test.gender, GenerationalWeighter{})
+		if gotLife != test.wantLife {
+			t.Errorf("test %q: got %v, want %v", test.name, gotLife, test.wantLife)
+		}
+		if gotMedian != test.wantMedian {
+			t.Errorf("test %q: got %v, want %v", test.name, gotMedian, test.wantMedian)
+		}
+		if gotModal != test.wantModal {
+			t.Errorf("test %q: got %v, want %v", test.name, gotModal, test.wantModal)
+		}
+	}
+}
+
+func TestGetDeathStatsForAncestorsUncertainDates(t *testing.T) {
+	maleDeathStats := []DeathStat{
+		{Year: "1872", LifeExpectancyDays: 14600, MedianAgeAtDeathDays: 14600, ModalAgeAtDeathDays: 14600},
+	}
+
+	ancestor := &gedcom.IndividualRecord{
+		Sex: "M",
+		Event: []*gedcom.EventRecord{
+			{Tag: "BIRT", Date: "ABT 1820"},
+			{Tag: "DEAT", Date: "BET 1870 AND 1875"},
+		},
+	}
+
+	subject := &gedcom.IndividualRecord{Parents: []*gedcom.FamilyLinkRecord{{Family: &gedcom.FamilyRecord{Husband: ancestor}}}}
+
+	analyzer, err := NewAnalyzer(ReferenceRegistry{DefaultCountry: ReferenceData{MaleDeathStats: maleDeathStats}}, Options{})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	result, err := analyzer.AnalyzeSubject(subject)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	got := result.Ancestors
+	if len(got) != 1 {
+		t.Fatalf("expected 1 ancestor, got %d", len(got))
+	}
+
+	// Born 1 Jan 1818 at the earliest and 31 Dec 1822 at the latest; died between 1 Jan 1870 and 31 Dec 1875.
+	want := daysBetween(utcDate(1822, 12, 31), utcDate(1870, 1, 1))
+	if got[0].AgeAtDeathMinDays != want {
+		t.Errorf("expected minimum age at death of %d days, got %d", want, got[0].AgeAtDeathMinDays)
+	}
+	want = daysBetween(utcDate(1818, 1, 1), utcDate(1875, 12, 31))
+	if got[0].AgeAtDeathMaxDays != want {
+		t.Errorf("expected maximum age at death of %d days, got %d", want, got[0].AgeAtDeathMaxDays)
+	}
+	if got[0].AgeAtDeathMinDays > got[0].AgeAtDeathDaysTotal || got[0].AgeAtDeathMaxDays < got[0].AgeAtDeathDaysTotal {
+		t.Errorf("expected age at death %d to fall within %d to %d", got[0].AgeAtDeathDaysTotal, got[0].AgeAtDeathMinDays, got[0].AgeAtDeathMaxDays)
+	}
+}
+
+func TestCalculateWeightedUncertainty(t *testing.T) {
+	ancestors := []AncestorDeath{
+		{Gender: "f", GenerationsRemoved: 1, AgeAtDeathDaysTotal: 1000, AgeAtDeathMinDays: 1000, AgeAtDeathMaxDays: 1000},
+		{Gender: "m", GenerationsRemoved: 1, AgeAtDeathDaysTotal: 1000, AgeAtDeathMinDays: 700, AgeAtDeathMaxDays: 1600},
+		{Gender: "m", GenerationsRemoved: 2, AgeAtDeathDaysTotal: 1000, AgeAtDeathMinDays: 400, AgeAtDeathMaxDays: 1300},
+	}
+
+	tests := []struct {
+		name      string
+		gender    string
+		wantLower int
+		wantUpper int
+	}{
+		{name: "female", gender: "f", wantLower: 0, wantUpper: 0},
+		{name: "male", gender: "m", wantLower: -400, wantUpper: 500},
+		{name: "all", gender: "", wantLower: -240, wantUpper: 300},
+	}
+
+	for _, test := range tests {
+		gotLower, gotUpper := CalculateWeightedUncertainty(ancestors, test.gender, GenerationalWeighter{})
+		if gotLower != test.wantLower || gotUpper != test.wantUpper {
+			t.Errorf("test %q: got %d to %d, want %d to %d", test.name, gotLower, gotUpper, test.wantLower, test.wantUpper)
+		}
+	}
+}
+
+const collapsedPedigreeTree = `0 HEAD
+0 @S@ INDI
+1 FAMC @F1@
+0 @F@ INDI
+1 FAMS @F1@
+1 FAMC @F2@
+0 @M@ INDI
+1 FAMS @F1@
+1 FAMC @F3@
+0 @MF@ INDI
+1 FAMS @F3@
+1 FAMC @F2@
+0 @GF@ INDI
+1 FAMS @F2@
+0 @F1@ FAM
+1 HUSB @F@
+1 WIFE @M@
+1 CHIL @S@
+0 @F2@ FAM
+1 HUSB @GF@
+1 CHIL @F@
+1 CHIL @MF@
+0 @F3@ FAM
+1 HUSB @MF@
+1 CHIL @M@
+0 TRLR
+`
+
+func TestGetAncestorsPedigreeCollapse(t *testing.T) {
+	individuals := individualsByXref(decodeTestTree(t, collapsedPedigreeTree))
+
+	ancestors, err := GetAncestors(individuals["S"])
+	if err != nil {
+		t.Fatalf("Error getting ancestors: %v", err)
+	}
+
+	want := map[string]AncestorPaths{
+		"F":  {1: 1},
+		"M":  {1: 1},
+		"MF": {2: 1},
+		"GF": {2: 1, 3: 1},
+	}
+	if len(ancestors) != len(want) {
+		t.Errorf("Expected %d ancestors, got %d", len(want), len(ancestors))
+	}
+	for xref, wantPaths := range want {
+		if !reflect.DeepEqual(ancestors[individuals[xref]], wantPaths) {
+			t.Errorf("Expected paths %v for %s, got %v", wantPaths, xref, ancestors[individuals[xref]])
+		}
+	}
+}
+
+func TestGetAncestorsLoop(t *testing.T) {
+	individual := &gedcom.IndividualRecord{}
+	individual.Parents = []*gedcom.FamilyLinkRecord{{Family: &gedcom.FamilyRecord{Husband: individual}}}
+
+	if _, err := GetAncestors(individual); err == nil {
+		t.Error("Expected an error for an individual who is their own parent")
+	}
+}
+
+func TestCalculateWeightedAveragesPedigreeCollapse(t *testing.T) {
+	ancestors := []AncestorDeath{
+		{Gender: "m", GenerationsRemoved: 1, Paths: AncestorPaths{1: 1}, MedianAgeAtDeathDiffDays: 0},
+		{Gender: "m", GenerationsRemoved: 2, Paths: AncestorPaths{2: 1, 3: 1}, MedianAgeAtDeathDiffDays: 70},
+	}
+
+	// The first ancestor has a weight of 4 and the second 2 + 1 for their two paths.
+	_, gotMedian, _ := CalculateWeightedAverages(ancestors, "", GenerationalWeighter{})
+	if gotMedian != 30 {
+		t.Errorf("got %d, want 30", gotMedian)
+	}
+}
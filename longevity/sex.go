@@ -0,0 +1,128 @@
+package longevity
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/iand/gedcom"
+)
+
+// Unknown sex policies choose how ancestors whose sex isn't recorded as M or F are compared.
+const (
+	ExcludeUnknownSex  = "exclude"
+	CombinedUnknownSex = "combined"
+	InferUnknownSex    = "infer"
+)
+
+var UnknownSexPolicies = []string{ExcludeUnknownSex, CombinedUnknownSex, InferUnknownSex}
+
+const UnknownSex = "u"
+
+func checkUnknownSexPolicy(policy string) error {
+	for _, known := range UnknownSexPolicies {
+		if policy == known {
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown sex policy '%s', expected one of %v", policy, UnknownSexPolicies)
+}
+
+// normaliseSex returns "m" or "f" for individuals recorded as male or female and "u" for anyone
+// else, including the GEDCOM U and X values and a missing SEX.
+func normaliseSex(sex string) string {
+	switch strings.ToLower(strings.TrimSpace(sex)) {
+	case "m":
+		return "m"
+	case "f":
+		return "f"
+	}
+	return UnknownSex
+}
+
+// sexFromFamilyRole infers someone's sex from whether they're the husband or wife in a family they
+// head.
+func sexFromFamilyRole(individual *gedcom.IndividualRecord) (string, bool) {
+	for _, familyLink := range individual.Family {
+		if familyLink.Family == nil {
+			continue
+		}
+		if familyLink.Family.Husband == individual {
+			return "m", true
+		}
+		if familyLink.Family.Wife == individual {
+			return "f", true
+		}
+	}
+	return "", false
+}
+
+// ancestorSex returns the sex whose statistics the ancestor should be compared with, whether it was
+// inferred, and false if the ancestor should be excluded. Under the combined policy an unknown sex
+// is returned as "u" and compared with the statistics for both sexes together.
+func ancestorSex(individual *gedcom.IndividualRecord, policy string) (string, bool, bool) {
+	sex := normaliseSex(individual.Sex)
+	if sex != UnknownSex {
+		return sex, false, true
+	}
+	switch policy {
+	case CombinedUnknownSex:
+		return UnknownSex, false, true
+	case InferUnknownSex:
+		if inferred, ok := sexFromFamilyRole(individual); ok {
+			return inferred, true, true
+		}
+	}
+	return UnknownSex, false, false
+}
+
+func describeSex(gender string) string {
+	switch gender {
+	case "m":
+		return "men"
+	case "f":
+		return "women"
+	}
+	return "people"
+}
+
+// averageDeathStats stands in for statistics covering both sexes by averaging the male and female
+// statistics for each year they both cover.
+func averageDeathStats(male []DeathStat, female []DeathStat) []DeathStat {
+	var combined []DeathStat
+	for _, maleStat := range male {
+		femaleStat, ok := exactDeathStat(female, statYear(maleStat))
+		if !ok {
+			continue
+		}
+		stat := DeathStat{
+			Year:             maleStat.Year,
+			LifeExpectancy:   (maleStat.LifeExpectancy + femaleStat.LifeExpectancy) / 2,
+			MedianAgeAtDeath: (maleStat.MedianAgeAtDeath + femaleStat.MedianAgeAtDeath) / 2,
+			ModalAgeAtDeath:  (maleStat.ModalAgeAtDeath + femaleStat.ModalAgeAtDeath) / 2,
+		}
+		stat.LifeExpectancyDays = int(stat.LifeExpectancy * 365)
+		stat.MedianAgeAtDeathDays = int(stat.MedianAgeAtDeath * 365)
+		stat.ModalAgeAtDeathDays = int(stat.ModalAgeAtDeath * 365)
+		combined = append(combined, stat)
+	}
+	return combined
+}
+
+// deathStats returns the main and external death stats for the given sex. Statistics for both sexes
+// together are the combined ones if any were loaded, or the average of the male and female ones.
+func (r ReferenceData) deathStats(sex string) ([]DeathStat, []DeathStat) {
+	switch sex {
+	case "m":
+		return r.MaleDeathStats, r.MaleExternalDeathStats
+	case "f":
+		return r.FemaleDeathStats, r.FemaleExternalDeathStats
+	}
+	stats, external := r.CombinedDeathStats, r.CombinedExternalDeathStats
+	if stats == nil {
+		stats = averageDeathStats(r.MaleDeathStats, r.FemaleDeathStats)
+	}
+	if external == nil {
+		external = averageDeathStats(r.MaleExternalDeathStats, r.FemaleExternalDeathStats)
+	}
+	return stats, external
+}
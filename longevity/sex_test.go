@@ -0,0 +1,129 @@
+package longevity
+
+import (
+	"testing"
+)
+
+const unknownSexTestTree = `0 HEAD
+0 @I1@ INDI
+1 NAME Subject /Smith/
+1 FAMC @F1@
+0 @I2@ INDI
+1 NAME Husband /Smith/
+1 BIRT
+2 DATE 1850
+1 DEAT
+2 DATE 1900
+1 FAMS @F1@
+0 @I3@ INDI
+1 NAME Wife /Jones/
+1 SEX U
+1 BIRT
+2 DATE 1860
+1 DEAT
+2 DATE 1900
+1 FAMS @F1@
+1 FAMC @F2@
+0 @I4@ INDI
+1 NAME Parent /Jones/
+1 SEX X
+1 BIRT
+2 DATE 1830
+1 DEAT
+2 DATE 1900
+0 @F1@ FAM
+1 HUSB @I2@
+1 WIFE @I3@
+1 CHIL @I1@
+0 @F2@ FAM
+1 HUSB @I4@
+1 CHIL @I3@
+0 TRLR
+`
+
+func TestNormaliseSex(t *testing.T) {
+	tests := map[string]string{"M": "m", "f": "f", " F ": "f", "U": "u", "X": "u", "": "u"}
+	for sex, want := range tests {
+		if got := normaliseSex(sex); got != want {
+			t.Errorf("normaliseSex(%q) = %q, want %q", sex, got, want)
+		}
+	}
+}
+
+func TestAverageDeathStats(t *testing.T) {
+	male := []DeathStat{{Year: "1900", LifeExpectancy: 40, MedianAgeAtDeath: 50, ModalAgeAtDeath: 70}, {Year: "1901", LifeExpectancy: 41}}
+	female := []DeathStat{{Year: "1900", LifeExpectancy: 44, MedianAgeAtDeath: 56, ModalAgeAtDeath: 76}}
+
+	got := averageDeathStats(male, female)
+	if len(got) != 1 {
+		t.Fatalf("expected only the year both sexes cover, got %+v", got)
+	}
+	if got[0].LifeExpectancy != 42 || got[0].MedianAgeAtDeath != 53 || got[0].ModalAgeAtDeath != 73 {
+		t.Errorf("got %+v", got[0])
+	}
+	if got[0].LifeExpectancyDays != 42*365 || got[0].MedianAgeAtDeathDays != 53*365 || got[0].ModalAgeAtDeathDays != 73*365 {
+		t.Errorf("got %+v", got[0])
+	}
+}
+
+func TestGetDeathStatsForAncestorsUnknownSex(t *testing.T) {
+	g := decodeTestTree(t, unknownSexTestTree)
+	ancestors, err := GetAncestors(individualsByXref(g)["I1"])
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	registry := ReferenceRegistry{DefaultCountry: {
+		MaleDeathStats:   []DeathStat{{Year: "1900", LifeExpectancy: 40, LifeExpectancyDays: 40 * 365}},
+		FemaleDeathStats: []DeathStat{{Year: "1900", LifeExpectancy: 50, LifeExpectancyDays: 50 * 365}},
+	}}
+
+	type result struct {
+		gender   string
+		inferred bool
+		leDays   int
+	}
+	tests := []struct {
+		policy   string
+		want     map[int]result
+		excluded []string
+	}{
+		// The husband and wife are inferred from their roles, but the parent's own record has no
+		// link to the family they head.
+		{InferUnknownSex, map[int]result{1850: {"m", true, 40 * 365}, 1860: {"f", true, 50 * 365}}, []string{"I4"}},
+		{ExcludeUnknownSex, map[int]result{}, []string{"I2", "I3", "I4"}},
+		{"", map[int]result{}, []string{"I2", "I3", "I4"}},
+		{CombinedUnknownSex, map[int]result{1850: {"u", false, 45 * 365}, 1860: {"u", false, 45 * 365}, 1830: {"u", false, 45 * 365}}, nil},
+	}
+	for _, tt := range tests {
+		deaths, exclusions := getDeathStatsForAncestors(nil, ancestors, registry, Options{UnknownSex: tt.policy})
+		got := map[int]result{}
+		for _, death := range deaths {
+			got[death.BirthYear] = result{death.Gender, death.SexInferred, death.LifeExpectancyDays}
+		}
+		if len(got) != len(tt.want) {
+			t.Errorf("%q: got %+v, want %+v", tt.policy, got, tt.want)
+		}
+		for year, want := range tt.want {
+			if got[year] != want {
+				t.Errorf("%q: ancestor born in %d: got %+v, want %+v", tt.policy, year, got[year], want)
+			}
+		}
+		var excluded []string
+		for _, exclusion := range exclusions {
+			if exclusion.Reason != UnknownSexExclusion {
+				t.Errorf("%q: unexpected exclusion %+v", tt.policy, exclusion)
+			}
+			excluded = append(excluded, exclusion.Xref)
+		}
+		if len(excluded) != len(tt.excluded) {
+			t.Errorf("%q: excluded %v, want %v", tt.policy, excluded, tt.excluded)
+			continue
+		}
+		for i := range excluded {
+			if excluded[i] != tt.excluded[i] {
+				t.Errorf("%q: excluded %v, want %v", tt.policy, excluded, tt.excluded)
+				break
+			}
+		}
+	}
+}
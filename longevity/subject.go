@@ -0,0 +1,165 @@
+package longevity
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/iand/gedcom"
+)
+
+const birthYearTolerance = 1
+
+var subjectBirthYearRegex = regexp.MustCompile(`^(.*?)[\s,]*(?:\(\s*)?(?:b\.?\s*|born\s+)?(\d{4})\s*\)?$`)
+
+// IndividualName returns the individual's first name without the slashes around the surname.
+func IndividualName(individual *gedcom.IndividualRecord) string {
+	if individual == nil || len(individual.Name) == 0 {
+		return "(unnamed)"
+	}
+	return gedcom.SplitPersonalName(individual.Name[0].Name).Full
+}
+
+// BirthYear returns the year of the individual's first dated birth event.
+func BirthYear(individual *gedcom.IndividualRecord) (int, bool) {
+	return eventYear(individual, "BIRT")
+}
+
+// DeathYear returns the year of the individual's first dated death event.
+func DeathYear(individual *gedcom.IndividualRecord) (int, bool) {
+	return eventYear(individual, "DEAT")
+}
+
+// eventYear returns the year of the first event with the given tag that has a usable date.
+func eventYear(individual *gedcom.IndividualRecord, tag string) (int, bool) {
+	for _, event := range individual.Event {
+		if event.Tag != tag {
+			continue
+		}
+		date, err := ParseDateValue(event.Date)
+		if err != nil {
+			continue
+		}
+		return date.Estimate.Year(), true
+	}
+	return 0, false
+}
+
+func describeIndividual(individual *gedcom.IndividualRecord) string {
+	description := fmt.Sprintf("@%s@ %s", individual.Xref, IndividualName(individual))
+	if year, ok := BirthYear(individual); ok {
+		description += fmt.Sprintf(" (b. %d)", year)
+	}
+	return description
+}
+
+func matchesXref(individual *gedcom.IndividualRecord, query string) bool {
+	return individual.Xref != "" && strings.EqualFold(individual.Xref, strings.Trim(query, "@"))
+}
+
+func matchesReference(individual *gedcom.IndividualRecord, query string) bool {
+	for _, reference := range individual.UserReference {
+		if strings.EqualFold(reference.Number, query) {
+			return true
+		}
+	}
+	for _, tag := range individual.UserDefined {
+		if (tag.Tag == "_UID" || tag.Tag == "UID") && strings.EqualFold(normaliseUID(tag.Value), normaliseUID(query)) {
+			return true
+		}
+	}
+	return false
+}
+
+func normaliseUID(uid string) string {
+	return strings.ReplaceAll(strings.TrimSpace(uid), "-", "")
+}
+
+func matchesName(individual *gedcom.IndividualRecord, nameTokens []string) bool {
+	for _, name := range individual.Name {
+		fullName := strings.ToLower(gedcom.SplitPersonalName(name.Name).Full)
+		matched := true
+		for _, token := range nameTokens {
+			if !strings.Contains(fullName, token) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return true
+		}
+	}
+	return false
+}
+
+func splitNameAndBirthYear(query string) ([]string, int, bool) {
+	name := query
+	year, hasYear := 0, false
+	if matches := subjectBirthYearRegex.FindStringSubmatch(query); matches != nil {
+		name = matches[1]
+		year, _ = strconv.Atoi(matches[2])
+		hasYear = true
+	}
+	name = strings.ReplaceAll(strings.ToLower(name), "/", " ")
+	return strings.Fields(name), year, hasYear
+}
+
+// FindSubject picks the individual whose ancestors are analysed. The query may be a GEDCOM xref
+// (e.g. "@I123@"), a REFN or UID, or a name optionally followed by a birth year (e.g. "John Smith 1850").
+// An empty query selects the first individual in the tree.
+func FindSubject(g *gedcom.Gedcom, query string) (*gedcom.IndividualRecord, error) {
+	if len(g.Individual) == 0 {
+		return nil, fmt.Errorf("tree contains no individuals")
+	}
+
+	query = strings.TrimSpace(query)
+	if query == "" {
+		return g.Individual[0], nil
+	}
+
+	for _, individual := range g.Individual {
+		if matchesXref(individual, query) {
+			return individual, nil
+		}
+	}
+
+	var candidates []*gedcom.IndividualRecord
+	for _, individual := range g.Individual {
+		if matchesReference(individual, query) {
+			candidates = append(candidates, individual)
+		}
+	}
+
+	if len(candidates) == 0 {
+		nameTokens, year, hasYear := splitNameAndBirthYear(query)
+		if len(nameTokens) == 0 {
+			return nil, fmt.Errorf("no individual matches '%s'", query)
+		}
+		for _, individual := range g.Individual {
+			if !matchesName(individual, nameTokens) {
+				continue
+			}
+			if hasYear {
+				individualBirthYear, ok := BirthYear(individual)
+				if !ok || individualBirthYear < year-birthYearTolerance || individualBirthYear > year+birthYearTolerance {
+					continue
+				}
+			}
+			candidates = append(candidates, individual)
+		}
+	}
+
+	switch len(candidates) {
+	case 0:
+		return nil, fmt.Errorf("no individual matches '%s'", query)
+	case 1:
+		return candidates[0], nil
+	}
+
+	descriptions := make([]string, len(candidates))
+	for i, candidate := range candidates {
+		descriptions[i] = "  " + describeIndividual(candidate)
+	}
+	return nil, fmt.Errorf("'%s' matches %d individuals, use an xref or add a birth year to choose one:\n%s", query, len(candidates), strings.Join(descriptions, "\n"))
+}
@@ -0,0 +1,87 @@
+package longevity
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/iand/gedcom"
+)
+
+const subjectTestTree = `0 HEAD
+0 @I1@ INDI
+1 NAME John /Smith/
+1 BIRT
+2 DATE 12 MAR 1850
+1 REFN 1001
+0 @I2@ INDI
+1 NAME John /Smith/
+1 BIRT
+2 DATE 1880
+1 _UID 4F2A-11B0
+0 @I3@ INDI
+1 NAME Mary Ann /Jones/
+1 BIRT
+2 DATE ABT 1852
+0 TRLR
+`
+
+func decodeTestTree(t *testing.T, tree string) *gedcom.Gedcom {
+	t.Helper()
+	g, err := gedcom.NewDecoder(strings.NewReader(tree)).Decode()
+	if err != nil {
+		t.Fatalf("unexpected error decoding tree: %s", err)
+	}
+	return g
+}
+
+func TestFindSubject(t *testing.T) {
+	g := decodeTestTree(t, subjectTestTree)
+
+	testCases := map[string]string{
+		"":                     "I1",
+		"@I2@":                 "I2",
+		"i3":                   "I3",
+		"1001":                 "I1",
+		"4f2a11b0":             "I2",
+		"John Smith 1880":      "I2",
+		"john smith (b. 1849)": "I1",
+		"mary jones":           "I3",
+		"Ann Jones 1852":       "I3",
+	}
+
+	for query, expectedXref := range testCases {
+		t.Run(query, func(t *testing.T) {
+			subject, err := FindSubject(g, query)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if subject.Xref != expectedXref {
+				t.Errorf("expected '%s' to select %s but got %s", query, expectedXref, subject.Xref)
+			}
+		})
+	}
+}
+
+func TestFindSubjectAmbiguous(t *testing.T) {
+	g := decodeTestTree(t, subjectTestTree)
+
+	_, err := FindSubject(g, "John Smith")
+	if err == nil {
+		t.Fatal("expected an error for an ambiguous name")
+	}
+	for _, candidate := range []string{"@I1@ John Smith (b. 1850)", "@I2@ John Smith (b. 1880)"} {
+		if !strings.Contains(err.Error(), candidate) {
+			t.Errorf("expected error to list candidate %q, got %q", candidate, err)
+		}
+	}
+}
+
+func TestFindSubjectNoMatch(t *testing.T) {
+	g := decodeTestTree(t, subjectTestTree)
+
+	for _, query := range []string{"@I9@", "Jane Doe", "John Smith 1900"} {
+		if _, err := FindSubject(g, query); err == nil {
+			t.Errorf("expected an error for query %q", query)
+		}
+	}
+}
@@ -0,0 +1,157 @@
+package longevity
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/iand/gedcom"
+)
+
+// Tree formats recognised by ReadTree.
+const (
+	Gedcom5Format = "gedcom-5"
+	Gedcom7Format = "gedcom-7"
+	GedzipFormat  = "gedzip"
+	GrampsFormat  = "gramps"
+)
+
+// gedzipTreeFileName is the GEDCOM file at the root of every GEDZIP archive, alongside any media.
+const gedzipTreeFileName = "gedcom.ged"
+
+// DefaultMaxUnpackedTreeBytes is a generous cap on the unzipped size of the tree in a GEDZIP archive
+// or gzipped Gramps file, for trees read from a file the user chose.
+const DefaultMaxUnpackedTreeBytes = 512 << 20
+
+var (
+	zipSignature  = []byte("PK\x03\x04")
+	gzipSignature = []byte("\x1f\x8b")
+)
+
+// byteOrderMark is allowed at the start of a GEDCOM 7 file, and some GEDCOM 5.5.1 exporters write
+// one too.
+const byteOrderMark = "\ufeff"
+
+// GedcomVersion returns the version in a GEDCOM file's header (HEAD.GEDC.VERS), such as "5.5.1" or
+// "7.0", or "" if the header doesn't give one.
+func GedcomVersion(data []byte) string {
+	scanner := bufio.NewScanner(bytes.NewReader(bytes.TrimPrefix(data, []byte(byteOrderMark))))
+	scanner.Buffer(nil, 1<<20)
+	inGedc := false
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 {
+			continue
+		}
+		switch {
+		case fields[0] == "0":
+			// The header is always the first record, so the version is missing once it's passed.
+			if fields[1] != "HEAD" {
+				return ""
+			}
+		case fields[0] == "1":
+			inGedc = fields[1] == "GEDC"
+		case fields[0] == "2" && inGedc && fields[1] == "VERS" && len(fields) > 2:
+			return fields[2]
+		}
+	}
+	return ""
+}
+
+// DetectTreeFormat works out the format of a family tree file from its content: a zip archive is
+// taken to be GEDZIP, a gzipped or XML file to be a Gramps database (as Gramps saves .gramps files
+// gzipped) and a GEDCOM file is told apart by the version in its header, with files that don't give
+// one read as GEDCOM 5.5.1.
+func DetectTreeFormat(data []byte) string {
+	if bytes.HasPrefix(data, zipSignature) {
+		return GedzipFormat
+	}
+	if bytes.HasPrefix(data, gzipSignature) || bytes.HasPrefix(bytes.TrimSpace(bytes.TrimPrefix(data, []byte(byteOrderMark))), []byte("<")) {
+		return GrampsFormat
+	}
+	if strings.HasPrefix(GedcomVersion(data), "7.") {
+		return Gedcom7Format
+	}
+	return Gedcom5Format
+}
+
+// ReadTree decodes a family tree in any of the formats DetectTreeFormat recognises. A compressed
+// tree that unpacks to more than maxUnpackedBytes is rejected, so a small upload can't expand to fill
+// memory.
+func ReadTree(data []byte, maxUnpackedBytes int64) (*gedcom.Gedcom, error) {
+	switch DetectTreeFormat(data) {
+	case GedzipFormat:
+		tree, err := readGedzip(data, maxUnpackedBytes)
+		if err != nil {
+			return nil, err
+		}
+		if DetectTreeFormat(tree) == Gedcom7Format {
+			return decodeGedcom7(tree)
+		}
+		return decodeGedcom(tree)
+	case Gedcom7Format:
+		return decodeGedcom7(data)
+	case GrampsFormat:
+		if bytes.HasPrefix(data, gzipSignature) {
+			var err error
+			data, err = gunzip(data, maxUnpackedBytes)
+			if err != nil {
+				return nil, err
+			}
+		}
+		return decodeGramps(data)
+	}
+	return decodeGedcom(data)
+}
+
+func decodeGedcom(data []byte) (*gedcom.Gedcom, error) {
+	return gedcom.NewDecoder(bytes.NewReader(bytes.TrimPrefix(data, []byte(byteOrderMark)))).Decode()
+}
+
+// readGedzip returns the GEDCOM file from a GEDZIP archive.
+func readGedzip(data []byte, maxUnpackedBytes int64) ([]byte, error) {
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("reading GEDZIP archive: %v", err)
+	}
+	for _, file := range archive.File {
+		if file.Name != gedzipTreeFileName {
+			continue
+		}
+		reader, err := file.Open()
+		if err != nil {
+			return nil, fmt.Errorf("opening %s in GEDZIP archive: %v", gedzipTreeFileName, err)
+		}
+		defer reader.Close()
+		tree, err := io.ReadAll(io.LimitReader(reader, maxUnpackedBytes+1))
+		if err != nil {
+			return nil, fmt.Errorf("reading %s in GEDZIP archive: %v", gedzipTreeFileName, err)
+		}
+		if int64(len(tree)) > maxUnpackedBytes {
+			return nil, fmt.Errorf("%s in GEDZIP archive is larger than %d bytes", gedzipTreeFileName, maxUnpackedBytes)
+		}
+		return tree, nil
+	}
+	return nil, fmt.Errorf("GEDZIP archive has no %s", gedzipTreeFileName)
+}
+
+// gunzip returns the content of a gzipped file.
+func gunzip(data []byte, maxUnpackedBytes int64) ([]byte, error) {
+	reader, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("reading gzipped file: %v", err)
+	}
+	defer reader.Close()
+	content, err := io.ReadAll(io.LimitReader(reader, maxUnpackedBytes+1))
+	if err != nil {
+		return nil, fmt.Errorf("reading gzipped file: %v", err)
+	}
+	if int64(len(content)) > maxUnpackedBytes {
+		return nil, fmt.Errorf("gzipped file is larger than %d bytes unpacked", maxUnpackedBytes)
+	}
+	return content, nil
+}
@@ -0,0 +1,225 @@
+package longevity
+
+import (
+	"archive/zip"
+	"bytes"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/iand/gedcom"
+)
+
+// gedcom7TestTree is the same family as gedcom5TestTree written as GEDCOM 7, with a father whose
+// own father is unknown and a mother whose birth date is only a phrase.
+const gedcom7TestTree = "\ufeff" + `0 HEAD
+1 GEDC
+2 VERS 7.0
+1 SCHMA
+2 TAG _LIVE https://example.com/living
+0 @I1@ INDI
+1 NAME Ann /Child/
+1 SEX F
+1 BIRT
+2 DATE 1960
+1 FAMC @F1@
+2 PEDI BIRTH
+0 @I2@ INDI
+1 NAME Tom /Father/
+1 SEX M
+1 BIRT
+2 DATE 1 JAN 1900
+1 DEAT
+2 DATE 1 JAN 1970
+3 PHRASE New Year's Day
+1 SNOTE @N1@
+1 FAMS @F1@
+1 FAMC @F2@
+0 @I3@ INDI
+1 NAME Sue /Mother/
+1 SEX F
+1 BIRT
+2 DATE 1 JAN 1905
+1 DEAT
+2 DATE JULIAN 23 DEC 1979
+1 FAMS @F1@
+0 @I4@ INDI
+1 NAME Grace /Father/
+1 SEX X
+1 BIRT
+2 DATE
+3 PHRASE The winter before the famine
+1 FAMS @F2@
+0 @F1@ FAM
+1 HUSB @I2@
+1 WIFE @I3@
+1 CHIL @I1@
+0 @F2@ FAM
+1 HUSB @VOID@
+2 PHRASE Unknown father
+1 WIFE @I4@
+1 CHIL @I2@
+0 @N1@ SNOTE Served in the merchant navy
+0 TRLR
+`
+
+const gedcom5TestTree = `0 HEAD
+1 GEDC
+2 VERS 5.5.1
+0 @I1@ INDI
+1 NAME Ann /Child/
+1 SEX F
+1 BIRT
+2 DATE 1960
+1 FAMC @F1@
+0 @I2@ INDI
+1 NAME Tom /Father/
+1 SEX M
+1 BIRT
+2 DATE 1 JAN 1900
+1 DEAT
+2 DATE 1 JAN 1970
+1 FAMS @F1@
+0 @I3@ INDI
+1 NAME Sue /Mother/
+1 SEX F
+1 BIRT
+2 DATE 1 JAN 1905
+1 DEAT
+2 DATE 5 JAN 1980
+1 FAMS @F1@
+0 @F1@ FAM
+1 HUSB @I2@
+1 WIFE @I3@
+1 CHIL @I1@
+0 TRLR
+`
+
+func gedzip(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	writer := zip.NewWriter(&buf)
+	for name, content := range files {
+		file, err := writer.Create(name)
+		if err != nil {
+			t.Fatalf("unexpected error creating %s: %s", name, err)
+		}
+		file.Write([]byte(content))
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatalf("unexpected error closing archive: %s", err)
+	}
+	return buf.Bytes()
+}
+
+func TestDetectTreeFormat(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    []byte
+		version string
+		format  string
+	}{
+		{name: "gedcom 5", data: []byte(gedcom5TestTree), version: "5.5.1", format: Gedcom5Format},
+		{name: "gedcom 7", data: []byte(gedcom7TestTree), version: "7.0", format: Gedcom7Format},
+		{name: "no version", data: []byte("0 HEAD\n1 CHAR UTF-8\n0 @I1@ INDI\n1 GEDC\n2 VERS 7.0\n0 TRLR\n"), version: "", format: Gedcom5Format},
+		{name: "gedzip", data: gedzip(t, map[string]string{gedzipTreeFileName: gedcom7TestTree}), version: "", format: GedzipFormat},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := GedcomVersion(test.data); got != test.version {
+				t.Errorf("got version %q, want %q", got, test.version)
+			}
+			if got := DetectTreeFormat(test.data); got != test.format {
+				t.Errorf("got format %q, want %q", got, test.format)
+			}
+		})
+	}
+}
+
+func analyzeTestTree(t *testing.T, tree *gedcom.Gedcom) Result {
+	t.Helper()
+	registry, err := DefaultRegistry()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	analyzer, err := NewAnalyzer(registry, DefaultOptions())
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	result, err := analyzer.Analyze(tree)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	return result
+}
+
+// ancestorsByXref orders ancestors by xref, as the order they're analysed in follows the tree.
+func ancestorsByXref(ancestors []AncestorDeath) []AncestorDeath {
+	sort.Slice(ancestors, func(i, j int) bool { return ancestors[i].Xref < ancestors[j].Xref })
+	return ancestors
+}
+
+func TestReadTreeGedcom7(t *testing.T) {
+	tree, err := ReadTree([]byte(gedcom7TestTree), DefaultMaxUnpackedTreeBytes)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	expectedTree, err := ReadTree([]byte(gedcom5TestTree), DefaultMaxUnpackedTreeBytes)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	result := analyzeTestTree(t, tree)
+	expected := analyzeTestTree(t, expectedTree)
+	if !reflect.DeepEqual(ancestorsByXref(result.Ancestors), ancestorsByXref(expected.Ancestors)) {
+		t.Errorf("got ancestors %+v, want the same as GEDCOM 5.5.1 %+v", result.Ancestors, expected.Ancestors)
+	}
+	if len(result.Exclusions) != 1 {
+		t.Fatalf("got %d exclusions, want only the grandmother, with no @VOID@ grandfather: %+v", len(result.Exclusions), result.Exclusions)
+	}
+	exclusion := result.Exclusions[0]
+	if exclusion.Xref != "I4" || exclusion.Reason != BadBirthDateExclusion || !strings.Contains(exclusion.Detail, "The winter before the famine") {
+		t.Errorf("got exclusion %+v, want I4 with a bad birth date giving the date phrase", exclusion)
+	}
+
+	for _, individual := range tree.Individual {
+		if individual.Xref != "I2" {
+			continue
+		}
+		for _, event := range individual.Event {
+			if event.Tag == "DEAT" && event.Date != "INT 1 JAN 1970 (New Year's Day)" {
+				t.Errorf("got death date %q, want the phrase folded into an interpreted date", event.Date)
+			}
+		}
+	}
+}
+
+func TestReadTreeGedzip(t *testing.T) {
+	data := gedzip(t, map[string]string{
+		"photos/tom.jpg":   "not really a photo",
+		gedzipTreeFileName: gedcom7TestTree,
+	})
+	tree, err := ReadTree(data, DefaultMaxUnpackedTreeBytes)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	expectedTree, err := ReadTree([]byte(gedcom7TestTree), DefaultMaxUnpackedTreeBytes)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	result, expected := analyzeTestTree(t, tree), analyzeTestTree(t, expectedTree)
+	if !reflect.DeepEqual(ancestorsByXref(result.Ancestors), ancestorsByXref(expected.Ancestors)) || !reflect.DeepEqual(result.Exclusions, expected.Exclusions) {
+		t.Errorf("got %+v and %+v, want the same result as the GEDCOM file in the archive", result.Ancestors, result.Exclusions)
+	}
+
+	_, err = ReadTree(gedzip(t, map[string]string{"tree.ged": gedcom7TestTree}), DefaultMaxUnpackedTreeBytes)
+	if err == nil || !strings.Contains(err.Error(), "has no gedcom.ged") {
+		t.Errorf("got error %v, want a missing gedcom.ged error", err)
+	}
+
+	_, err = ReadTree(data, int64(len(gedcom7TestTree)-1))
+	if err == nil || !strings.Contains(err.Error(), "larger than") {
+		t.Errorf("got error %v, want an unpacked size error", err)
+	}
+}
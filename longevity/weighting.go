@@ -0,0 +1,204 @@
+package longevity
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/iand/gedcom"
+)
+
+// A Weighter decides how much each ancestor's diffs count towards the weighted averages. Weights
+// are relative, so only their ratios matter.
+type Weighter interface {
+	Name() string
+	Weights(ancestors []AncestorDeath) []float64
+}
+
+const (
+	GenerationalWeighting    = "generational"
+	KinshipWeighting         = "kinship"
+	EqualWeighting           = "equal"
+	CalendarDecayWeighting   = "calendar-decay"
+	DiscountImputedWeighting = "discount-imputed"
+)
+
+var WeightingNames = []string{GenerationalWeighting, KinshipWeighting, EqualWeighting, CalendarDecayWeighting, DiscountImputedWeighting}
+
+const DefaultWeightingHalfLifeYears = 50
+const DefaultImputedDateDiscount = 0.5
+
+// PathsToSubject returns the ancestor's paths to the subject, treating an ancestor with none
+// recorded as having a single path at GenerationsRemoved.
+func (ancestor AncestorDeath) PathsToSubject() AncestorPaths {
+	if len(ancestor.Paths) == 0 {
+		return AncestorPaths{ancestor.GenerationsRemoved: 1}
+	}
+	return ancestor.Paths
+}
+
+func findHighestGeneration(ancestors []AncestorDeath) int {
+	highestGeneration := 0
+	for _, ancestor := range ancestors {
+		for generation := range ancestor.PathsToSubject() {
+			if generation > highestGeneration {
+				highestGeneration = generation
+			}
+		}
+	}
+	return highestGeneration
+}
+
+// GenerationalWeighter credits an ancestor once per path to the subject, with each path weighted
+// twice as heavily as one a generation further back.
+type GenerationalWeighter struct{}
+
+func (GenerationalWeighter) Name() string {
+	return GenerationalWeighting
+}
+
+func (GenerationalWeighter) Weights(ancestors []AncestorDeath) []float64 {
+	highestGeneration := findHighestGeneration(ancestors)
+	weights := make([]float64, len(ancestors))
+	for i, ancestor := range ancestors {
+		for generation, count := range ancestor.PathsToSubject() {
+			weights[i] += float64(count) * math.Pow(2, float64(highestGeneration-generation))
+		}
+	}
+	return weights
+}
+
+// KinshipWeighter weights an ancestor by twice their coefficient of kinship with the subject: their
+// expected share of the subject's genes. Unlike the generational weights this counts the ancestor's
+// own inbreeding and their kinship with the subject's other ancestors, so an ancestor whose
+// descendants married each other counts for more than the sum of their paths. Ancestors without a
+// coefficient, e.g. built by hand, fall back to the sum of 0.5^generations over every path, which is
+// what the coefficient comes to in a pedigree without collapse.
+type KinshipWeighter struct{}
+
+func (KinshipWeighter) Name() string {
+	return KinshipWeighting
+}
+
+func (KinshipWeighter) Weights(ancestors []AncestorDeath) []float64 {
+	weights := make([]float64, len(ancestors))
+	for i, ancestor := range ancestors {
+		if ancestor.KinshipCoefficient > 0 {
+			weights[i] = 2 * ancestor.KinshipCoefficient
+			continue
+		}
+		for generation, count := range ancestor.PathsToSubject() {
+			weights[i] += float64(count) * math.Pow(0.5, float64(generation))
+		}
+	}
+	return weights
+}
+
+// EqualWeighter gives every ancestor the same weight, however far back they are.
+type EqualWeighter struct{}
+
+func (EqualWeighter) Name() string {
+	return EqualWeighting
+}
+
+func (EqualWeighter) Weights(ancestors []AncestorDeath) []float64 {
+	weights := make([]float64, len(ancestors))
+	for i := range ancestors {
+		weights[i] = 1
+	}
+	return weights
+}
+
+// CalendarDecayWeighter halves an ancestor's weight for every HalfLifeYears between their birth
+// and the subject's, so ancestors who lived in similar conditions to the subject count for more.
+type CalendarDecayWeighter struct {
+	SubjectBirthYear int
+	HalfLifeYears    float64
+}
+
+func (CalendarDecayWeighter) Name() string {
+	return CalendarDecayWeighting
+}
+
+func (c CalendarDecayWeighter) Weights(ancestors []AncestorDeath) []float64 {
+	weights := make([]float64, len(ancestors))
+	for i, ancestor := range ancestors {
+		distance := math.Abs(float64(c.SubjectBirthYear - ancestor.BirthYear))
+		weights[i] = math.Pow(0.5, distance/c.HalfLifeYears)
+	}
+	return weights
+}
+
+// DiscountImputedWeighter scales the weights from another weighter down by Discount for each of
+// an ancestor's birth and death dates that was imputed from a christening, burial or similar.
+type DiscountImputedWeighter struct {
+	Base     Weighter
+	Discount float64
+}
+
+func (DiscountImputedWeighter) Name() string {
+	return DiscountImputedWeighting
+}
+
+func (d DiscountImputedWeighter) Weights(ancestors []AncestorDeath) []float64 {
+	weights := d.Base.Weights(ancestors)
+	for i, ancestor := range ancestors {
+		if IsImputed(ancestor.BirthSource, "BIRT") {
+			weights[i] *= d.Discount
+		}
+		if IsImputed(ancestor.DeathSource, "DEAT") {
+			weights[i] *= d.Discount
+		}
+	}
+	return weights
+}
+
+func checkWeighterName(name string) error {
+	for _, weighterName := range WeightingNames {
+		if name == weighterName {
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown weighting '%s', expected one of: %s", name, strings.Join(WeightingNames, ", "))
+}
+
+// NewWeighter builds the named weighting strategy for the given subject.
+func NewWeighter(name string, subject *gedcom.IndividualRecord, options Options) (Weighter, error) {
+	switch name {
+	case GenerationalWeighting, "":
+		return GenerationalWeighter{}, nil
+	case KinshipWeighting:
+		return KinshipWeighter{}, nil
+	case EqualWeighting:
+		return EqualWeighter{}, nil
+	case CalendarDecayWeighting:
+		if options.WeightingHalfLifeYears <= 0 {
+			return nil, fmt.Errorf("weighting half-life must be a positive number of years")
+		}
+		subjectBirthYear, ok := BirthYear(subject)
+		if !ok {
+			return nil, fmt.Errorf("%s weighting needs a birth date for %s", name, describeIndividual(subject))
+		}
+		return CalendarDecayWeighter{SubjectBirthYear: subjectBirthYear, HalfLifeYears: options.WeightingHalfLifeYears}, nil
+	case DiscountImputedWeighting:
+		if options.ImputedDateDiscount < 0 || options.ImputedDateDiscount > 1 {
+			return nil, fmt.Errorf("imputed date discount must be between 0 and 1")
+		}
+		return DiscountImputedWeighter{Base: GenerationalWeighter{}, Discount: options.ImputedDateDiscount}, nil
+	}
+	return nil, checkWeighterName(name)
+}
+
+// NewWeighters builds every weighting strategy that can be used for the subject, for comparing
+// the results they give.
+func NewWeighters(subject *gedcom.IndividualRecord, options Options) []Weighter {
+	var weighters []Weighter
+	for _, name := range WeightingNames {
+		weighter, err := NewWeighter(name, subject, options)
+		if err != nil {
+			continue
+		}
+		weighters = append(weighters, weighter)
+	}
+	return weighters
+}
@@ -0,0 +1,72 @@
+package longevity
+
+import (
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/iand/gedcom"
+)
+
+func TestWeighters(t *testing.T) {
+	ancestors := []AncestorDeath{
+		{GenerationsRemoved: 1, BirthYear: 1950, BirthSource: "BIRT", DeathSource: "DEAT"},
+		{GenerationsRemoved: 2, BirthYear: 1900, BirthSource: "CHR", DeathSource: "DEAT"},
+		{GenerationsRemoved: 3, Paths: AncestorPaths{3: 1, 4: 1}, BirthYear: 1850, BirthSource: "BAPM", DeathSource: "BURI"},
+	}
+
+	tests := []struct {
+		weighter Weighter
+		want     []float64
+	}{
+		{weighter: GenerationalWeighter{}, want: []float64{8, 4, 3}},
+		{weighter: KinshipWeighter{}, want: []float64{0.5, 0.25, 0.1875}},
+		{weighter: EqualWeighter{}, want: []float64{1, 1, 1}},
+		{weighter: CalendarDecayWeighter{SubjectBirthYear: 2000, HalfLifeYears: 50}, want: []float64{0.5, 0.25, 0.125}},
+		{weighter: DiscountImputedWeighter{Base: GenerationalWeighter{}, Discount: 0.5}, want: []float64{8, 2, 0.75}},
+	}
+
+	for _, test := range tests {
+		got := test.weighter.Weights(ancestors)
+		if len(got) != len(test.want) {
+			t.Fatalf("%s: got %d weights, want %d", test.weighter.Name(), len(got), len(test.want))
+		}
+		for i := range got {
+			if math.Abs(got[i]-test.want[i]) > 1e-9 {
+				t.Errorf("%s: got %v, want %v", test.weighter.Name(), got, test.want)
+				break
+			}
+		}
+	}
+}
+
+func TestNewWeighter(t *testing.T) {
+	subject := &gedcom.IndividualRecord{Event: []*gedcom.EventRecord{{Tag: "BIRT", Date: "1980"}}}
+	options := Options{WeightingHalfLifeYears: 25, ImputedDateDiscount: 0.75}
+
+	for _, name := range WeightingNames {
+		weighter, err := NewWeighter(name, subject, options)
+		if err != nil {
+			t.Fatalf("unexpected error for %q: %s", name, err)
+		}
+		if weighter.Name() != name {
+			t.Errorf("expected weighter named %q, got %q", name, weighter.Name())
+		}
+	}
+
+	weighter, _ := NewWeighter(CalendarDecayWeighting, subject, options)
+	want := CalendarDecayWeighter{SubjectBirthYear: 1980, HalfLifeYears: 25}
+	if !reflect.DeepEqual(weighter, want) {
+		t.Errorf("got %+v, want %+v", weighter, want)
+	}
+
+	if _, err := NewWeighter("tallest-first", subject, options); err == nil {
+		t.Error("expected an error for an unknown weighting")
+	}
+	if _, err := NewWeighter(CalendarDecayWeighting, &gedcom.IndividualRecord{}, options); err == nil {
+		t.Error("expected an error for calendar-decay weighting without a subject birth date")
+	}
+	if weighters := NewWeighters(&gedcom.IndividualRecord{}, options); len(weighters) != len(WeightingNames)-1 {
+		t.Errorf("expected every weighter but calendar-decay to be available without a subject birth date, got %d", len(weighters))
+	}
+}
@@ -0,0 +1,131 @@
+package longevity
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// Year policies choose what happens when there are no statistics for the year an ancestor died.
+const (
+	ExactYearPolicy       = "exact"
+	ClampYearPolicy       = "clamp"
+	InterpolateYearPolicy = "interpolate"
+	ExternalYearPolicy    = "external"
+)
+
+var YearPolicies = []string{ExactYearPolicy, ClampYearPolicy, InterpolateYearPolicy, ExternalYearPolicy}
+
+func checkYearPolicy(policy string) error {
+	for _, known := range YearPolicies {
+		if policy == known {
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown year policy '%s', expected one of %v", policy, YearPolicies)
+}
+
+func statYear(stat DeathStat) int {
+	year, _ := strconv.Atoi(stat.Year)
+	return year
+}
+
+func exactDeathStat(stats []DeathStat, year int) (DeathStat, bool) {
+	for _, stat := range stats {
+		if statYear(stat) == year {
+			return stat, true
+		}
+	}
+	return DeathStat{}, false
+}
+
+// surroundingDeathStats returns the stats for the nearest years before and after the given year,
+// reporting which of the two were found.
+func surroundingDeathStats(stats []DeathStat, year int) (DeathStat, bool, DeathStat, bool) {
+	var before, after DeathStat
+	hasBefore, hasAfter := false, false
+	for _, stat := range stats {
+		statsYear := statYear(stat)
+		if statsYear < year && (!hasBefore || statsYear > statYear(before)) {
+			before, hasBefore = stat, true
+		}
+		if statsYear > year && (!hasAfter || statsYear < statYear(after)) {
+			after, hasAfter = stat, true
+		}
+	}
+	return before, hasBefore, after, hasAfter
+}
+
+// nearestDeathStat returns the stats for the closest year covered, preferring the earlier year when
+// two are equally close.
+func nearestDeathStat(stats []DeathStat, year int) (DeathStat, bool) {
+	before, hasBefore, after, hasAfter := surroundingDeathStats(stats, year)
+	switch {
+	case hasBefore && hasAfter:
+		if statYear(after)-year < year-statYear(before) {
+			return after, true
+		}
+		return before, true
+	case hasBefore:
+		return before, true
+	case hasAfter:
+		return after, true
+	}
+	return DeathStat{}, false
+}
+
+// interpolatedDeathStat estimates the stats for a year in a gap in the table by linear
+// interpolation between the years either side.
+func interpolatedDeathStat(stats []DeathStat, year int) (DeathStat, bool) {
+	before, hasBefore, after, hasAfter := surroundingDeathStats(stats, year)
+	if !hasBefore || !hasAfter {
+		return DeathStat{}, false
+	}
+	fraction := float64(year-statYear(before)) / float64(statYear(after)-statYear(before))
+	interpolate := func(from float64, to float64) float64 {
+		return from + fraction*(to-from)
+	}
+
+	stat := DeathStat{
+		Year:             strconv.Itoa(year),
+		LifeExpectancy:   interpolate(before.LifeExpectancy, after.LifeExpectancy),
+		MedianAgeAtDeath: interpolate(before.MedianAgeAtDeath, after.MedianAgeAtDeath),
+		ModalAgeAtDeath:  interpolate(before.ModalAgeAtDeath, after.ModalAgeAtDeath),
+	}
+	stat.LifeExpectancyDays = int(stat.LifeExpectancy * 365)
+	stat.MedianAgeAtDeathDays = int(stat.MedianAgeAtDeath * 365)
+	stat.ModalAgeAtDeathDays = int(stat.ModalAgeAtDeath * 365)
+	return stat, true
+}
+
+// findDeathStat returns the stats for the year of death along with how they were found. Years with
+// no stats of their own are handled according to the policy: excluded (exact), given the stats for
+// the nearest year covered (clamp), interpolated between the years either side of a gap and
+// otherwise clamped (interpolate), or looked up in the external dataset, exactly or by
+// interpolation (external).
+func findDeathStat(stats []DeathStat, external []DeathStat, year int, policy string) (DeathStat, string, bool) {
+	if stat, ok := exactDeathStat(stats, year); ok {
+		return stat, ExactYearPolicy, true
+	}
+
+	switch policy {
+	case ClampYearPolicy:
+		if stat, ok := nearestDeathStat(stats, year); ok {
+			return stat, ClampYearPolicy, true
+		}
+	case InterpolateYearPolicy:
+		if stat, ok := interpolatedDeathStat(stats, year); ok {
+			return stat, InterpolateYearPolicy, true
+		}
+		if stat, ok := nearestDeathStat(stats, year); ok {
+			return stat, ClampYearPolicy, true
+		}
+	case ExternalYearPolicy:
+		if stat, ok := exactDeathStat(external, year); ok {
+			return stat, ExternalYearPolicy, true
+		}
+		if stat, ok := interpolatedDeathStat(external, year); ok {
+			return stat, ExternalYearPolicy, true
+		}
+	}
+	return DeathStat{}, "", false
+}
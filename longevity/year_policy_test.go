@@ -0,0 +1,87 @@
+package longevity
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/iand/gedcom"
+)
+
+func TestFindDeathStat(t *testing.T) {
+	stats := []DeathStat{
+		{Year: "1850", LifeExpectancy: 40, MedianAgeAtDeath: 50, ModalAgeAtDeath: 70},
+		{Year: "1841", LifeExpectancy: 38, MedianAgeAtDeath: 46, ModalAgeAtDeath: 72},
+		{Year: "1860", LifeExpectancy: 45, MedianAgeAtDeath: 55, ModalAgeAtDeath: 75},
+	}
+	external := []DeathStat{
+		{Year: "1700", LifeExpectancy: 30, MedianAgeAtDeath: 32, ModalAgeAtDeath: 68},
+		{Year: "1800", LifeExpectancy: 35, MedianAgeAtDeath: 40, ModalAgeAtDeath: 70},
+	}
+	interpolated := DeathStat{
+		Year:                 "1855",
+		LifeExpectancy:       42.5,
+		LifeExpectancyDays:   15512,
+		MedianAgeAtDeath:     52.5,
+		MedianAgeAtDeathDays: 19162,
+		ModalAgeAtDeath:      72.5,
+		ModalAgeAtDeathDays:  26462,
+	}
+
+	tests := []struct {
+		name      string
+		year      int
+		policy    string
+		want      DeathStat
+		wantMatch string
+		wantOk    bool
+	}{
+		{name: "exact", year: 1850, policy: ExactYearPolicy, want: stats[0], wantMatch: ExactYearPolicy, wantOk: true},
+		{name: "exact year with another policy", year: 1850, policy: ClampYearPolicy, want: stats[0], wantMatch: ExactYearPolicy, wantOk: true},
+		{name: "gap with exact", year: 1855, policy: ExactYearPolicy, wantOk: false},
+		{name: "clamp before", year: 1700, policy: ClampYearPolicy, want: stats[1], wantMatch: ClampYearPolicy, wantOk: true},
+		{name: "clamp after", year: 1920, policy: ClampYearPolicy, want: stats[2], wantMatch: ClampYearPolicy, wantOk: true},
+		{name: "clamp gap", year: 1857, policy: ClampYearPolicy, want: stats[2], wantMatch: ClampYearPolicy, wantOk: true},
+		{name: "interpolate gap", year: 1855, policy: InterpolateYearPolicy, want: interpolated, wantMatch: InterpolateYearPolicy, wantOk: true},
+		{name: "interpolate outside range", year: 1700, policy: InterpolateYearPolicy, want: stats[1], wantMatch: ClampYearPolicy, wantOk: true},
+		{name: "external exact", year: 1700, policy: ExternalYearPolicy, want: external[0], wantMatch: ExternalYearPolicy, wantOk: true},
+		{name: "external outside its range", year: 1650, policy: ExternalYearPolicy, wantOk: false},
+	}
+
+	for _, test := range tests {
+		got, match, ok := findDeathStat(stats, external, test.year, test.policy)
+		if ok != test.wantOk || match != test.wantMatch || !reflect.DeepEqual(got, test.want) {
+			t.Errorf("test %q: got %+v, %q, %v, want %+v, %q, %v", test.name, got, match, ok, test.want, test.wantMatch, test.wantOk)
+		}
+	}
+
+	got, match, ok := findDeathStat(stats, external, 1750, ExternalYearPolicy)
+	if !ok || match != ExternalYearPolicy || got.LifeExpectancy != 32.5 {
+		t.Errorf("expected external stats interpolated to a life expectancy of 32.5, got %+v, %q, %v", got, match, ok)
+	}
+}
+
+func TestGetDeathStatsForAncestorsYearPolicy(t *testing.T) {
+	registry := ReferenceRegistry{DefaultCountry: {
+		FemaleDeathStats: []DeathStat{{Year: "1841", MedianAgeAtDeathDays: 50 * 365}},
+	}}
+	ancestor := &gedcom.IndividualRecord{
+		Sex: "F",
+		Event: []*gedcom.EventRecord{
+			{Tag: "BIRT", Date: "1750"},
+			{Tag: "DEAT", Date: "1820"},
+		},
+	}
+	ancestors := map[*gedcom.IndividualRecord]AncestorPaths{ancestor: {1: 1}}
+
+	if got, _ := getDeathStatsForAncestors(nil, ancestors, registry, Options{YearPolicy: ExactYearPolicy}); len(got) != 0 {
+		t.Errorf("expected the ancestor to be left out with the exact policy, got %d ancestors", len(got))
+	}
+
+	got, _ := getDeathStatsForAncestors(nil, ancestors, registry, Options{YearPolicy: ClampYearPolicy})
+	if len(got) != 1 {
+		t.Fatalf("expected 1 ancestor, got %d", len(got))
+	}
+	if got[0].StatsYear != 1841 || got[0].StatsMatch != ClampYearPolicy {
+		t.Errorf("expected stats clamped to 1841, got %d (%s)", got[0].StatsYear, got[0].StatsMatch)
+	}
+}
@@ -1,477 +1,458 @@
 package main
 
 import (
-	"bytes"
 	"encoding/csv"
 	"flag"
 	"fmt"
+	"io"
 	"io/ioutil"
-	"math"
 	"os"
-	"regexp"
 	"sort"
 	"strconv"
 	"strings"
 	"text/tabwriter"
-	"time"
 
-	"github.com/araddon/dateparse"
 	"github.com/iand/gedcom"
+	"predict-death/longevity"
 )
 
-type DeathStat struct {
-	Year                 string
-	LifeExpectancy       float64
-	LifeExpectancyDays   int
-	MedianAgeAtDeath     float64
-	MedianAgeAtDeathDays int
-	ModalAgeAtDeath      float64
-	ModalAgeAtDeathDays  int
-}
-
-type AncestorDeath struct {
-	Year                     int
-	GenerationsRemoved       int
-	Gender                   string
-	AgeAtDeathDaysTotal      int
-	AgeAtDeathYears          int
-	AgeAtDeathDays           int
-	LifeExpectancyDiffDays   int
-	MedianAgeAtDeathDiffDays int
-	ModalAgeAtDeathDiffDays  int
-	ModalDeathAgeDays        int
-	MedianDeathAgeDays       int
-	LifeExpectancyDays       int
-}
-
-var months = []string{"January", "February", "March", "April", "May", "June", "July", "August", "September", "October", "November", "December"}
-
-func calculateWeightedAverages(ancestors []AncestorDeath, gender string) (int, int, int) {
-	var totalLifeExpectancyDiffDays, totalMedianAgeAtDeathDiffDays, totalModalAgeAtDeathDiffDays int
-	var weightSum int
-
-	highestGeneration := 0
-	for _, ancestor := range ancestors {
-		if ancestor.GenerationsRemoved > highestGeneration {
-			highestGeneration = ancestor.GenerationsRemoved
-		}
+func formatYearsAndDays(daysTotal int) string {
+	sign := ""
+	if daysTotal < 0 {
+		sign = "-"
+		daysTotal = -daysTotal
 	}
-
-	for _, ancestor := range ancestors {
-		if ancestor.Gender == gender || gender == "" {
-			weight := int(math.Pow(2, float64(highestGeneration-ancestor.GenerationsRemoved)))
-			totalLifeExpectancyDiffDays += ancestor.LifeExpectancyDiffDays * weight
-			totalMedianAgeAtDeathDiffDays += ancestor.MedianAgeAtDeathDiffDays * weight
-			totalModalAgeAtDeathDiffDays += ancestor.ModalAgeAtDeathDiffDays * weight
-			weightSum += weight
-		}
-	}
-
-	return totalLifeExpectancyDiffDays / weightSum, totalMedianAgeAtDeathDiffDays / weightSum, totalModalAgeAtDeathDiffDays / weightSum
+	years, days := longevity.DaysToYearsAndDays(daysTotal)
+	return fmt.Sprintf("%s%d years %d days", sign, years, days)
 }
 
-func checkValidYear(dateStr string) error {
-	currentYear := time.Now().Year()
-	re := regexp.MustCompile(`\b\d{4}\b`)
-	yearStr := re.FindString(dateStr)
-	if yearStr == "" {
-		return fmt.Errorf("date '%s' does not contain a four-digit year", dateStr)
-	}
-	year, err := strconv.Atoi(yearStr)
-	if err != nil {
-		return fmt.Errorf("error converting year from date '%s' to an int", dateStr)
+func formatWithRange(daysTotal int, lowerDays int, upperDays int) string {
+	if lowerDays == 0 && upperDays == 0 {
+		return formatYearsAndDays(daysTotal)
 	}
-	if year < 1500 || year > currentYear {
-		return fmt.Errorf("year in date '%s' is outside valid range", dateStr)
-	}
-	return nil
+	return fmt.Sprintf("%s (%s to %s)", formatYearsAndDays(daysTotal), formatYearsAndDays(daysTotal+lowerDays), formatYearsAndDays(daysTotal+upperDays))
 }
 
-func yearRangeMidpoint(dateStr string) (time.Time, error) {
-	dateStr = strings.ReplaceAll(dateStr, " ", "")
-	parts := strings.Split(dateStr, "-")
-	if len(parts) != 2 {
-		return time.Time{}, fmt.Errorf("invalid date range: %s", dateStr)
-	}
-	startYear, err := strconv.Atoi(parts[0])
-	if err != nil {
-		return time.Time{}, fmt.Errorf("invalid start year in date range: %s", dateStr)
-	}
-	endYear, err := strconv.Atoi(parts[1])
-	if err != nil {
-		return time.Time{}, fmt.Errorf("invalid end year in date range: %s", dateStr)
+func formatDiff(daysTotal int) string {
+	if daysTotal >= 0 {
+		return "+" + formatYearsAndDays(daysTotal)
 	}
-	start := time.Date(startYear, 1, 1, 0, 0, 0, 0, time.UTC)
-	end := time.Date(endYear, 1, 1, 0, 0, 0, 0, time.UTC)
-	midpoint := start.Add(end.Sub(start) / 2)
-	return midpoint, nil
+	return formatYearsAndDays(daysTotal)
 }
 
-func cleanDate(dateStr string) string {
-	dateSuffixRegex := regexp.MustCompile(`([1-9])(st|nd|th|rd)`)
-	dateStr = dateSuffixRegex.ReplaceAllString(dateStr, "$1")
-	dateStr = strings.ReplaceAll(dateStr, "  ", " ")
-
-	for _, month := range months {
-		monthAbbr := month[:3]
-
-		regex := regexp.MustCompile(fmt.Sprintf(`(?i)\b%s\b`, monthAbbr))
-		dateStr = regex.ReplaceAllString(dateStr, month)
-
-		if monthAbbr == "Sep" {
-			monthAbbr = month[:4]
-			regex := regexp.MustCompile(fmt.Sprintf(`(?i)\b%s\b`, monthAbbr))
-			dateStr = regex.ReplaceAllString(dateStr, month)
-		}
+// formatLifeExpectancy shows the ancestor's expected age at death along with the age it was
+// conditioned on.
+func formatLifeExpectancy(ancestor longevity.AncestorDeath) string {
+	if ancestor.ConditioningAge == 0 {
+		return formatYearsAndDays(ancestor.LifeExpectancyDays) + " (from birth)"
 	}
-	return dateStr
+	return fmt.Sprintf("%s (from age %d)", formatYearsAndDays(ancestor.LifeExpectancyDays), ancestor.ConditioningAge)
 }
 
-func parseDate(dateStr string) (time.Time, error) {
-	err := checkValidYear(dateStr)
-	if err != nil {
-		return time.Time{}, fmt.Errorf("no valid year found in date: %s", err)
-	}
-
-	dateStr = cleanDate(dateStr)
-
-	foundMonth := false
-	for _, month := range months {
-		if strings.HasPrefix(dateStr, month) {
-			foundMonth = true
-			break
-		}
-	}
+// formatStatsYear shows the year of the stats an ancestor was compared with, and how they were found
+// if not by an exact match on the year of death.
+func formatStatsYear(ancestor longevity.AncestorDeath) string {
+	switch ancestor.StatsMatch {
+	case longevity.ClampYearPolicy:
+		return fmt.Sprintf("%d (clamped)", ancestor.StatsYear)
+	case longevity.InterpolateYearPolicy:
+		return fmt.Sprintf("%d (interpolated)", ancestor.StatsYear)
+	case longevity.ExternalYearPolicy:
+		return fmt.Sprintf("%d (external)", ancestor.StatsYear)
+	}
+	return strconv.Itoa(ancestor.StatsYear)
+}
 
-	if !foundMonth {
-		for i, r := range dateStr {
-			if r >= '0' && r <= '9' {
-				dateStr = dateStr[i:]
-				break
-			}
-		}
+func formatGender(ancestor longevity.AncestorDeath) string {
+	if ancestor.SexInferred {
+		return ancestor.Gender + " (inferred)"
 	}
+	return ancestor.Gender
+}
 
-	yearRangeRegex := regexp.MustCompile(`(\d{4})\s*-\s*(\d{4})`)
-	if yearRangeRegex.MatchString(dateStr) {
-		parsedDate, err := yearRangeMidpoint(dateStr)
-		if err != nil {
-			return time.Time{}, fmt.Errorf("failed to find midpoint date in year range '%s': %s", dateStr, err)
-		}
-		return parsedDate, nil
+func formatPercentile(percentile float64, ok bool) string {
+	if !ok {
+		return "n/a"
 	}
+	return fmt.Sprintf("%.1f%%", percentile)
+}
 
-	for _, month := range months {
-		if strings.Contains(dateStr, month) && !regexp.MustCompile(`\b\d{1,2} `+month).MatchString(dateStr) {
-			dateStr = regexp.MustCompile(month).ReplaceAllString(dateStr, "1 "+month)
-		}
+func formatSources(ancestor longevity.AncestorDeath) string {
+	birthSource, deathSource := ancestor.BirthSource, ancestor.DeathSource
+	if longevity.IsImputed(birthSource, "BIRT") {
+		birthSource += "*"
 	}
-
-	parsedDate, err := dateparse.ParseLocal(dateStr)
-	if err != nil {
-		return time.Time{}, fmt.Errorf("failed to parse date %s: %s", dateStr, err)
+	if longevity.IsImputed(deathSource, "DEAT") {
+		deathSource += "*"
 	}
-
-	return parsedDate, nil
+	return birthSource + "/" + deathSource
 }
 
-func getDeathStatsForAncestors(ancestors map[*gedcom.IndividualRecord]int, maleDeathStats []DeathStat, femaleDeathStats []DeathStat) []AncestorDeath {
-	var ancestorDeaths []AncestorDeath
-	for individual, generation := range ancestors {
-		var birthDate, deathDate time.Time
-		hasBirth, hasDeath := true, true
-		for _, event := range individual.Event {
-			switch event.Tag {
-			case "DEAT":
-				var err error
-				deathDate, err = parseDate(event.Date)
-				if err != nil {
-					hasDeath = false
-				}
-			case "BIRT":
-				var err error
-				birthDate, err = parseDate(event.Date)
-				if err != nil {
-					hasBirth = false
-				}
-			default:
+// summaryTable returns the rows of the summary of weighted averages, starting with the heading row.
+// Unknown covers ancestors compared with the statistics for both sexes, and is n/a when there
+// aren't any.
+func summaryTable(summary longevity.Summary, stats StatSelection) [][]string {
+	groups := []longevity.SummaryGroup{summary.Male, summary.Female, summary.Unknown, summary.Overall}
+	unknown := 2 // the column that is n/a when no ancestor has an unknown sex
+
+	rows := [][]string{{"Stat", "Male", "Female", "Unknown", "Overall"}}
+	for _, stat := range stats.diffStats() {
+		row := []string{"Difference from " + statLabel(stat)}
+		for i, g := range groups {
+			if i == unknown && g.Ancestors == 0 {
+				row = append(row, "n/a")
 				continue
 			}
+			row = append(row, formatWithRange(pickDiff(stat, g.LifeExpectancyDiffDays, g.MedianAgeAtDeathDiffDays, g.ModalAgeAtDeathDiffDays), g.DiffLowerDays, g.DiffUpperDays))
 		}
-		if !hasBirth || !hasDeath || birthDate == (time.Time{}) || deathDate == (time.Time{}) {
-			continue
-		}
-
-		ageAtDeathDaysTotal := int(deathDate.Unix()-birthDate.Unix()) / 60 / 60 / 24
-
-		var deathStats []DeathStat
-		if strings.ToLower(individual.Sex) == "m" {
-			deathStats = maleDeathStats
-		} else {
-			deathStats = femaleDeathStats
-		}
-
-		var deathStat DeathStat
-		statsForYear := false
-		for _, ds := range deathStats {
-			if ds.Year == strconv.Itoa(deathDate.Year()) {
-				deathStat = ds
-				statsForYear = true
-				break
-			}
-		}
-
-		if !statsForYear {
-			continue
-		}
-
-		ageAtDeathYears, ageAtDeathDays := daysToYearsAndDays(ageAtDeathDaysTotal)
-
-		ancestorDeaths = append(ancestorDeaths, AncestorDeath{
-			Year:                     deathDate.Year(),
-			GenerationsRemoved:       generation,
-			Gender:                   strings.ToLower(individual.Sex),
-			AgeAtDeathYears:          ageAtDeathYears,
-			AgeAtDeathDays:           ageAtDeathDays,
-			AgeAtDeathDaysTotal:      ageAtDeathDaysTotal,
-			LifeExpectancyDiffDays:   ageAtDeathDaysTotal - deathStat.LifeExpectancyDays,
-			MedianAgeAtDeathDiffDays: ageAtDeathDaysTotal - deathStat.MedianAgeAtDeathDays,
-			ModalAgeAtDeathDiffDays:  ageAtDeathDaysTotal - deathStat.ModalAgeAtDeathDays,
-			ModalDeathAgeDays:        deathStat.ModalAgeAtDeathDays,
-			MedianDeathAgeDays:       deathStat.MedianAgeAtDeathDays,
-			LifeExpectancyDays:       deathStat.LifeExpectancyDays,
-		})
-	}
-	return ancestorDeaths
-}
-
-func parseDeathStats(filepath string) ([]DeathStat, error) {
-	file, err := os.Open(filepath)
-	if err != nil {
-		fmt.Println("Error opening file:", err)
-		return nil, err
-	}
-	defer file.Close()
-
-	reader := csv.NewReader(file)
-
-	records, err := reader.ReadAll()
-	if err != nil {
-		fmt.Println("Error reading records:", err)
-		return nil, err
-	}
-
-	var DeathStats []DeathStat
-
-	for i, record := range records {
-		if i == 0 {
-
-			continue
-		}
-
-		var s DeathStat
-		s.Year = record[0]
-		lifeExpectancy, err := strconv.ParseFloat(record[1], 64)
-		s.LifeExpectancy = lifeExpectancy
-		s.LifeExpectancyDays = int(lifeExpectancy * 365)
-		if err != nil {
-			fmt.Println("Error parsing life expectancy:", err)
-			return nil, err
-		}
-
-		medianAgeAtDeath, err := strconv.ParseFloat(record[2], 64)
-		s.MedianAgeAtDeath = medianAgeAtDeath
-		s.MedianAgeAtDeathDays = int(medianAgeAtDeath * 365)
-		if err != nil {
-			fmt.Println("Error parsing median age at death:", err)
-			return nil, err
-		}
-
-		modalAgeAtDeath, err := strconv.ParseFloat(record[3], 64)
-		s.ModalAgeAtDeath = modalAgeAtDeath
-		s.ModalAgeAtDeathDays = int(modalAgeAtDeath * 365)
-		if err != nil {
-			fmt.Println("Error parsing modal age at death:", err)
-			return nil, err
-		}
-
-		DeathStats = append(DeathStats, s)
+		rows = append(rows, row)
 	}
-	return DeathStats, nil
-}
-
-func earliestYear(stats []DeathStat) (int, error) {
-	var earliest int
-	for _, s := range stats {
-		year, err := strconv.Atoi(s.Year)
-		if err != nil {
-			fmt.Println("Error parsing year:", err)
-			return 0, err
-		}
-		if earliest == 0 || year < earliest {
-			earliest = year
+	if stats.includes(percentileStat) {
+		row := []string{"Mean Survival Percentile"}
+		for _, g := range groups {
+			row = append(row, formatPercentile(g.SurvivalPercentile, g.HasSurvivalPercentile))
 		}
+		rows = append(rows, row)
 	}
-	return earliest, nil
+	return rows
 }
 
-func getParents(individual *gedcom.IndividualRecord) ([]*gedcom.IndividualRecord, error) {
-	var parents []*gedcom.IndividualRecord
-
-	for _, parentRecord := range individual.Parents {
-		if parentRecord.Family.Husband != nil {
-			parents = append(parents, parentRecord.Family.Husband)
-		}
-
-		if parentRecord.Family.Wife != nil {
-			parents = append(parents, parentRecord.Family.Wife)
-		}
-	}
-	return parents, nil
-}
-
-func daysToYearsAndDays(daysTotal int) (int, int) {
-	years := daysTotal / 365
-	days := int(math.Abs(float64(daysTotal % 365)))
-	return years, days
-}
-
-func printResults(ancestors []AncestorDeath, subject *gedcom.IndividualRecord) {
-	_, maleTotalMedianAgeAtDeathDiffDays, maleTotalModalAgeAtDeathDiffDays := calculateWeightedAverages(ancestors, "m")
-	_, femaleTotalMedianAgeAtDeathDiffDays, femaleTotalModalAgeAtDeathDiffDays := calculateWeightedAverages(ancestors, "f")
-	_, overallTotalMedianAgeAtDeathDiffDays, overallTotalModalAgeAtDeathDiffDays := calculateWeightedAverages(ancestors, "")
-
-	maleMedianAgeAtDeathYears, maleMedianAgeAtDeathDays := daysToYearsAndDays(maleTotalMedianAgeAtDeathDiffDays)
-	maleModalAgeAtDeathYears, maleModalAgeAtDeathDays := daysToYearsAndDays(maleTotalModalAgeAtDeathDiffDays)
-
-	femaleMedianAgeAtDeathYears, femaleMedianAgeAtDeathDays := daysToYearsAndDays(femaleTotalMedianAgeAtDeathDiffDays)
-	femaleModalAgeAtDeathYears, femaleModalAgeAtDeathDays := daysToYearsAndDays(femaleTotalModalAgeAtDeathDiffDays)
-
-	overallMedianAgeAtDeathYears, overallMedianAgeAtDeathDays := daysToYearsAndDays(overallTotalMedianAgeAtDeathDiffDays)
-	overallModalAgeAtDeathYears, overallModalAgeAtDeathDays := daysToYearsAndDays(overallTotalModalAgeAtDeathDiffDays)
-
-	subjectName := gedcom.SplitPersonalName(subject.Name[0].Name).Full
+func printResults(result longevity.Result, comparisonWeighters []longevity.Weighter, stats StatSelection) {
+	ancestors, weighter := result.Ancestors, result.Weighter
+	subjectName := longevity.IndividualName(result.Subject)
 
 	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
 	fmt.Fprintln(w, "===========================================================================================")
-	fmt.Fprintln(w, "Longevity statistics for the direct ancestors of "+subjectName)
+	fmt.Fprintln(w, "Longevity statistics for the direct ancestors of "+subjectName+" ("+weighter.Name()+" weighting)")
 	fmt.Fprintln(w, "===========================================================================================")
-	fmt.Fprintln(w, "Stat\tMale\tFemale\tOverall")
-	fmt.Fprintln(w, "Difference from Median Death Age\t"+strconv.Itoa(maleMedianAgeAtDeathYears)+" "+"years"+" "+strconv.Itoa(maleMedianAgeAtDeathDays)+" "+"days\t"+strconv.Itoa(femaleMedianAgeAtDeathYears)+" "+"years"+" "+strconv.Itoa(femaleMedianAgeAtDeathDays)+" "+"days\t"+strconv.Itoa(overallMedianAgeAtDeathYears)+" "+"years"+" "+strconv.Itoa(overallMedianAgeAtDeathDays)+" "+"days")
-	fmt.Fprintln(w, "Difference from Modal Age at Death\t"+strconv.Itoa(maleModalAgeAtDeathYears)+" "+"years"+" "+strconv.Itoa(maleModalAgeAtDeathDays)+" "+"days\t"+strconv.Itoa(femaleModalAgeAtDeathYears)+" "+"years"+" "+strconv.Itoa(femaleModalAgeAtDeathDays)+" "+"days\t"+strconv.Itoa(overallModalAgeAtDeathYears)+" "+"years"+" "+strconv.Itoa(overallModalAgeAtDeathDays)+" "+"days")
+	for _, row := range summaryTable(result.Summary, stats) {
+		fmt.Fprintln(w, strings.Join(row, "\t"))
+	}
+	w.Flush()
+	fmt.Fprintln(w, "===========================================================================================")
+	names := make([]string, len(comparisonWeighters))
+	byWeighter := map[string][]string{}
+	for i, comparisonWeighter := range comparisonWeighters {
+		lifeExpectancy, median, modal := longevity.CalculateWeightedAverages(ancestors, "", comparisonWeighter)
+		names[i] = comparisonWeighter.Name()
+		for _, stat := range stats.diffStats() {
+			byWeighter[stat] = append(byWeighter[stat], formatYearsAndDays(pickDiff(stat, lifeExpectancy, median, modal)))
+		}
+	}
+	fmt.Fprintln(w, "Overall stat by weighting\t"+strings.Join(names, "\t"))
+	for _, stat := range stats.diffStats() {
+		fmt.Fprintln(w, "Difference from "+statLabel(stat)+"\t"+strings.Join(byWeighter[stat], "\t"))
+	}
 	w.Flush()
 	fmt.Fprintln(w, "===========================================================================================")
 
+	// Sorted on a copy, as the other outputs are written from the same ancestors afterwards.
+	ancestors = append([]longevity.AncestorDeath(nil), ancestors...)
 	sort.SliceStable(ancestors, func(i, j int) bool {
 		return ancestors[i].Year > ancestors[j].Year
 	})
-	fmt.Fprintln(w, "Year\tGenerations removed from subject\tGender\tAge at death\tMedian Death Age Diff\tModal Death Age Diff\tModal Death Age\tMedian Death Age")
+	header := []string{"Year", "Generations removed from subject", "Gender", "Country", "Stats Year", "Age at death", "Age at death range"}
+	if stats.includes(lifeExpectancyStat) {
+		header = append(header, "Life Expectancy Diff", "Life Expectancy")
+	}
+	if stats.includes(medianStat) {
+		header = append(header, "Median Death Age Diff", "Median Death Age")
+	}
+	if stats.includes(modalStat) {
+		header = append(header, "Modal Death Age Diff", "Modal Death Age")
+	}
+	if stats.includes(percentileStat) {
+		header = append(header, "Survival Percentile")
+	}
+	header = append(header, "Dates from")
+	fmt.Fprintln(w, strings.Join(header, "\t"))
+	imputed := false
 	for _, ancestor := range ancestors {
-		ageAtDeathYears, ageAtDeathDays := daysToYearsAndDays(ancestor.AgeAtDeathDaysTotal)
-		medianDeathAgeDiffYears, medianDeathAgeDiffDays := daysToYearsAndDays(ancestor.MedianAgeAtDeathDiffDays)
-		modalDeathAgeDiffYears, modalDeathAgeDiffDays := daysToYearsAndDays(ancestor.ModalAgeAtDeathDiffDays)
-		modalDeathAgeYears, modalDeathAgeDays := daysToYearsAndDays(ancestor.ModalDeathAgeDays)
-		medianDeathAgeYears, medianDeathAgeDays := daysToYearsAndDays(ancestor.MedianDeathAgeDays)
-		fmt.Fprintf(w, "%d\t%d\t%s\t%d years %d days\t%+d years %d days\t%+d years %d days\t%d years %d days\t%d years %d days\n",
-			ancestor.Year, ancestor.GenerationsRemoved, ancestor.Gender, ageAtDeathYears, ageAtDeathDays,
-			medianDeathAgeDiffYears, medianDeathAgeDiffDays,
-			modalDeathAgeDiffYears, modalDeathAgeDiffDays,
-			modalDeathAgeYears, modalDeathAgeDays,
-			medianDeathAgeYears, medianDeathAgeDays,
-		)
+		ageAtDeathRange := "exact"
+		if ancestor.AgeAtDeathMinDays != ancestor.AgeAtDeathMaxDays {
+			ageAtDeathRange = formatYearsAndDays(ancestor.AgeAtDeathMinDays) + " to " + formatYearsAndDays(ancestor.AgeAtDeathMaxDays)
+		}
+		if longevity.IsImputed(ancestor.BirthSource, "BIRT") || longevity.IsImputed(ancestor.DeathSource, "DEAT") {
+			imputed = true
+		}
+		row := []string{
+			strconv.Itoa(ancestor.Year), formatGenerations(ancestor.PathsToSubject()), formatGender(ancestor), ancestor.Country, formatStatsYear(ancestor),
+			formatYearsAndDays(ancestor.AgeAtDeathDaysTotal), ageAtDeathRange,
+		}
+		if stats.includes(lifeExpectancyStat) {
+			row = append(row, formatDiff(ancestor.LifeExpectancyDiffDays), formatLifeExpectancy(ancestor))
+		}
+		if stats.includes(medianStat) {
+			row = append(row, formatDiff(ancestor.MedianAgeAtDeathDiffDays), formatYearsAndDays(ancestor.MedianDeathAgeDays))
+		}
+		if stats.includes(modalStat) {
+			row = append(row, formatDiff(ancestor.ModalAgeAtDeathDiffDays), formatYearsAndDays(ancestor.ModalDeathAgeDays))
+		}
+		if stats.includes(percentileStat) {
+			row = append(row, formatPercentile(ancestor.SurvivalPercentile, ancestor.HasSurvivalPercentile))
+		}
+		row = append(row, formatSources(ancestor))
+		fmt.Fprintln(w, strings.Join(row, "\t"))
 	}
 	w.Flush()
-}
-
-func getAncestors(individual *gedcom.IndividualRecord, ancestors map[*gedcom.IndividualRecord]int, generation int) (map[*gedcom.IndividualRecord]int, error) {
-	parents, err := getParents(individual)
-	if err != nil {
-		return nil, err
+	if imputed {
+		fmt.Println("* date imputed from a christening, baptism, burial or cremation")
 	}
+}
 
-	for _, parent := range parents {
-		ancestors[parent] = generation
+func formatGenerations(paths longevity.AncestorPaths) string {
+	var generations []int
+	for generation := range paths {
+		generations = append(generations, generation)
 	}
+	sort.Ints(generations)
 
-	for _, parent := range parents {
-		ancestors, err = getAncestors(parent, ancestors, generation+1)
-		if err != nil {
-			return nil, err
+	formatted := make([]string, len(generations))
+	for i, generation := range generations {
+		formatted[i] = strconv.Itoa(generation)
+		if paths[generation] > 1 {
+			formatted[i] += fmt.Sprintf(" (x%d)", paths[generation])
 		}
 	}
+	return strings.Join(formatted, ", ")
+}
 
-	return ancestors, nil
+func csvPercentile(percentile float64, ok bool) string {
+	if !ok {
+		return ""
+	}
+	return strconv.FormatFloat(percentile, 'f', 2, 64)
 }
 
-func writeCsv(ancestors []AncestorDeath, subject *gedcom.IndividualRecord, csvFileName string) {
+func writeCsv(ancestors []longevity.AncestorDeath, subject *gedcom.IndividualRecord, weighter longevity.Weighter, csvFileName string, stats StatSelection) error {
 	if !strings.HasSuffix(csvFileName, ".csv") {
 		csvFileName = csvFileName + ".csv"
 	}
-	file, _ := os.Create(csvFileName)
-	defer file.Close()
-	writer := csv.NewWriter(file)
-	defer writer.Flush()
+	file, err := os.Create(csvFileName)
+	if err != nil {
+		return err
+	}
+	if err := renderCsv(file, ancestors, subject, weighter, stats); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
 
-	subjectName := gedcom.SplitPersonalName(subject.Name[0].Name).Full
-	writer.Write([]string{"Year", fmt.Sprintf("Generations removed from %s", subjectName), "Gender", "Age at death (days)", "Median Death Age Diff (days)", "Modal Death Age Diff (days)", "Modal Death Age (days)", "Median Death Age (days)"})
+// renderCsv writes one row per ancestor compared with the statistics.
+func renderCsv(w io.Writer, ancestors []longevity.AncestorDeath, subject *gedcom.IndividualRecord, weighter longevity.Weighter, stats StatSelection) error {
+	writer := csv.NewWriter(w)
 
-	for _, ancestor := range ancestors {
-		ageAtDeath := ancestor.AgeAtDeathDaysTotal
-		medianDeathAgeDiff := ancestor.MedianAgeAtDeathDiffDays
-		modalDeathAgeDiff := ancestor.ModalAgeAtDeathDiffDays
-		modalDeathAge := ancestor.ModalDeathAgeDays
-		medianDeathAge := ancestor.MedianDeathAgeDays
+	subjectName := longevity.IndividualName(subject)
+	header := []string{"Year", fmt.Sprintf("Generations removed from %s", subjectName), "Paths", "Gender", "Sex inferred", "Country", "Stats year", "Stats match", "Age at death (days)", "Age at death min (days)", "Age at death max (days)"}
+	if stats.includes(lifeExpectancyStat) {
+		header = append(header, "Life Expectancy Diff (days)", "Life Expectancy (days)", "Life expectancy from age")
+	}
+	if stats.includes(medianStat) {
+		header = append(header, "Median Death Age Diff (days)", "Median Death Age (days)")
+	}
+	if stats.includes(modalStat) {
+		header = append(header, "Modal Death Age Diff (days)", "Modal Death Age (days)")
+	}
+	if stats.includes(percentileStat) {
+		header = append(header, "Survival percentile")
+	}
+	writer.Write(append(header, "Birth source", "Death source", "Weighting", "Weight"))
 
-		writer.Write([]string{
+	weights := weighter.Weights(ancestors)
+	for i, ancestor := range ancestors {
+		row := []string{
 			strconv.Itoa(ancestor.Year),
 			strconv.Itoa(ancestor.GenerationsRemoved),
+			strconv.Itoa(ancestor.PathsToSubject().Count()),
 			ancestor.Gender,
-			strconv.Itoa(ageAtDeath),
-			strconv.Itoa(medianDeathAgeDiff),
-			strconv.Itoa(modalDeathAgeDiff),
-			strconv.Itoa(modalDeathAge),
-			strconv.Itoa(medianDeathAge),
-		})
-	}
+			strconv.FormatBool(ancestor.SexInferred),
+			ancestor.Country,
+			strconv.Itoa(ancestor.StatsYear),
+			ancestor.StatsMatch,
+			strconv.Itoa(ancestor.AgeAtDeathDaysTotal),
+			strconv.Itoa(ancestor.AgeAtDeathMinDays),
+			strconv.Itoa(ancestor.AgeAtDeathMaxDays),
+		}
+		if stats.includes(lifeExpectancyStat) {
+			row = append(row, strconv.Itoa(ancestor.LifeExpectancyDiffDays), strconv.Itoa(ancestor.LifeExpectancyDays), strconv.Itoa(ancestor.ConditioningAge))
+		}
+		if stats.includes(medianStat) {
+			row = append(row, strconv.Itoa(ancestor.MedianAgeAtDeathDiffDays), strconv.Itoa(ancestor.MedianDeathAgeDays))
+		}
+		if stats.includes(modalStat) {
+			row = append(row, strconv.Itoa(ancestor.ModalAgeAtDeathDiffDays), strconv.Itoa(ancestor.ModalDeathAgeDays))
+		}
+		if stats.includes(percentileStat) {
+			row = append(row, csvPercentile(ancestor.SurvivalPercentile, ancestor.HasSurvivalPercentile))
+		}
+		writer.Write(append(row,
+			ancestor.BirthSource,
+			ancestor.DeathSource,
+			weighter.Name(),
+			strconv.FormatFloat(weights[i], 'g', -1, 64),
+		))
+	}
+	writer.Flush()
+	return writer.Error()
 }
 
 func main() {
-	var treeFile string
-	flag.StringVar(&treeFile, "tree-file", "", "path to GEDCOM tree file")
+	if len(os.Args) > 1 && os.Args[1] == "serve" {
+		runServe(os.Args[2:])
+		return
+	}
+	var options longevity.Options
+	var treeFile, csvFile, filterStr, birthFallbacksStr, deathFallbacksStr string
+	var reference referenceFlags
+	var exclusionsCsvFile, exclusionsJsonFile, htmlFile, fanChartFile string
+	var fanChartGenerations int
+	var outputFormat, statsStr string
+	var batch bool
+	flag.StringVar(&treeFile, "tree-file", "", "path to the family tree: a GEDCOM 5.5.1 or 7 file, GEDZIP archive or Gramps XML database")
+	flag.StringVar(&csvFile, "csv", "", "path to CSV file")
+	flag.StringVar(&statsStr, "stats", "all", "comma-separated statistics to display: all, "+strings.Join(statNames, ", "))
+	flag.StringVar(&outputFormat, "format", textFormat, "output format for a single subject: "+strings.Join(outputFormats, ", "))
+	flag.StringVar(&options.Subject, "subject", "", "xref (e.g. @I123@), REFN/UID or name and birth year of the subject (defaults to the first individual in the tree)")
+	flag.StringVar(&htmlFile, "html", "", "path to a self-contained HTML report with charts, for sharing")
+	flag.StringVar(&fanChartFile, "fan-chart", "", "path to an SVG fan chart of the subject's pedigree coloured by longevity")
+	flag.IntVar(&fanChartGenerations, "fan-generations", defaultFanChartGenerations, "number of generations of ancestors shown in the fan chart")
+	flag.StringVar(&exclusionsCsvFile, "exclusions-csv", "", "path to a CSV file listing the ancestors left out of the analysis and why")
+	flag.StringVar(&exclusionsJsonFile, "exclusions-json", "", "path to a JSON file listing the ancestors left out of the analysis and why")
+	flag.BoolVar(&batch, "batch", false, "summarise every individual in the tree instead of a single subject")
+	flag.StringVar(&filterStr, "filter", "all", "comma-separated conditions selecting batch subjects: all, living, deceased, born-after=YEAR, born-before=YEAR")
+	flag.StringVar(&birthFallbacksStr, "birth-fallback", "", "comma-separated TAG:DAYS events used to impute a missing birth date, tried in order (e.g. "+longevity.TypicalBirthFallbacks+")")
+	flag.StringVar(&deathFallbacksStr, "death-fallback", "", "comma-separated TAG:DAYS events used to impute a missing death date, tried in order (e.g. "+longevity.TypicalDeathFallbacks+")")
+	flag.StringVar(&options.Weighting, "weighting", longevity.GenerationalWeighting, "how ancestors are weighted: "+strings.Join(longevity.WeightingNames, ", "))
+	flag.Float64Var(&options.WeightingHalfLifeYears, "half-life-years", longevity.DefaultWeightingHalfLifeYears, "years between an ancestor's birth and the subject's that halve the ancestor's weight with calendar-decay weighting")
+	flag.Float64Var(&options.ImputedDateDiscount, "imputed-discount", longevity.DefaultImputedDateDiscount, "factor applied to an ancestor's weight for each imputed date with discount-imputed weighting")
+	flag.StringVar(&options.Conditioning, "condition-on", longevity.AdulthoodConditioning, "age ancestors are known to have survived to when comparing with life expectancy: "+strings.Join(longevity.ConditioningModes, ", "))
+	flag.IntVar(&options.AdultAge, "adult-age", longevity.DefaultAdultAge, "age at which an ancestor is taken to have reached adulthood")
+	flag.StringVar(&options.Comparison, "comparison", longevity.PeriodComparison, "compare ancestors with the statistics for the year they died (period) or were born (cohort)")
+	flag.StringVar(&options.UnknownSex, "unknown-sex", longevity.InferUnknownSex, "how to compare ancestors whose sex isn't recorded: "+strings.Join(longevity.UnknownSexPolicies, ", "))
+	flag.StringVar(&options.YearPolicy, "year-policy", longevity.ExactYearPolicy, "how to compare ancestors who died in a year with no stats: "+strings.Join(longevity.YearPolicies, ", "))
+	flag.StringVar(&options.DefaultCountry, "default-country", longevity.DefaultCountry, "country code whose stats are used for ancestors whose country is unknown or has no stats")
+	reference.register(flag.CommandLine)
 	flag.Parse()
 	if treeFile == "" {
 		fmt.Println("Error: --tree-file flag is required")
 		os.Exit(1)
 	}
-	var csvFile string
-	flag.StringVar(&csvFile, "csv", "", "path to CSV file")
-	flag.Parse()
-
-	maleDeathStats, err := parseDeathStats("male_death_stats.csv")
+	filter, err := longevity.ParseFilter(filterStr)
 	if err != nil {
-		fmt.Printf("Error parsing male death stats: %v", err)
+		fmt.Printf("Error parsing filter: %v", err)
 		os.Exit(1)
 	}
-	femaleDeathStats, err := parseDeathStats("female_death_stats.csv")
+	stats, err := parseStatSelection(statsStr)
 	if err != nil {
-		fmt.Printf("Error parsing female death stats: %v", err)
+		fmt.Printf("Error parsing stats: %v", err)
+		os.Exit(1)
+	}
+	if fanChartGenerations < 1 {
+		fmt.Println("Error: --fan-generations must be at least 1")
+		os.Exit(1)
+	}
+	if err := checkOutputFormat(outputFormat); err != nil {
+		fmt.Printf("Error parsing format: %v", err)
+		os.Exit(1)
+	}
+	if outputFormat == jsonFormat && batch {
+		fmt.Println("Error: --format json isn't supported with --batch")
+		os.Exit(1)
+	}
+	if options.YearPolicy == longevity.ExternalYearPolicy && !reference.hasExternalStats() {
+		fmt.Println("Error: --year-policy external requires --external-male-stats or --external-female-stats")
+		os.Exit(1)
+	}
+	options.BirthFallbacks, err = longevity.ParseFallbacks(birthFallbacksStr)
+	if err != nil {
+		fmt.Printf("Error parsing birth fallbacks: %v", err)
+		os.Exit(1)
+	}
+	options.DeathFallbacks, err = longevity.ParseFallbacks(deathFallbacksStr)
+	if err != nil {
+		fmt.Printf("Error parsing death fallbacks: %v", err)
 		os.Exit(1)
 	}
 
-	data, _ := ioutil.ReadFile(treeFile)
-	d := gedcom.NewDecoder(bytes.NewReader(data))
-	g, _ := d.Decode()
-	subject := g.Individual[0]
+	registry, datasets, err := reference.load()
+	if err != nil {
+		fmt.Printf("Error %v", err)
+		os.Exit(1)
+	}
+	analyzer, err := longevity.NewAnalyzer(registry, options)
+	if err != nil {
+		fmt.Printf("Error setting up analysis: %v", err)
+		os.Exit(1)
+	}
 
-	ancestors, err := getAncestors(subject, map[*gedcom.IndividualRecord]int{}, 1)
+	data, err := ioutil.ReadFile(treeFile)
 	if err != nil {
-		fmt.Printf("Error retrieving direct ancestors: %v", err)
+		fmt.Printf("Error reading tree file: %v", err)
 		os.Exit(1)
 	}
+	g, err := longevity.ReadTree(data, longevity.DefaultMaxUnpackedTreeBytes)
+	if err != nil {
+		fmt.Printf("Error decoding tree file: %v", err)
+		os.Exit(1)
+	}
+
+	if batch {
+		summaries := analyzer.AnalyzeBatch(g, filter)
+		printBatchResults(summaries, options.Weighting, stats)
+		if csvFile != "" {
+			if err := writeBatchCsv(summaries, csvFile, stats); err != nil {
+				fmt.Printf("Error writing batch CSV: %v", err)
+				os.Exit(1)
+			}
+		}
+		return
+	}
 
-	ancestorDeaths := getDeathStatsForAncestors(ancestors, maleDeathStats, femaleDeathStats)
-	printResults(ancestorDeaths, subject)
+	result, err := analyzer.Analyze(g)
+	if err != nil {
+		fmt.Printf("Error analysing subject: %v", err)
+		os.Exit(1)
+	}
+	subject, ancestorDeaths, exclusions, weighter := result.Subject, result.Ancestors, result.Exclusions, result.Weighter
+	if outputFormat == jsonFormat {
+		report := newJsonReport(ancestorDeaths, exclusions, subject, weighter, newJsonMetadata(analyzer.Options(), registry, datasets))
+		if err := writeJsonReport(os.Stdout, report); err != nil {
+			fmt.Printf("Error writing JSON: %v", err)
+			os.Exit(1)
+		}
+	} else {
+		printResults(result, analyzer.Weighters(subject), stats)
+		printExclusions(exclusions)
+	}
+	if exclusionsCsvFile != "" {
+		if err := writeExclusionsCsv(exclusions, exclusionsCsvFile); err != nil {
+			fmt.Printf("Error writing exclusions CSV: %v", err)
+			os.Exit(1)
+		}
+	}
+	if exclusionsJsonFile != "" {
+		if err := writeExclusionsJson(exclusions, exclusionsJsonFile); err != nil {
+			fmt.Printf("Error writing exclusions JSON: %v", err)
+			os.Exit(1)
+		}
+	}
+	if fanChartFile != "" {
+		if err := writeFanChart(subject, ancestorDeaths, exclusions, fanChartGenerations, fanChartFile); err != nil {
+			fmt.Printf("Error writing fan chart: %v", err)
+			os.Exit(1)
+		}
+	}
+	if htmlFile != "" {
+		if err := writeHtmlReport(ancestorDeaths, exclusions, subject, weighter, stats, htmlFile); err != nil {
+			fmt.Printf("Error writing HTML report: %v", err)
+			os.Exit(1)
+		}
+	}
 	if csvFile != "" {
-		writeCsv(ancestorDeaths, subject, csvFile)
+		if err := writeCsv(ancestorDeaths, subject, weighter, csvFile, stats); err != nil {
+			fmt.Printf("Error writing CSV: %v", err)
+			os.Exit(1)
+		}
 	}
 }
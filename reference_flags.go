@@ -0,0 +1,135 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+
+	"predict-death/longevity"
+)
+
+// referenceFlags are the flags choosing the statistics ancestors are compared with, shared by the
+// command line and the server.
+type referenceFlags struct {
+	maleStatsFile              string
+	femaleStatsFile            string
+	statsDir                   string
+	externalMaleStatsFile      string
+	externalFemaleStatsFile    string
+	maleLifeTablesFile         string
+	femaleLifeTablesFile       string
+	maleCohortLifeTablesFile   string
+	femaleCohortLifeTablesFile string
+	countryStats               []string
+	hmdDir                     string
+}
+
+func (f *referenceFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&f.maleLifeTablesFile, "male-life-tables", "", "path to a CSV of male period life tables (Year, Age, ex and optionally qx, lx, dx)")
+	fs.StringVar(&f.femaleLifeTablesFile, "female-life-tables", "", "path to a CSV of female period life tables (Year, Age, ex and optionally qx, lx, dx)")
+	fs.StringVar(&f.maleCohortLifeTablesFile, "male-cohort-life-tables", "", "path to a CSV of male cohort life tables by year of birth, in the same format as --male-life-tables")
+	fs.StringVar(&f.femaleCohortLifeTablesFile, "female-cohort-life-tables", "", "path to a CSV of female cohort life tables by year of birth, in the same format as --female-life-tables")
+	fs.StringVar(&f.maleStatsFile, "male-stats", "", "path to a CSV of male death stats to use instead of the bundled ONS statistics for England and Wales")
+	fs.StringVar(&f.femaleStatsFile, "female-stats", "", "path to a CSV of female death stats to use instead of the bundled ONS statistics for England and Wales")
+	fs.StringVar(&f.statsDir, "stats-dir", "", "directory containing "+longevity.MaleDeathStatsFileName+" and "+longevity.FemaleDeathStatsFileName+" to use instead of the bundled ONS statistics")
+	fs.StringVar(&f.externalMaleStatsFile, "external-male-stats", "", "path to a CSV of male death stats for years outside the main stats, used with --year-policy external")
+	fs.StringVar(&f.externalFemaleStatsFile, "external-female-stats", "", "path to a CSV of female death stats for years outside the main stats, used with --year-policy external")
+	fs.Func("country-stats", "CODE:MALE_CSV:FEMALE_CSV death stats for another country, used for ancestors born or died there (repeatable)", func(value string) error {
+		f.countryStats = append(f.countryStats, value)
+		return nil
+	})
+	fs.StringVar(&f.hmdDir, "hmd-dir", "", "directory of Human Mortality Database files (fltper_1x1, mltper_1x1, fltcoh_1x1, mltcoh_1x1, Mx_1x1, E0per) for any number of countries")
+}
+
+func (f *referenceFlags) hasExternalStats() bool {
+	return f.externalMaleStatsFile != "" || f.externalFemaleStatsFile != ""
+}
+
+// load reads the statistics the flags point to, returning them along with a description of every
+// dataset used. Errors read as a sentence after "Error".
+func (f *referenceFlags) load() (longevity.ReferenceRegistry, []string, error) {
+	var reference longevity.ReferenceData
+	var err error
+	reference.MaleDeathStats, err = longevity.LoadDeathStats(f.maleStatsFile, f.statsDir, longevity.MaleDeathStatsFileName)
+	if err != nil {
+		return nil, nil, fmt.Errorf("parsing male death stats: %v", err)
+	}
+	reference.FemaleDeathStats, err = longevity.LoadDeathStats(f.femaleStatsFile, f.statsDir, longevity.FemaleDeathStatsFileName)
+	if err != nil {
+		return nil, nil, fmt.Errorf("parsing female death stats: %v", err)
+	}
+	if f.externalMaleStatsFile != "" {
+		reference.MaleExternalDeathStats, err = longevity.ParseDeathStats(f.externalMaleStatsFile)
+		if err != nil {
+			return nil, nil, fmt.Errorf("parsing external male death stats: %v", err)
+		}
+	}
+	if f.externalFemaleStatsFile != "" {
+		reference.FemaleExternalDeathStats, err = longevity.ParseDeathStats(f.externalFemaleStatsFile)
+		if err != nil {
+			return nil, nil, fmt.Errorf("parsing external female death stats: %v", err)
+		}
+	}
+	if f.maleLifeTablesFile != "" {
+		reference.MaleLifeTables, err = longevity.ParseLifeTables(f.maleLifeTablesFile)
+		if err != nil {
+			return nil, nil, fmt.Errorf("parsing male life tables: %v", err)
+		}
+	}
+	if f.femaleLifeTablesFile != "" {
+		reference.FemaleLifeTables, err = longevity.ParseLifeTables(f.femaleLifeTablesFile)
+		if err != nil {
+			return nil, nil, fmt.Errorf("parsing female life tables: %v", err)
+		}
+	}
+	if f.maleCohortLifeTablesFile != "" {
+		reference.MaleCohortLifeTables, err = longevity.ParseLifeTables(f.maleCohortLifeTablesFile)
+		if err != nil {
+			return nil, nil, fmt.Errorf("parsing male cohort life tables: %v", err)
+		}
+	}
+	if f.femaleCohortLifeTablesFile != "" {
+		reference.FemaleCohortLifeTables, err = longevity.ParseLifeTables(f.femaleCohortLifeTablesFile)
+		if err != nil {
+			return nil, nil, fmt.Errorf("parsing female cohort life tables: %v", err)
+		}
+	}
+	datasets := []string{
+		longevity.DeathStatsSource(f.maleStatsFile, f.statsDir, longevity.MaleDeathStatsFileName),
+		longevity.DeathStatsSource(f.femaleStatsFile, f.statsDir, longevity.FemaleDeathStatsFileName),
+	}
+	for _, file := range []string{f.externalMaleStatsFile, f.externalFemaleStatsFile, f.maleLifeTablesFile, f.femaleLifeTablesFile, f.maleCohortLifeTablesFile, f.femaleCohortLifeTablesFile} {
+		if file != "" {
+			datasets = append(datasets, file)
+		}
+	}
+
+	registry := longevity.ReferenceRegistry{longevity.DefaultCountry: reference}
+	for _, value := range f.countryStats {
+		code, maleStatsFile, femaleStatsFile, err := longevity.ParseCountryStats(value)
+		if err != nil {
+			return nil, nil, fmt.Errorf("parsing country stats: %v", err)
+		}
+		var countryReference longevity.ReferenceData
+		countryReference.MaleDeathStats, err = longevity.ParseDeathStats(maleStatsFile)
+		if err != nil {
+			return nil, nil, fmt.Errorf("parsing male death stats for %s: %v", code, err)
+		}
+		countryReference.FemaleDeathStats, err = longevity.ParseDeathStats(femaleStatsFile)
+		if err != nil {
+			return nil, nil, fmt.Errorf("parsing female death stats for %s: %v", code, err)
+		}
+		registry[code] = countryReference
+		datasets = append(datasets, maleStatsFile, femaleStatsFile)
+	}
+	if f.hmdDir != "" {
+		hmdRegistry, err := longevity.LoadHMDDirectory(f.hmdDir)
+		if err != nil {
+			return nil, nil, fmt.Errorf("loading HMD statistics: %v", err)
+		}
+		if err := registry.Merge(hmdRegistry); err != nil {
+			return nil, nil, fmt.Errorf("loading HMD statistics: %v", err)
+		}
+		datasets = append(datasets, f.hmdDir)
+	}
+	return registry, datasets, nil
+}
@@ -0,0 +1,290 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"time"
+
+	"predict-death/longevity"
+)
+
+const (
+	defaultServeAddr      = "localhost:8080"
+	defaultMaxUploadBytes = 32 << 20
+	defaultRequestTimeout = 60 * time.Second
+
+	// treeFormField is the multipart field holding the uploaded tree, in any format ReadTree reads.
+	treeFormField = "tree"
+	// maxFieldBytes caps each non-file form field, which only ever holds a short option value.
+	maxFieldBytes = 4 << 10
+)
+
+// csvFormat is only offered by the server, which has no files to write the CSV to.
+const csvFormat = "csv"
+
+var serveFormats = []string{jsonFormat, csvFormat}
+
+// analysisServer analyses family trees uploaded to /analyze, holding the uploads and the results in
+// memory only. The reference statistics are loaded once and shared by every request.
+type analysisServer struct {
+	registry       longevity.ReferenceRegistry
+	datasets       []string
+	externalStats  bool
+	maxUploadBytes int64
+}
+
+// httpError is an error with the status code it should be reported with.
+type httpError struct {
+	status int
+	err    error
+}
+
+func (e *httpError) Error() string {
+	return e.err.Error()
+}
+
+func newHttpError(status int, format string, args ...interface{}) *httpError {
+	return &httpError{status: status, err: fmt.Errorf(format, args...)}
+}
+
+func writeHttpError(w http.ResponseWriter, status int, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+}
+
+// handler routes requests to the server, giving up on any that take longer than the timeout.
+func (s *analysisServer) handler(timeout time.Duration) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/analyze", s.analyze)
+	return http.TimeoutHandler(mux, timeout, `{"error": "analysis timed out"}`)
+}
+
+// readUpload reads the uploaded tree and the option fields from a multipart request, along with any
+// options given in the query string. Fields override query parameters of the same name.
+func (s *analysisServer) readUpload(r *http.Request) ([]byte, url.Values, error) {
+	reader, err := r.MultipartReader()
+	if err != nil {
+		return nil, nil, newHttpError(http.StatusBadRequest, "expected a multipart/form-data upload: %v", err)
+	}
+	params := r.URL.Query()
+	fields := url.Values{}
+	var tree []byte
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, uploadError(err)
+		}
+		tree, err = readPart(part, tree, fields)
+		part.Close()
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	if tree == nil {
+		return nil, nil, newHttpError(http.StatusBadRequest, "no tree file uploaded in the '%s' field", treeFormField)
+	}
+	for name, values := range fields {
+		params[name] = values
+	}
+	return tree, params, nil
+}
+
+// readPart reads one part of the upload, returning the tree if the part holds it and adding it to
+// the fields otherwise.
+func readPart(part *multipart.Part, tree []byte, fields url.Values) ([]byte, error) {
+	name := part.FormName()
+	if name == treeFormField {
+		if tree != nil {
+			return nil, newHttpError(http.StatusBadRequest, "more than one tree file uploaded")
+		}
+		data, err := io.ReadAll(part)
+		if err != nil {
+			return nil, uploadError(err)
+		}
+		return data, nil
+	}
+	value, err := io.ReadAll(io.LimitReader(part, maxFieldBytes+1))
+	if err != nil {
+		return nil, uploadError(err)
+	}
+	if len(value) > maxFieldBytes {
+		return nil, newHttpError(http.StatusBadRequest, "field '%s' is longer than %d bytes", name, maxFieldBytes)
+	}
+	fields.Add(name, string(value))
+	return tree, nil
+}
+
+func uploadError(err error) error {
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		return newHttpError(http.StatusRequestEntityTooLarge, "upload is larger than %d bytes", maxBytesErr.Limit)
+	}
+	return newHttpError(http.StatusBadRequest, "reading upload: %v", err)
+}
+
+// parseServeParams turns the request parameters, named after the command line flags, into analysis
+// options, the statistics to show and the response format.
+func parseServeParams(params url.Values) (longevity.Options, StatSelection, string, error) {
+	options := longevity.DefaultOptions()
+	statsStr, format := "all", jsonFormat
+	stringParams := map[string]*string{
+		"subject":         &options.Subject,
+		"weighting":       &options.Weighting,
+		"condition-on":    &options.Conditioning,
+		"comparison":      &options.Comparison,
+		"unknown-sex":     &options.UnknownSex,
+		"year-policy":     &options.YearPolicy,
+		"default-country": &options.DefaultCountry,
+		"stats":           &statsStr,
+		"format":          &format,
+	}
+	for name := range params {
+		value := params.Get(name)
+		var err error
+		switch name {
+		case "half-life-years":
+			options.WeightingHalfLifeYears, err = strconv.ParseFloat(value, 64)
+		case "imputed-discount":
+			options.ImputedDateDiscount, err = strconv.ParseFloat(value, 64)
+		case "adult-age":
+			options.AdultAge, err = strconv.Atoi(value)
+		case "birth-fallback":
+			options.BirthFallbacks, err = longevity.ParseFallbacks(value)
+		case "death-fallback":
+			options.DeathFallbacks, err = longevity.ParseFallbacks(value)
+		default:
+			target, ok := stringParams[name]
+			if !ok {
+				return options, nil, "", fmt.Errorf("unknown parameter '%s'", name)
+			}
+			*target = value
+		}
+		if err != nil {
+			return options, nil, "", fmt.Errorf("parsing %s: %v", name, err)
+		}
+	}
+	stats, err := parseStatSelection(statsStr)
+	if err != nil {
+		return options, nil, "", fmt.Errorf("parsing stats: %v", err)
+	}
+	if format != jsonFormat && format != csvFormat {
+		return options, nil, "", fmt.Errorf("unknown format '%s', expected one of %v", format, serveFormats)
+	}
+	return options, stats, format, nil
+}
+
+func (s *analysisServer) analyze(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		writeHttpError(w, http.StatusMethodNotAllowed, fmt.Errorf("expected a POST request"))
+		return
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, s.maxUploadBytes)
+	tree, params, err := s.readUpload(r)
+	if err != nil {
+		var httpErr *httpError
+		if errors.As(err, &httpErr) {
+			writeHttpError(w, httpErr.status, err)
+		} else {
+			writeHttpError(w, http.StatusBadRequest, err)
+		}
+		return
+	}
+
+	options, stats, format, err := parseServeParams(params)
+	if err != nil {
+		writeHttpError(w, http.StatusBadRequest, err)
+		return
+	}
+	if options.YearPolicy == longevity.ExternalYearPolicy && !s.externalStats {
+		writeHttpError(w, http.StatusBadRequest, fmt.Errorf("year-policy external requires the server to be started with --external-male-stats or --external-female-stats"))
+		return
+	}
+	analyzer, err := longevity.NewAnalyzer(s.registry, options)
+	if err != nil {
+		writeHttpError(w, http.StatusBadRequest, fmt.Errorf("setting up analysis: %v", err))
+		return
+	}
+
+	// A compressed tree may unpack to no more than an uncompressed upload could carry.
+	g, err := longevity.ReadTree(tree, s.maxUploadBytes)
+	if err != nil {
+		writeHttpError(w, http.StatusUnprocessableEntity, fmt.Errorf("decoding tree file: %v", err))
+		return
+	}
+	result, err := analyzer.Analyze(g)
+	if err != nil {
+		writeHttpError(w, http.StatusUnprocessableEntity, fmt.Errorf("analysing subject: %v", err))
+		return
+	}
+
+	// Render into memory first so a failure can still be reported with an error status.
+	var body bytes.Buffer
+	if format == csvFormat {
+		err = renderCsv(&body, result.Ancestors, result.Subject, result.Weighter, stats)
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	} else {
+		report := newJsonReport(result.Ancestors, result.Exclusions, result.Subject, result.Weighter, newJsonMetadata(analyzer.Options(), s.registry, s.datasets))
+		err = writeJsonReport(&body, report)
+		w.Header().Set("Content-Type", "application/json")
+	}
+	if err != nil {
+		w.Header().Del("Content-Type")
+		writeHttpError(w, http.StatusInternalServerError, fmt.Errorf("writing %s: %v", format, err))
+		return
+	}
+	body.WriteTo(w)
+}
+
+// runServe starts the server for the serve subcommand, which takes its own flags.
+func runServe(args []string) {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	var reference referenceFlags
+	var addr string
+	var maxUploadBytes int64
+	var timeout time.Duration
+	fs.StringVar(&addr, "addr", defaultServeAddr, "address to listen on")
+	fs.Int64Var(&maxUploadBytes, "max-upload-bytes", defaultMaxUploadBytes, "largest request accepted, including the tree file, and largest tree a compressed upload may unpack to")
+	fs.DurationVar(&timeout, "timeout", defaultRequestTimeout, "longest time spent reading, analysing and answering a request")
+	reference.register(fs)
+	fs.Parse(args)
+
+	registry, datasets, err := reference.load()
+	if err != nil {
+		fmt.Printf("Error %v", err)
+		os.Exit(1)
+	}
+	s := &analysisServer{
+		registry:       registry,
+		datasets:       datasets,
+		externalStats:  reference.hasExternalStats(),
+		maxUploadBytes: maxUploadBytes,
+	}
+	server := &http.Server{
+		Addr:              addr,
+		Handler:           s.handler(timeout),
+		ReadHeaderTimeout: 10 * time.Second,
+		ReadTimeout:       timeout,
+		WriteTimeout:      timeout + 5*time.Second,
+		IdleTimeout:       2 * timeout,
+		MaxHeaderBytes:    1 << 20,
+	}
+	fmt.Printf("Listening on http://%s/analyze\n", addr)
+	if err := server.ListenAndServe(); err != nil {
+		fmt.Printf("Error serving: %v", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"predict-death/longevity"
+)
+
+const serveTestTree = `0 HEAD
+0 @I1@ INDI
+1 NAME Ann /Child/
+1 BIRT
+2 DATE 1960
+1 FAMC @F1@
+0 @I2@ INDI
+1 NAME John /Child/
+1 SEX M
+1 BIRT
+2 DATE 1930
+1 DEAT
+2 DATE 2000
+1 FAMS @F1@
+0 @I3@ INDI
+1 NAME Mary /Smith/
+1 SEX F
+1 BIRT
+2 DATE 1932
+1 DEAT
+2 DATE 2010
+1 FAMS @F1@
+0 @F1@ FAM
+1 HUSB @I2@
+1 WIFE @I3@
+1 CHIL @I1@
+0 TRLR
+`
+
+func newTestServer(t *testing.T) *analysisServer {
+	t.Helper()
+	registry, err := longevity.DefaultRegistry()
+	if err != nil {
+		t.Fatalf("unexpected error loading stats: %s", err)
+	}
+	return &analysisServer{registry: registry, datasets: []string{"bundled"}, maxUploadBytes: 1 << 20}
+}
+
+// uploadRequest builds a multipart upload of the tree along with the given fields.
+func uploadRequest(t *testing.T, target string, tree string, fields map[string]string) *http.Request {
+	t.Helper()
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	if tree != "" {
+		part, err := writer.CreateFormFile(treeFormField, "tree.ged")
+		if err != nil {
+			t.Fatalf("unexpected error creating upload: %s", err)
+		}
+		part.Write([]byte(tree))
+	}
+	for name, value := range fields {
+		writer.WriteField(name, value)
+	}
+	writer.Close()
+	r := httptest.NewRequest(http.MethodPost, target, &body)
+	r.Header.Set("Content-Type", writer.FormDataContentType())
+	return r
+}
+
+func TestServeJson(t *testing.T) {
+	w := httptest.NewRecorder()
+	newTestServer(t).handler(time.Minute).ServeHTTP(w, uploadRequest(t, "/analyze?weighting=generational", serveTestTree, map[string]string{
+		"subject":   "@I1@",
+		"weighting": "equal",
+	}))
+	if w.Code != http.StatusOK {
+		t.Fatalf("got status %d, want %d: %s", w.Code, http.StatusOK, w.Body)
+	}
+	var report JsonReport
+	if err := json.Unmarshal(w.Body.Bytes(), &report); err != nil {
+		t.Fatalf("unexpected error decoding response: %s", err)
+	}
+	if report.Subject.Name != "Ann Child" {
+		t.Errorf("got subject %q, want %q", report.Subject.Name, "Ann Child")
+	}
+	if len(report.Ancestors) != 2 {
+		t.Errorf("got %d ancestors, want 2", len(report.Ancestors))
+	}
+	if report.Metadata.Weighting != longevity.EqualWeighting {
+		t.Errorf("got weighting %q, want the form field to override the query parameter", report.Metadata.Weighting)
+	}
+}
+
+func TestServeCsv(t *testing.T) {
+	w := httptest.NewRecorder()
+	newTestServer(t).handler(time.Minute).ServeHTTP(w, uploadRequest(t, "/analyze", serveTestTree, map[string]string{
+		"format": "csv",
+		"stats":  "median",
+	}))
+	if w.Code != http.StatusOK {
+		t.Fatalf("got status %d, want %d: %s", w.Code, http.StatusOK, w.Body)
+	}
+	if got := w.Header().Get("Content-Type"); !strings.HasPrefix(got, "text/csv") {
+		t.Errorf("got content type %q, want text/csv", got)
+	}
+	rows, err := csv.NewReader(w.Body).ReadAll()
+	if err != nil {
+		t.Fatalf("unexpected error reading CSV: %s", err)
+	}
+	if len(rows) != 3 {
+		t.Fatalf("got %d rows, want a header and 2 ancestors", len(rows))
+	}
+	if strings.Contains(strings.Join(rows[0], ","), "Life Expectancy") {
+		t.Errorf("got header %v, want only the median columns", rows[0])
+	}
+}
+
+func TestServeErrors(t *testing.T) {
+	s := newTestServer(t)
+	s.maxUploadBytes = 1024
+	tests := []struct {
+		name    string
+		request *http.Request
+		status  int
+		message string
+	}{
+		{
+			name:    "get",
+			request: httptest.NewRequest(http.MethodGet, "/analyze", nil),
+			status:  http.StatusMethodNotAllowed,
+			message: "expected a POST request",
+		},
+		{
+			name:    "not multipart",
+			request: httptest.NewRequest(http.MethodPost, "/analyze", strings.NewReader(serveTestTree)),
+			status:  http.StatusBadRequest,
+			message: "expected a multipart/form-data upload",
+		},
+		{
+			name:    "no tree",
+			request: uploadRequest(t, "/analyze", "", map[string]string{"subject": "@I1@"}),
+			status:  http.StatusBadRequest,
+			message: "no tree file uploaded",
+		},
+		{
+			name:    "too large",
+			request: uploadRequest(t, "/analyze", serveTestTree+strings.Repeat("0 NOTE padding\n", 100), nil),
+			status:  http.StatusRequestEntityTooLarge,
+			message: "upload is larger than 1024 bytes",
+		},
+		{
+			name:    "unknown parameter",
+			request: uploadRequest(t, "/analyze?weigting=equal", "0 HEAD\n0 TRLR\n", nil),
+			status:  http.StatusBadRequest,
+			message: "unknown parameter 'weigting'",
+		},
+		{
+			name:    "bad option",
+			request: uploadRequest(t, "/analyze", "0 HEAD\n0 TRLR\n", map[string]string{"weighting": "bad"}),
+			status:  http.StatusBadRequest,
+			message: "unknown weighting 'bad'",
+		},
+		{
+			name:    "bad format",
+			request: uploadRequest(t, "/analyze", "0 HEAD\n0 TRLR\n", map[string]string{"format": "text"}),
+			status:  http.StatusBadRequest,
+			message: "unknown format 'text'",
+		},
+		{
+			name:    "external without stats",
+			request: uploadRequest(t, "/analyze", "0 HEAD\n0 TRLR\n", map[string]string{"year-policy": "external"}),
+			status:  http.StatusBadRequest,
+			message: "year-policy external requires",
+		},
+		{
+			name:    "missing subject",
+			request: uploadRequest(t, "/analyze", "0 HEAD\n0 @I1@ INDI\n1 NAME Ann /Child/\n0 TRLR\n", map[string]string{"subject": "@I9@"}),
+			status:  http.StatusUnprocessableEntity,
+			message: "analysing subject",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			s.handler(time.Minute).ServeHTTP(w, test.request)
+			if w.Code != test.status {
+				t.Errorf("got status %d, want %d: %s", w.Code, test.status, w.Body)
+			}
+			var body map[string]string
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+				t.Fatalf("unexpected error decoding response %q: %s", w.Body, err)
+			}
+			if !strings.Contains(body["error"], test.message) {
+				t.Errorf("got error %q, want it to contain %q", body["error"], test.message)
+			}
+		})
+	}
+}
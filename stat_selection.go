@@ -0,0 +1,92 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Statistics ancestors' ages at death can be compared with, in the order they're displayed.
+const (
+	lifeExpectancyStat = "life-expectancy"
+	medianStat         = "median"
+	modalStat          = "modal"
+	percentileStat     = "percentile"
+)
+
+var statNames = []string{lifeExpectancyStat, medianStat, modalStat, percentileStat}
+
+// StatSelection is the set of statistics to display. Percentiles aren't a diff, so they only appear
+// where a mean or a per-ancestor value makes sense.
+type StatSelection map[string]bool
+
+func (s StatSelection) includes(stat string) bool {
+	return s[stat]
+}
+
+// diffStats returns the selected statistics that ancestors' ages at death are diffed against, in
+// display order.
+func (s StatSelection) diffStats() []string {
+	var stats []string
+	for _, stat := range statNames {
+		if stat != percentileStat && s.includes(stat) {
+			stats = append(stats, stat)
+		}
+	}
+	return stats
+}
+
+// parseStatSelection parses a comma-separated list of statistics, or "all".
+func parseStatSelection(value string) (StatSelection, error) {
+	selection := StatSelection{}
+	for _, name := range strings.Split(value, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		switch name {
+		case "":
+			continue
+		case "all":
+			for _, stat := range statNames {
+				selection[stat] = true
+			}
+			continue
+		}
+		known := false
+		for _, stat := range statNames {
+			if name == stat {
+				known = true
+			}
+		}
+		if !known {
+			return nil, fmt.Errorf("unknown statistic '%s', expected one of %v", name, statNames)
+		}
+		selection[name] = true
+	}
+	if len(selection) == 0 {
+		return nil, fmt.Errorf("no statistics selected, expected one or more of %v", statNames)
+	}
+	return selection, nil
+}
+
+// statLabel is the name a diff statistic is displayed under.
+func statLabel(stat string) string {
+	switch stat {
+	case lifeExpectancyStat:
+		return "Life Expectancy"
+	case medianStat:
+		return "Median Death Age"
+	case modalStat:
+		return "Modal Age at Death"
+	}
+	return "Survival Percentile"
+}
+
+// pickDiff returns the diff for the given statistic from a set of life expectancy, median and modal
+// diffs, in the order longevity.CalculateWeightedAverages returns them.
+func pickDiff(stat string, lifeExpectancyDiff int, medianDiff int, modalDiff int) int {
+	switch stat {
+	case lifeExpectancyStat:
+		return lifeExpectancyDiff
+	case medianStat:
+		return medianDiff
+	}
+	return modalDiff
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseStatSelection(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    []string
+		wantErr bool
+	}{
+		{value: "all", want: []string{lifeExpectancyStat, medianStat, modalStat}},
+		{value: "modal, Life-Expectancy", want: []string{lifeExpectancyStat, modalStat}},
+		{value: "percentile", want: nil},
+		{value: "mean", wantErr: true},
+		{value: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := parseStatSelection(tt.value)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%q: got error %v, want error %v", tt.value, err, tt.wantErr)
+			continue
+		}
+		if tt.wantErr {
+			continue
+		}
+		if diffStats := got.diffStats(); !reflect.DeepEqual(diffStats, tt.want) {
+			t.Errorf("%q: got diff stats %v, want %v", tt.value, diffStats, tt.want)
+		}
+	}
+
+	selection, _ := parseStatSelection("median,percentile")
+	if !selection.includes(percentileStat) || selection.includes(modalStat) {
+		t.Errorf("got %v", selection)
+	}
+}
+
+func TestPickDiff(t *testing.T) {
+	for stat, want := range map[string]int{lifeExpectancyStat: 1, medianStat: 2, modalStat: 3} {
+		if got := pickDiff(stat, 1, 2, 3); got != want {
+			t.Errorf("%s: got %d, want %d", stat, got, want)
+		}
+	}
+}